@@ -17,15 +17,207 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 )
 
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
 // NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
 
-type Responses struct {
+// SpillType is where the samples are written with their full bodies
+// +kubebuilder:validation:Enum=ConfigMap;ResultsStore
+type SpillType string
+
+const (
+	// ConfigMapSpill writes the samples of every worker to a ConfigMap named <load generator>-samples-<shard>
+	ConfigMapSpill SpillType = "ConfigMap"
+	// ResultsStoreSpill writes the samples to the results directory of the controller
+	ResultsStoreSpill SpillType = "ResultsStore"
+)
+
+// SamplingSpec bounds the requests and responses kept from a run
+type SamplingSpec struct {
+	// Errors is the number of the most recent failed requests kept
+	// +optional
+	// +kubebuilder:default=5
+	// +kubebuilder:validation:Minimum=0
+	Errors int `json:"errors"`
+	// Successes is the number of successful requests kept, picked uniformly over the run
+	// +optional
+	// +kubebuilder:default=5
+	// +kubebuilder:validation:Minimum=0
+	Successes int `json:"successes"`
+	// Spill writes a larger set of samples with their full bodies outside of the status
+	// +optional
+	Spill *SpillSpec `json:"spill,omitempty"`
+}
+
+type SpillSpec struct {
+	Type SpillType `json:"type"`
+	// Errors and Successes are the number of samples of each kind kept in the spill
+	// +optional
+	// +kubebuilder:default=100
+	// +kubebuilder:validation:Minimum=0
+	Errors int `json:"errors"`
+	// +optional
+	// +kubebuilder:default=100
+	// +kubebuilder:validation:Minimum=0
+	Successes int `json:"successes"`
+}
+
+// ExportFormat is a file format the results of a run are exported in
+// +kubebuilder:validation:Enum=JSONLines;CSV;JUnit
+type ExportFormat string
+
+const (
+	// JSONLinesExport writes the requests of the run as records.jsonl, one JSON object per line
+	JSONLinesExport ExportFormat = "JSONLines"
+	// CSVExport writes the requests of the run as records.csv
+	CSVExport ExportFormat = "CSV"
+	// JUnitExport writes the assertions as test cases of junit.xml
+	JUnitExport ExportFormat = "JUnit"
+)
+
+// ExportType is where the results of a run are exported to
+// +kubebuilder:validation:Enum=ConfigMap;ResultsStore
+type ExportType string
+
+const (
+	// ConfigMapExport writes the files to a ConfigMap named <load generator>-results, records are left
+	// out once they don't fit. It's the default
+	ConfigMapExport ExportType = "ConfigMap"
+	// ResultsStoreExport writes the files to <results directory>/<namespace>/<load generator>/ of the
+	// controller, the directory can be a PersistentVolumeClaim and is served over HTTP by the controller.
+	// The controller has to be started with --results-dir
+	ResultsStoreExport ExportType = "ResultsStore"
+)
+
+// ExportSpec writes the results of a run to files once it's finished, summary.json is always written
+type ExportSpec struct {
+	// +optional
+	// +kubebuilder:default=ConfigMap
+	Type ExportType `json:"type,omitempty"`
+	// Formats are the files written along with the summary
+	// +optional
+	// +kubebuilder:default={JSONLines,CSV,JUnit}
+	Formats []ExportFormat `json:"formats,omitempty"`
+	// MaxRecords is the number of requests recorded for the JSONLines and CSV files, split across the
+	// workers. The requests after it are counted in the summary but not recorded
+	// +optional
+	// +kubebuilder:default=10000
+	// +kubebuilder:validation:Minimum=0
+	MaxRecords int `json:"maxRecords"`
+}
+
+// RecordingType is where the recording of a run is kept once it's finished
+// +kubebuilder:validation:Enum=ConfigMap;ResultsStore
+type RecordingType string
+
+const (
+	// ConfigMapRecording keeps the requests of every worker in a ConfigMap named <load generator>-recording-<shard>
+	ConfigMapRecording RecordingType = "ConfigMap"
+	// ResultsStoreRecording also merges them into <results directory>/<namespace>/<load generator>/recording.jsonl
+	// of the controller, which can be replayed from its URL
+	ResultsStoreRecording RecordingType = "ResultsStore"
+)
+
+// RecordSpec records the requests of the run as they were sent, after the routes were sampled and the
+// versions picked, so another load generator can replay them. A worker records up to about 900KiB
+// of requests, the ones after are counted as dropped
+type RecordSpec struct {
+	// +optional
+	// +kubebuilder:default=ConfigMap
+	Type RecordingType `json:"type,omitempty"`
+}
+
+// ReplaySpec sends the requests recorded by another run in place of the routes, at the offsets they were
+// sent at from the start of that run. One of From or URL must be set
+type ReplaySpec struct {
+	// From is the load generator whose recording is replayed, the replay waits for its run to finish
+	// +optional
+	From *LoadGeneratorRef `json:"from,omitempty"`
+	// URL is where a recording.jsonl is downloaded from, like the results server of the controller
+	// +optional
+	URL string `json:"url,omitempty"`
+	// Speed scales the pace of the recording, 2 replays it twice as fast and 0.5 at half the speed
+	// +optional
+	// +kubebuilder:default="1"
+	Speed *resource.Quantity `json:"speed,omitempty"`
+}
+
+// NotifySpec tells other tools once a run is finished, so they don't have to poll the status
+type NotifySpec struct {
+	// Webhooks are sent the summary of the finished run as JSON, the one written to summary.json by an export
+	// +optional
+	Webhooks []Webhook `json:"webhooks,omitempty"`
+	// Event emits a Kubernetes Event on the load generator once the run is finished, a Warning when the run
+	// stopped early or its assertions failed
+	// +optional
+	Event bool `json:"event,omitempty"`
+}
+
+// Webhook is a URL the summary of a finished run is POSTed to
+type Webhook struct {
+	// +kubebuilder:validation:Pattern=`^https?://`
+	URL string `json:"url"`
+	// MaxAttempts is how many times the summary is sent before giving up, the wait between attempts
+	// doubles from 5 seconds up to 5 minutes
+	// +optional
+	// +kubebuilder:default=5
+	// +kubebuilder:validation:Minimum=1
+	MaxAttempts int `json:"maxAttempts,omitempty"`
+}
+
+// NotificationStatus is the delivery of the summary of the run to a webhook
+type NotificationStatus struct {
+	URL string `json:"url"`
+	// Delivered is set once the webhook responded with a 2xx status
+	Delivered bool `json:"delivered"`
+	// +optional
+	Attempts int `json:"attempts,omitempty"`
+	// +optional
+	LastAttempt *metav1.Time `json:"lastAttempt,omitempty"`
+	// Error is why the last attempt failed
+	// +optional
+	Error string `json:"error,omitempty"`
+}
+
+// Sample is a request sent by the load generator with the response it got
+type Sample struct {
+	Template string          `json:"template"`
+	Time     metav1.Time     `json:"time"`
+	Latency  metav1.Duration `json:"latency"`
+	// +optional
+	StatusCode int `json:"statusCode,omitempty"`
+	// +optional
+	Error string `json:"error,omitempty"`
+	// Request and Response bodies are cut short in the status
 	Request  string `json:"request"`
 	Response string `json:"response"`
+	// Sequence is the number of the request in its shard, with the seed it picks the routes of the request
+	// +optional
+	Sequence int64 `json:"sequence,omitempty"`
+	// Route lists the hops of the request tree that were called or left out by their probability
+	// +optional
+	Route []RouteDecision `json:"route,omitempty"`
+}
+
+// RouteDecision is whether a hop of a request tree was called
+type RouteDecision struct {
+	// Path is the designations from the first hop down to this one, like service_1/service_2@v2
+	Path   string `json:"path"`
+	Called bool   `json:"called"`
+}
+
+type Samples struct {
+	// +optional
+	Errors []Sample `json:"errors,omitempty"`
+	// +optional
+	Successes []Sample `json:"successes,omitempty"`
+	// SpilledTo is the ConfigMap or the file the full samples are written to
+	// +optional
+	SpilledTo string `json:"spilledTo,omitempty"`
 }
 
 type SimulationRef struct {
@@ -33,34 +225,518 @@ type SimulationRef struct {
 	Namespace string `json:"namespace"`
 }
 
+func (in SimulationRef) NamespacedName() types.NamespacedName {
+	return types.NamespacedName{Namespace: in.Namespace, Name: in.Name}
+}
+
 // LoadGeneratorSpec defines the desired state of LoadGenerator
 type LoadGeneratorSpec struct {
-	Routes []string `json:"requests"`
+	// Routes are the request trees sent to the simulation
+	// +optional
+	Routes []RouteTemplate `json:"routes,omitempty"`
+	// Requests are request trees in the legacy raw JSON form, they are converted to
+	// route templates named request-<index> by the controller.
+	// Deprecated: use Routes instead
+	// +optional
+	Requests []string `json:"requests,omitempty"`
+	// Scenarios are user journeys sent in place of single requests, every journey sends the routes of its
+	// steps one after another. The rate, the concurrency and requestCount then count journeys
+	// +optional
+	Scenarios []Scenario `json:"scenarios,omitempty"`
+	// Replicas is the number of worker pods the load is split across, when neither Rate nor Concurrency
+	// is set every worker sends a request per route every BetweenDelay. With Concurrency there are no
+	// more workers than users, every worker needs at least one
 	// +optional
 	// +kubebuilder:default=1
 	Replicas      int           `json:"replicas"`
 	SimulationRef SimulationRef `json:"simulationRef"`
+	// RequestCount is the number of requests to send before stopping
 	// +optional
 	// +kubebuilder:validation:Minimum=0
 	RequestCount *int `json:"requestCount"`
+	// Timeout stops the load generator once it's been sending requests for this long, the time it was paused
+	// or its workers were restarting isn't counted
+	// +optional
+	Timeout *metav1.Duration `json:"timeout"`
+	// Seed makes the sampled routes and versions reproducible, a request picks the same routes in every
+	// run with the same seed. A random seed is picked and kept in status.seed when it's not set
+	// +optional
+	Seed *int64 `json:"seed,omitempty"`
+	// Abort stops the load generator early when the simulation is not coping with the load
+	// +optional
+	Abort *AbortSpec `json:"abort,omitempty"`
+	// Warmup leaves the first requests of the run out of its results, they are counted in status.warmup
+	// +optional
+	Warmup *WarmupSpec `json:"warmup,omitempty"`
+	// Assertions decide whether the run passed, they are checked while the run is going and once it's
+	// finished, the result is the Passed condition
+	// +optional
+	Assertions []Assertion `json:"assertions,omitempty"`
+	// +optional
+	BetweenDelay metav1.Duration `json:"betweenDelay"`
+	// Rate is the number of requests started per second. Requests are sent on schedule no matter
+	// how long the earlier ones take and latency is measured from the scheduled time (open model)
+	// +optional
+	Rate *resource.Quantity `json:"rate,omitempty"`
+	// Concurrency is the number of users sending requests back to back (closed model), it takes
+	// precedence over Rate
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	Concurrency *int `json:"concurrency,omitempty"`
+	// Sampling bounds the requests and responses kept in the status, by default the 5 most recent
+	// failed requests and 5 successful ones are kept
+	// +optional
+	Sampling *SamplingSpec `json:"sampling,omitempty"`
+	// Export writes the summary, the requests and the assertions of a finished run to files
+	// +optional
+	Export *ExportSpec `json:"export,omitempty"`
+	// Record keeps every request sent so the run can be replayed
+	// +optional
+	Record *RecordSpec `json:"record,omitempty"`
+	// Notify posts the summary of the run to webhooks and emits an Event once it's finished
+	// +optional
+	Notify *NotifySpec `json:"notify,omitempty"`
+	// Replay sends the recording of another run rather than the routes, the rate, the concurrency and
+	// the profile are ignored
 	// +optional
-	Timeout      *metav1.Duration `json:"timeout"`
-	BetweenDelay metav1.Duration  `json:"betweenDelay"`
+	Replay *ReplaySpec `json:"replay,omitempty"`
+	// Profile changes the rate or the concurrency over time in stages, the load generator stops
+	// after the last stage. It takes precedence over Rate and Concurrency
+	// +optional
+	Profile *LoadProfile `json:"profile,omitempty"`
+}
+
+// AbortSpec are the conditions a load generator is stopped early at. They are checked by the controller
+// on the results of every worker together each time they report, about every 5 seconds
+type AbortSpec struct {
+	// MaxErrorRate is the percentage of requests failed within Window the load generator is stopped at
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	MaxErrorRate *int `json:"maxErrorRate,omitempty"`
+	// Window is how far back the error rate and the latency are calculated, up to 5 minutes
+	// +optional
+	// +kubebuilder:default="30s"
+	Window metav1.Duration `json:"window"`
+	// MaxLatency stops the load generator once the Percentile latency within Window is over it
+	// +optional
+	MaxLatency *metav1.Duration `json:"maxLatency,omitempty"`
+	// +optional
+	// +kubebuilder:default=p99
+	// +kubebuilder:validation:Enum=p50;p90;p95;p99
+	Percentile string `json:"percentile,omitempty"`
+	// MinRequests is the number of requests within Window needed before the conditions are checked
+	// +optional
+	// +kubebuilder:default=20
+	// +kubebuilder:validation:Minimum=0
+	MinRequests int `json:"minRequests"`
+}
+
+// ThinkTimeDistribution is how the think time between the steps of a journey is drawn
+// +kubebuilder:validation:Enum=Constant;Uniform;Exponential;Normal
+type ThinkTimeDistribution string
+
+const (
+	// ConstantThinkTime always waits Mean
+	ConstantThinkTime ThinkTimeDistribution = "Constant"
+	// UniformThinkTime waits between Min and Max
+	UniformThinkTime ThinkTimeDistribution = "Uniform"
+	// ExponentialThinkTime waits Mean on average with many short waits and a few long ones
+	ExponentialThinkTime ThinkTimeDistribution = "Exponential"
+	// NormalThinkTime waits Mean on average with StdDev
+	NormalThinkTime ThinkTimeDistribution = "Normal"
+)
+
+// ThinkTime is how long a virtual user waits after a step before sending the next one, the draws of
+// every distribution are kept between Min and Max when they are set
+type ThinkTime struct {
+	// +optional
+	// +kubebuilder:default=Constant
+	Distribution ThinkTimeDistribution `json:"distribution,omitempty"`
+	// +optional
+	Mean *metav1.Duration `json:"mean,omitempty"`
+	// +optional
+	StdDev *metav1.Duration `json:"stdDev,omitempty"`
+	// +optional
+	Min *metav1.Duration `json:"min,omitempty"`
+	// +optional
+	Max *metav1.Duration `json:"max,omitempty"`
 }
 
+// ScenarioStep is a request of a journey
+type ScenarioStep struct {
+	// Route is the name of the route template sent, from routes
+	// +kubebuilder:validation:MinLength=1
+	Route string `json:"route"`
+	// ThinkTime is the wait after the step, it replaces the one of the scenario
+	// +optional
+	ThinkTime *ThinkTime `json:"thinkTime,omitempty"`
+}
+
+// Scenario is a user journey made of ordered steps, every journey is a virtual user with a session of its own
+type Scenario struct {
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+	// +kubebuilder:validation:MinItems=1
+	Steps []ScenarioStep `json:"steps"`
+	// Weight is the relative share of the journeys through this scenario
+	// +optional
+	// +kubebuilder:default=1
+	// +kubebuilder:validation:Minimum=0
+	Weight int `json:"weight"`
+	// ThinkTime is the wait after every step but the last
+	// +optional
+	ThinkTime *ThinkTime `json:"thinkTime,omitempty"`
+	// SessionHeader is the header holding the session of the virtual user, it's the same on every step of a journey
+	// +optional
+	// +kubebuilder:default=X-Session-ID
+	SessionHeader string `json:"sessionHeader,omitempty"`
+}
+
+// WarmupSpec is the start of a run that is left out of its results, the warmup is over once both the
+// duration and the requests passed and, when it's set, the load reached a steady state. Every worker
+// warms up on its own with its share of the requests
+type WarmupSpec struct {
+	// Duration is how long the warmup lasts at least
+	// +optional
+	Duration *metav1.Duration `json:"duration,omitempty"`
+	// Requests is the number of requests the warmup lasts at least, they count towards requestCount
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	Requests *int `json:"requests,omitempty"`
+	// SteadyState keeps the warmup going until the throughput and the latency are stable
+	// +optional
+	SteadyState *SteadyStateSpec `json:"steadyState,omitempty"`
+}
+
+// SteadyStateSpec is when the load is stable, the last Window is compared with the one before it
+type SteadyStateSpec struct {
+	// Window is how long the throughput and the latency are compared over, up to 2.5 minutes
+	// +optional
+	// +kubebuilder:default="30s"
+	Window metav1.Duration `json:"window"`
+	// MaxThroughputChange is the percentage the throughput can change by between the windows
+	// +optional
+	// +kubebuilder:default=10
+	// +kubebuilder:validation:Minimum=0
+	MaxThroughputChange int `json:"maxThroughputChange"`
+	// MaxLatencyChange is the percentage the mean latency can change by between the windows
+	// +optional
+	// +kubebuilder:default=10
+	// +kubebuilder:validation:Minimum=0
+	MaxLatencyChange int `json:"maxLatencyChange"`
+	// Timeout ends the warmup when the load doesn't reach a steady state within it, counted from the start of the run
+	// +optional
+	// +kubebuilder:default="5m"
+	Timeout metav1.Duration `json:"timeout"`
+}
+
+const (
+	// WarmupPassed ends the warmup once its duration and requests passed
+	WarmupPassed = "Passed"
+	// WarmupSteadyState ends the warmup once the load reached a steady state
+	WarmupSteadyState = "SteadyState"
+	// WarmupTimeout ends the warmup when the load didn't reach a steady state in time
+	WarmupTimeout = "Timeout"
+)
+
+// Assertion is a check on the results of a run, every limit that is set has to hold for it to pass
+type Assertion struct {
+	// Name identifies the assertion in the status, defaults to a description of the check
+	// +optional
+	Name string `json:"name,omitempty"`
+	// Service scopes MaxErrorRate, MaxLatency and MaxErrors to the calls of a service, like service_1 or
+	// service_1@v2. A service without a version includes all of its versions
+	// +optional
+	Service string `json:"service,omitempty"`
+	// MaxErrorRate is the highest percentage of failed requests over the run
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	MaxErrorRate *int `json:"maxErrorRate,omitempty"`
+	// MaxLatency is the highest Percentile latency over the run, services only have a latency when they report it
+	// +optional
+	MaxLatency *metav1.Duration `json:"maxLatency,omitempty"`
+	// +optional
+	// +kubebuilder:default=p99
+	// +kubebuilder:validation:Enum=p50;p90;p95;p99
+	Percentile string `json:"percentile,omitempty"`
+	// MaxErrors is the highest number of failed requests over the run, 0 allows none
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	MaxErrors *int `json:"maxErrors,omitempty"`
+	// Called is a designation that has to show up in the response trees at least once, like service_3
+	// +optional
+	Called string `json:"called,omitempty"`
+}
+
+// LoadShape is how the load moves towards the target of a stage
+// +kubebuilder:validation:Enum=Step;Ramp;Spike;Sine
+type LoadShape string
+
+const (
+	// StepShape jumps to the target at the start of the stage
+	StepShape LoadShape = "Step"
+	// RampShape moves linearly from the previous stage to the target
+	RampShape LoadShape = "Ramp"
+	// SpikeShape rises to the target at the middle of the stage and falls back to the previous stage by its end
+	SpikeShape LoadShape = "Spike"
+	// SineShape oscillates around the target by the amplitude
+	SineShape LoadShape = "Sine"
+)
+
+// LoadProfile is a sequence of stages, either every stage sets a rate or every stage sets a concurrency
+type LoadProfile struct {
+	// +kubebuilder:validation:MinItems=1
+	Stages []LoadStage `json:"stages"`
+}
+
+type LoadStage struct {
+	// Name is reported in the status while the stage is running, defaults to stage-<index>
+	// +optional
+	Name     string          `json:"name,omitempty"`
+	Duration metav1.Duration `json:"duration"`
+	// +optional
+	// +kubebuilder:default=Step
+	Shape LoadShape `json:"shape,omitempty"`
+	// Rate is the number of requests started per second at the target of the stage
+	// +optional
+	Rate *resource.Quantity `json:"rate,omitempty"`
+	// Concurrency is the number of users at the target of the stage
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	Concurrency *int `json:"concurrency,omitempty"`
+	// Amplitude of a sine stage, in requests per second or users
+	// +optional
+	Amplitude *resource.Quantity `json:"amplitude,omitempty"`
+	// Period of a sine stage, defaults to the duration of the stage
+	// +optional
+	Period *metav1.Duration `json:"period,omitempty"`
+}
+
+// LatencyStats are percentiles of the latency of the requests, measured from when they were scheduled to start
+type LatencyStats struct {
+	P50  metav1.Duration `json:"p50"`
+	P90  metav1.Duration `json:"p90"`
+	P95  metav1.Duration `json:"p95"`
+	P99  metav1.Duration `json:"p99"`
+	Max  metav1.Duration `json:"max"`
+	Mean metav1.Duration `json:"mean"`
+}
+
+// TemplateStats are the statistics of the requests sent with a route template
+type TemplateStats struct {
+	Requests int `json:"requests"`
+	Errors   int `json:"errors"`
+	// +optional
+	Latency *LatencyStats `json:"latency,omitempty"`
+}
+
+// AssertionResult is the outcome of an assertion
+type AssertionResult struct {
+	Name   string `json:"name"`
+	Passed bool   `json:"passed"`
+	// Message holds the values the assertion was checked against
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// ShardStatus is the checkpoint of a worker, the full statistics are kept in the report ConfigMap of
+// the shard which a restarted worker resumes from
+type ShardStatus struct {
+	Shard        int `json:"shard"`
+	DoneRequests int `json:"doneRequests"`
+	// Elapsed is how long the shard has been sending requests, the profile is resumed from it
+	Elapsed metav1.Duration `json:"elapsed"`
+	// +optional
+	Stage string `json:"stage,omitempty"`
+	// Resumes is how many times the worker was restarted from the checkpoint
+	// +optional
+	Resumes int `json:"resumes,omitempty"`
+	// WarmupEndedBy is why the warmup of the shard is over, one of Passed, SteadyState or Timeout. It's empty
+	// while the shard is warming up or without a warmup
+	// +optional
+	WarmupEndedBy string `json:"warmupEndedBy,omitempty"`
+	// Reason is why the shard stopped, it's empty while the shard is running
+	// +optional
+	Reason     string      `json:"reason,omitempty"`
+	LastReport metav1.Time `json:"lastReport"`
+}
+
+// ScenarioStats are the statistics of the journeys through a scenario, a journey failed when any of its requests did
+type ScenarioStats struct {
+	Journeys int `json:"journeys"`
+	Failed   int `json:"failed"`
+	// Duration is how long the journeys took, think time included
+	// +optional
+	Duration *LatencyStats `json:"duration,omitempty"`
+}
+
+// RecordingStatus is how much of the run was recorded
+type RecordingStatus struct {
+	Requests int `json:"requests"`
+	// Dropped is the number of requests that didn't fit in the recording
+	// +optional
+	Dropped int `json:"dropped,omitempty"`
+	// RecordedTo are the ConfigMaps or the file the requests are recorded in
+	RecordedTo string `json:"recordedTo"`
+}
+
+// WarmupStatus are the statistics of the requests of the warmup
+type WarmupStatus struct {
+	Requests int `json:"requests"`
+	Failed   int `json:"failed"`
+	// +optional
+	Latency *LatencyStats `json:"latency,omitempty"`
+	// Duration is how long the longest warmup of the workers lasted so far
+	Duration metav1.Duration `json:"duration"`
+	// Warming is the number of workers still warming up
+	Warming int `json:"warming"`
+}
+
+// ServiceCallStats are the statistics of the calls to a service, parsed from the response trees
+type ServiceCallStats struct {
+	Calls int `json:"calls"`
+	// Errors is the number of calls that reported an error or didn't respond
+	Errors int `json:"errors"`
+	// NotCalled is the number of calls left out by the probability of the hop, they aren't errors
+	// +optional
+	NotCalled int `json:"notCalled,omitempty"`
+	// ErrorMessages counts the distinct error messages, up to 10 of them
+	// +optional
+	ErrorMessages map[string]int `json:"errorMessages,omitempty"`
+	// Latency is the time the service took to respond, only set for services that report it
+	// +optional
+	Latency *LatencyStats `json:"latency,omitempty"`
+}
+
+const (
+	// ConditionFinished is true once the load generator stopped sending requests on its own
+	ConditionFinished = "Finished"
+
+	// ReasonRunning is the reason of the Finished condition while requests are sent
+	ReasonRunning = "Running"
+	// ReasonCompleted is set when the request count was met or the profile was over
+	ReasonCompleted = "Completed"
+	// ReasonTimeout is set when the timeout was reached
+	ReasonTimeout = "Timeout"
+	// ReasonErrorRateExceeded is set when the error rate went over abort.maxErrorRate
+	ReasonErrorRateExceeded = "ErrorRateExceeded"
+	// ReasonLatencyExceeded is set when the latency went over abort.maxLatency
+	ReasonLatencyExceeded = "LatencyExceeded"
+	// ReasonWorkerFailed is set when a worker pod kept failing before its shard was done
+	ReasonWorkerFailed = "WorkerFailed"
+
+	// ConditionExported is whether a finished run wrote its spilled samples, recording and exported results
+	// where the spec asks for them, it's only set when the spec asks for any
+	ConditionExported = "Exported"
+
+	// ReasonResultsWritten is set when everything was written
+	ReasonResultsWritten = "ResultsWritten"
+	// ReasonExportFailed is set when anything couldn't be written, the message says what and why
+	ReasonExportFailed = "ExportFailed"
+
+	// ConditionPassed is the verdict of the assertions, it's unknown until the run is finished
+	ConditionPassed = "Passed"
+
+	// ReasonAssertionsPassed is set when every assertion held at the end of the run
+	ReasonAssertionsPassed = "AssertionsPassed"
+	// ReasonAssertionsFailed is set when an assertion didn't hold at the end of the run
+	ReasonAssertionsFailed = "AssertionsFailed"
+	// ReasonAssertionsFailing is set while the run is going and an assertion doesn't hold so far
+	ReasonAssertionsFailing = "AssertionsFailing"
+)
+
 // LoadGeneratorStatus defines the observed state of LoadGenerator
 type LoadGeneratorStatus struct {
+	// DoneRequests is the number of finished requests, the ones of the warmup aren't counted
 	// +kubebuilder:validation:Minimum=0
 	// +kubebuilder:default=0
-	DoneRequests      int                  `json:"doneRequests"`
-	Responses         map[string]Responses `json:"responses"`
-	TotalResponseTime metav1.Duration      `json:"totalResponseTime"`
-	Replicas          int                  `json:"replicas"`
+	DoneRequests int `json:"doneRequests"`
+	// Samples are a bounded set of the requests sent with their responses
+	// +optional
+	Samples           *Samples        `json:"samples,omitempty"`
+	TotalResponseTime metav1.Duration `json:"totalResponseTime"`
+	// Replicas is the number of worker pods still sending requests
+	Replicas int `json:"replicas"`
+	// Paused is set while the referenced simulation is suspended
+	// +optional
+	Paused bool `json:"paused,omitempty"`
+	// PausedAt is when the current pause started and PausedFor how long the earlier pauses of the run lasted
+	// +optional
+	PausedAt *metav1.Time `json:"pausedAt,omitempty"`
+	// +optional
+	PausedFor metav1.Duration `json:"pausedFor,omitempty"`
+	// StartTime is when the load generator started sending requests
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+	// Seed is the seed of the current run, set it in the spec to run again with the same routes
+	// +optional
+	Seed *int64 `json:"seed,omitempty"`
+	// Conditions hold the Finished condition, its reason is why the load generator stopped, and the
+	// Passed condition when the spec has assertions
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	// CurrentStage is the name of the profile stage being run
+	// +optional
+	CurrentStage string `json:"currentStage,omitempty"`
+	// SucceededRequests and FailedRequests are counted over the current run
+	// +optional
+	SucceededRequests int `json:"succeededRequests,omitempty"`
+	// +optional
+	FailedRequests int `json:"failedRequests,omitempty"`
+	// StatusClasses counts the responses by the class of their status code, like 2xx
+	// +optional
+	StatusClasses map[string]int `json:"statusClasses,omitempty"`
+	// Errors counts the failed requests by type, one of Timeout, Connection, Status or Other
+	// +optional
+	Errors map[string]int `json:"errors,omitempty"`
+	// +optional
+	Latency *LatencyStats `json:"latency,omitempty"`
+	// Services breaks the calls down by the designation in the route template, designations of
+	// services with versions include the version that was called, like service_1@v2
+	// +optional
+	Services map[string]ServiceCallStats `json:"services,omitempty"`
+	// Templates breaks the requests down by the name of their route template
+	// +optional
+	Templates map[string]TemplateStats `json:"templates,omitempty"`
+	// Scenarios breaks the finished journeys down by scenario
+	// +optional
+	Scenarios map[string]ScenarioStats `json:"scenarios,omitempty"`
+	// Throughput is the number of requests finished per second over the last 10 seconds
+	// +optional
+	Throughput *resource.Quantity `json:"throughput,omitempty"`
+	// Summary is a one line form of the statistics above
+	// +optional
+	Summary string `json:"summary,omitempty"`
+	// Warmup are the statistics of the warmup, the rest of the status leaves it out
+	// +optional
+	Warmup *WarmupStatus `json:"warmup,omitempty"`
+	// Assertions are the results of the assertions of the spec, they are updated while the run is going
+	// +optional
+	Assertions []AssertionResult `json:"assertions,omitempty"`
+	// ExportedTo is the ConfigMap or the directory the results of the run were exported to
+	// +optional
+	ExportedTo string `json:"exportedTo,omitempty"`
+	// Recording is set when the spec records the run
+	// +optional
+	Recording *RecordingStatus `json:"recording,omitempty"`
+	// Notifications are the deliveries of the summary of the finished run to the webhooks
+	// +optional
+	Notifications []NotificationStatus `json:"notifications,omitempty"`
+	// Shards are the checkpoints of the workers of the current run
+	// +optional
+	Shards []ShardStatus `json:"shards,omitempty"`
 }
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
 //+kubebuilder:subresource:scale:specpath=.spec.replicas,statuspath=.status.replicas
+//+kubebuilder:printcolumn:name="Done",type=integer,JSONPath=`.status.doneRequests`
+//+kubebuilder:printcolumn:name="Stage",type=string,JSONPath=`.status.currentStage`
+//+kubebuilder:printcolumn:name="Summary",type=string,JSONPath=`.status.summary`
+//+kubebuilder:printcolumn:name="Result",type=string,JSONPath=`.status.conditions[?(@.type=="Finished")].reason`
+//+kubebuilder:printcolumn:name="Passed",type=string,JSONPath=`.status.conditions[?(@.type=="Passed")].status`
 
 // LoadGenerator is the Schema for the loadgenerators API
 type LoadGenerator struct {
@@ -0,0 +1,148 @@
+package v1alpha1
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// MaxRouteDepth is the deepest request tree a RouteTemplate can expand to
+const MaxRouteDepth = 16
+
+// RouteTemplates returns the typed route templates of the spec followed by the
+// legacy raw JSON requests converted to templates, typed templates that don't expand
+// to a request tree are an error
+func (s LoadGeneratorSpec) RouteTemplates() ([]RouteTemplate, error) {
+	templates := make([]RouteTemplate, 0, len(s.Routes)+len(s.Requests))
+	for _, template := range s.Routes {
+		if _, err := template.Route(); err != nil {
+			return nil, err
+		}
+		templates = append(templates, template)
+	}
+	for i, request := range s.Requests {
+		var route Route
+		if err := json.Unmarshal([]byte(request), &route); err != nil {
+			return nil, fmt.Errorf("failed to decode request #%d: %w", i, err)
+		}
+		templates = append(templates, RouteTemplateFromRoute(fmt.Sprintf("request-%d", i), route))
+	}
+	return templates, nil
+}
+
+// Route expands the template to the request tree sent to the services, starting at its first hop.
+// Every hop has to be reachable from the first one and route to hops of the template only
+func (t RouteTemplate) Route() (Route, error) {
+	if len(t.Hops) == 0 {
+		return Route{}, fmt.Errorf("route template %s has no hops", t.Name)
+	}
+
+	hops := make(map[string]Hop, len(t.Hops))
+	for _, hop := range t.Hops {
+		name := hop.key()
+		if _, ok := hops[name]; ok {
+			return Route{}, fmt.Errorf("route template %s has more than one hop named %s", t.Name, name)
+		}
+		hops[name] = hop
+	}
+	for _, hop := range t.Hops {
+		for _, name := range hop.Routes {
+			if _, ok := hops[name]; !ok {
+				return Route{}, fmt.Errorf("hop %s of route template %s routes to unknown hop %s", hop.key(), t.Name, name)
+			}
+		}
+	}
+
+	reached := make(map[string]bool, len(t.Hops))
+	route, err := t.expand(hops, t.Hops[0], 1, reached)
+	if err != nil {
+		return Route{}, err
+	}
+	var unreachable []string
+	for _, hop := range t.Hops {
+		if !reached[hop.key()] {
+			unreachable = append(unreachable, hop.key())
+		}
+	}
+	if len(unreachable) > 0 {
+		return Route{}, fmt.Errorf("route template %s has hops that can't be reached from %s: %s",
+			t.Name, t.Hops[0].key(), strings.Join(unreachable, ", "))
+	}
+	return route, nil
+}
+
+func (t RouteTemplate) expand(hops map[string]Hop, hop Hop, depth int, reached map[string]bool) (Route, error) {
+	if depth > MaxRouteDepth {
+		return Route{}, fmt.Errorf("route template %s is deeper than %d hops, check for cycles", t.Name, MaxRouteDepth)
+	}
+
+	reached[hop.key()] = true
+
+	route := Route{
+		Designation: hop.Designation,
+		Probability: 100,
+		Faults:      hop.Faults.normalize(),
+	}
+	if hop.Probability != nil {
+		route.Probability = *hop.Probability
+	}
+	for _, name := range hop.Routes {
+		child, ok := hops[name]
+		if !ok {
+			return Route{}, fmt.Errorf("route template %s refers to unknown hop %s", t.Name, name)
+		}
+		childRoute, err := t.expand(hops, child, depth+1, reached)
+		if err != nil {
+			return Route{}, err
+		}
+		route.Routes = append(route.Routes, childRoute)
+	}
+	return route, nil
+}
+
+// RouteTemplateFromRoute flattens a request tree into a template, hops calling the same
+// designation more than once are suffixed with a counter
+func RouteTemplateFromRoute(name string, route Route) RouteTemplate {
+	template := RouteTemplate{Name: name, Weight: 1}
+	template.flatten(route, map[string]bool{})
+	return template
+}
+
+func (t *RouteTemplate) flatten(route Route, used map[string]bool) string {
+	name := route.Designation
+	for n := 1; used[name]; n++ {
+		name = fmt.Sprintf("%s-%d", route.Designation, n)
+	}
+	used[name] = true
+
+	probability := route.Probability
+	t.Hops = append(t.Hops, Hop{
+		Name:        name,
+		Designation: route.Designation,
+		Probability: &probability,
+		Faults:      route.Faults,
+	})
+	i := len(t.Hops) - 1
+	for _, child := range route.Routes {
+		t.Hops[i].Routes = append(t.Hops[i].Routes, t.flatten(child, used))
+	}
+	return name
+}
+
+func (h Hop) key() string {
+	if h.Name != "" {
+		return h.Name
+	}
+	return h.Designation
+}
+
+// normalize replaces nil fault lists with empty ones since some services reject null lists
+func (f Faults) normalize() Faults {
+	if f.Before == nil {
+		f.Before = []Fault{}
+	}
+	if f.After == nil {
+		f.After = []Fault{}
+	}
+	return f
+}
@@ -0,0 +1,191 @@
+package v1alpha1
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func TestRouteTemplatesRoute(t *testing.T) {
+	none := Faults{Before: []Fault{}, After: []Fault{}}
+	tests := []struct {
+		name     string
+		template RouteTemplate
+		want     Route
+		err      string
+	}{
+		{
+			name:     "single hop",
+			template: RouteTemplate{Name: "t", Hops: []Hop{{Designation: "a"}}},
+			want:     Route{Designation: "a", Probability: 100, Faults: none},
+		},
+		{
+			name: "tree by name and designation",
+			template: RouteTemplate{Name: "t", Hops: []Hop{
+				{Designation: "a", Routes: []string{"b", "c-1"}},
+				{Designation: "b", Probability: intPtr(50)},
+				{Name: "c-1", Designation: "c", Routes: []string{"b-2"}},
+				{Name: "b-2", Designation: "b"},
+			}},
+			want: Route{Designation: "a", Probability: 100, Faults: none, Routes: []Route{
+				{Designation: "b", Probability: 50, Faults: none},
+				{Designation: "c", Probability: 100, Faults: none, Routes: []Route{
+					{Designation: "b", Probability: 100, Faults: none},
+				}},
+			}},
+		},
+		{
+			name:     "no hops",
+			template: RouteTemplate{Name: "t"},
+			err:      "has no hops",
+		},
+		{
+			name:     "duplicate hop",
+			template: RouteTemplate{Name: "t", Hops: []Hop{{Designation: "a"}, {Designation: "a"}}},
+			err:      "more than one hop named a",
+		},
+		{
+			name:     "unknown hop",
+			template: RouteTemplate{Name: "t", Hops: []Hop{{Designation: "a", Routes: []string{"b"}}}},
+			err:      "hop a of route template t routes to unknown hop b",
+		},
+		{
+			name: "unknown hop of an unreachable hop",
+			template: RouteTemplate{Name: "t", Hops: []Hop{
+				{Designation: "a"},
+				{Designation: "b", Routes: []string{"c"}},
+			}},
+			err: "routes to unknown hop c",
+		},
+		{
+			name: "unreachable hops",
+			template: RouteTemplate{Name: "t", Hops: []Hop{
+				{Designation: "a", Routes: []string{"b"}},
+				{Designation: "b"},
+				{Designation: "c"},
+				{Designation: "d", Routes: []string{"c"}},
+			}},
+			err: "can't be reached from a: c, d",
+		},
+		{
+			name: "cycle",
+			template: RouteTemplate{Name: "t", Hops: []Hop{
+				{Designation: "a", Routes: []string{"b"}},
+				{Designation: "b", Routes: []string{"a"}},
+			}},
+			err: "deeper than 16 hops",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.template.Route()
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("Route() error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Route() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Route() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRouteTemplates(t *testing.T) {
+	tests := []struct {
+		name  string
+		spec  LoadGeneratorSpec
+		names []string
+		err   string
+	}{
+		{
+			name: "typed routes before requests",
+			spec: LoadGeneratorSpec{
+				Routes:   []RouteTemplate{{Name: "browse", Hops: []Hop{{Designation: "a"}}}},
+				Requests: []string{`{"designation":"b","probability":100,"routes":[{"designation":"c","probability":30}]}`},
+			},
+			names: []string{"browse", "request-0"},
+		},
+		{
+			name: "invalid request",
+			spec: LoadGeneratorSpec{Requests: []string{`{`}},
+			err:  "failed to decode request #0",
+		},
+		{
+			name: "invalid route template",
+			spec: LoadGeneratorSpec{Routes: []RouteTemplate{{Name: "browse", Hops: []Hop{{Designation: "a"}, {Designation: "b"}}}}},
+			err:  "can't be reached",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			templates, err := tt.spec.RouteTemplates()
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("RouteTemplates() error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("RouteTemplates() error = %v", err)
+			}
+			var names []string
+			for _, template := range templates {
+				names = append(names, template.Name)
+			}
+			if !reflect.DeepEqual(names, tt.names) {
+				t.Errorf("RouteTemplates() names = %v, want %v", names, tt.names)
+			}
+		})
+	}
+}
+
+func TestRouteTemplateFromRoute(t *testing.T) {
+	tests := []struct {
+		name  string
+		route Route
+	}{
+		{
+			name:  "single service",
+			route: Route{Designation: "a", Probability: 100},
+		},
+		{
+			name: "repeated designations",
+			route: Route{Designation: "a", Probability: 100, Routes: []Route{
+				{Designation: "b", Probability: 40, Routes: []Route{{Designation: "a", Probability: 100}}},
+				{Designation: "b", Probability: 100},
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			template := RouteTemplateFromRoute("request-0", tt.route)
+			got, err := template.Route()
+			if err != nil {
+				t.Fatalf("Route() error = %v", err)
+			}
+			if want := normalizeRoute(tt.route); !reflect.DeepEqual(got, want) {
+				t.Errorf("round trip = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+// normalizeRoute fills in the empty fault lists Route adds
+func normalizeRoute(route Route) Route {
+	route.Faults = route.Faults.normalize()
+	routes := route.Routes
+	route.Routes = nil
+	for _, child := range routes {
+		route.Routes = append(route.Routes, normalizeRoute(child))
+	}
+	return route
+}
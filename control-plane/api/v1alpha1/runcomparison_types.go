@@ -0,0 +1,186 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// LoadGeneratorRef points to a LoadGenerator, the namespace of the referring object is used when it's empty
+type LoadGeneratorRef struct {
+	Name string `json:"name"`
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// NamespacedName returns the key of the load generator, namespace is used when the reference has none
+func (in LoadGeneratorRef) NamespacedName(namespace string) types.NamespacedName {
+	if in.Namespace != "" {
+		namespace = in.Namespace
+	}
+	return types.NamespacedName{Namespace: namespace, Name: in.Name}
+}
+
+// RunComparisonSpec defines the desired state of RunComparison
+type RunComparisonSpec struct {
+	// Baseline is the run the candidate is compared against, like the one with the current framework
+	Baseline LoadGeneratorRef `json:"baseline"`
+	// Candidate is the run with the change, like a new framework or fault profile
+	Candidate LoadGeneratorRef `json:"candidate"`
+	// Confidence is the confidence level in percent a difference has to reach to be significant
+	// +optional
+	// +kubebuilder:default=95
+	// +kubebuilder:validation:Minimum=50
+	// +kubebuilder:validation:Maximum=99
+	Confidence int `json:"confidence,omitempty"`
+}
+
+// RunReference is the run of a load generator that was compared
+type RunReference struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	// Generation of the load generator the run belongs to
+	Generation int64 `json:"generation"`
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+	// +optional
+	Seed *int64 `json:"seed,omitempty"`
+	// Reason is the reason of the Finished condition of the run
+	Reason   string `json:"reason"`
+	Requests int    `json:"requests"`
+}
+
+// DurationDelta is a latency of the baseline and the candidate
+type DurationDelta struct {
+	Baseline  metav1.Duration `json:"baseline"`
+	Candidate metav1.Duration `json:"candidate"`
+	// Change is the relative change from the baseline, like +12.5%
+	Change string `json:"change"`
+}
+
+// LatencyComparison compares the latency percentiles of two runs, the significance is tested on the whole
+// distribution with a Mann-Whitney U test
+type LatencyComparison struct {
+	P50  DurationDelta `json:"p50"`
+	P90  DurationDelta `json:"p90"`
+	P95  DurationDelta `json:"p95"`
+	P99  DurationDelta `json:"p99"`
+	Mean DurationDelta `json:"mean"`
+	// PValue is the probability of a difference at least this large when the latencies are the same
+	PValue string `json:"pValue"`
+	// Significant is set when PValue is under the significance level of the confidence
+	Significant bool `json:"significant"`
+	// Slower is set when the candidate tends to be slower than the baseline
+	Slower bool `json:"slower"`
+}
+
+// RateComparison compares the error rates of two runs with a two proportion z-test
+type RateComparison struct {
+	// Baseline and Candidate are percentages, like 1.25%
+	Baseline  string `json:"baseline"`
+	Candidate string `json:"candidate"`
+	// Change is the difference in percentage points, like +0.50pp
+	Change      string `json:"change"`
+	PValue      string `json:"pValue"`
+	Significant bool   `json:"significant"`
+	// Higher is set when the candidate fails more often than the baseline
+	Higher bool `json:"higher"`
+}
+
+// ServiceComparison compares the calls to a service, keyed by the designation in the route template
+type ServiceComparison struct {
+	BaselineCalls  int `json:"baselineCalls"`
+	CandidateCalls int `json:"candidateCalls"`
+	// +optional
+	ErrorRate *RateComparison `json:"errorRate,omitempty"`
+	// Latency is only set when the service reported its durations in both runs
+	// +optional
+	Latency *LatencyComparison `json:"latency,omitempty"`
+}
+
+const (
+	// ConditionCompared is true once both runs finished and were compared
+	ConditionCompared = "Compared"
+	// ReasonWaitingForRuns is set while a run is missing or still going
+	ReasonWaitingForRuns = "WaitingForRuns"
+	// ReasonRunsCompared is set once the comparison is in the status
+	ReasonRunsCompared = "RunsCompared"
+	// ReasonReportsMissing is set when a finished run doesn't have the reports of all its workers,
+	// the statistics of the run can't be compared without them
+	ReasonReportsMissing = "ReportsMissing"
+
+	// ConditionRegressed is true when the candidate is significantly slower or fails more often than the baseline
+	ConditionRegressed = "Regressed"
+	// ReasonLatencyRegressed is set when the candidate is significantly slower
+	ReasonLatencyRegressed = "LatencyRegressed"
+	// ReasonErrorRateRegressed is set when the candidate fails significantly more often
+	ReasonErrorRateRegressed = "ErrorRateRegressed"
+	// ReasonNoRegression is set when neither got significantly worse
+	ReasonNoRegression = "NoRegression"
+)
+
+// RunComparisonStatus defines the observed state of RunComparison
+type RunComparisonStatus struct {
+	// Baseline and Candidate are the runs that were compared
+	// +optional
+	Baseline *RunReference `json:"baseline,omitempty"`
+	// +optional
+	Candidate *RunReference `json:"candidate,omitempty"`
+	// +optional
+	Latency *LatencyComparison `json:"latency,omitempty"`
+	// +optional
+	ErrorRate *RateComparison `json:"errorRate,omitempty"`
+	// Services compares the calls to the services found in either run
+	// +optional
+	Services map[string]ServiceComparison `json:"services,omitempty"`
+	// Summary is a one line form of the comparison
+	// +optional
+	Summary string `json:"summary,omitempty"`
+	// Conditions hold the Compared and the Regressed conditions
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Baseline",type=string,JSONPath=`.spec.baseline.name`
+//+kubebuilder:printcolumn:name="Candidate",type=string,JSONPath=`.spec.candidate.name`
+//+kubebuilder:printcolumn:name="Regressed",type=string,JSONPath=`.status.conditions[?(@.type=="Regressed")].status`
+//+kubebuilder:printcolumn:name="Summary",type=string,JSONPath=`.status.summary`
+
+// RunComparison is the Schema for the runcomparisons API
+type RunComparison struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   RunComparisonSpec   `json:"spec,omitempty"`
+	Status RunComparisonStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// RunComparisonList contains a list of RunComparison
+type RunComparisonList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []RunComparison `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&RunComparison{}, &RunComparisonList{})
+}
@@ -0,0 +1,106 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ConcurrencyPolicy is what happens when a run is due while an earlier one is still going
+// +kubebuilder:validation:Enum=Allow;Forbid;Replace
+type ConcurrencyPolicy string
+
+const (
+	// AllowConcurrent starts the run alongside the earlier ones
+	AllowConcurrent ConcurrencyPolicy = "Allow"
+	// ForbidConcurrent skips the run while an earlier one is still going
+	ForbidConcurrent ConcurrencyPolicy = "Forbid"
+	// ReplaceConcurrent deletes the runs still going and starts the new one
+	ReplaceConcurrent ConcurrencyPolicy = "Replace"
+)
+
+// ScheduledLoadGeneratorSpec defines the desired state of ScheduledLoadGenerator
+type ScheduledLoadGeneratorSpec struct {
+	// Schedule is when the runs start in cron format, like "0 2 * * *" for every night at 2am
+	// +kubebuilder:validation:MinLength=1
+	Schedule string `json:"schedule"`
+	// StartingDeadlineSeconds is how late a run can start after its scheduled time, missed runs are skipped
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	StartingDeadlineSeconds *int64 `json:"startingDeadlineSeconds,omitempty"`
+	// ConcurrencyPolicy is what happens when a run is due while an earlier one is still going
+	// +optional
+	// +kubebuilder:default=Forbid
+	ConcurrencyPolicy ConcurrencyPolicy `json:"concurrencyPolicy,omitempty"`
+	// Suspend stops new runs from starting, runs that already started keep going
+	// +optional
+	Suspend bool `json:"suspend,omitempty"`
+	// Template is the spec of the LoadGenerator created for every run
+	Template LoadGeneratorSpec `json:"template"`
+	// SuccessfulRunsHistoryLimit is the number of passed runs kept
+	// +optional
+	// +kubebuilder:default=3
+	// +kubebuilder:validation:Minimum=0
+	SuccessfulRunsHistoryLimit *int32 `json:"successfulRunsHistoryLimit,omitempty"`
+	// FailedRunsHistoryLimit is the number of failed runs kept, a run fails when it didn't complete or its assertions failed
+	// +optional
+	// +kubebuilder:default=1
+	// +kubebuilder:validation:Minimum=0
+	FailedRunsHistoryLimit *int32 `json:"failedRunsHistoryLimit,omitempty"`
+}
+
+// ScheduledLoadGeneratorStatus defines the observed state of ScheduledLoadGenerator
+type ScheduledLoadGeneratorStatus struct {
+	// Active are the runs still going
+	// +optional
+	Active []v1.ObjectReference `json:"active,omitempty"`
+	// LastScheduleTime is when the last run was scheduled
+	// +optional
+	LastScheduleTime *metav1.Time `json:"lastScheduleTime,omitempty"`
+	// LastSuccessfulTime is when the last passed run finished
+	// +optional
+	LastSuccessfulTime *metav1.Time `json:"lastSuccessfulTime,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Schedule",type=string,JSONPath=`.spec.schedule`
+//+kubebuilder:printcolumn:name="Suspend",type=boolean,JSONPath=`.spec.suspend`
+//+kubebuilder:printcolumn:name="Last Schedule",type=date,JSONPath=`.status.lastScheduleTime`
+
+// ScheduledLoadGenerator is the Schema for the scheduledloadgenerators API
+type ScheduledLoadGenerator struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ScheduledLoadGeneratorSpec   `json:"spec,omitempty"`
+	Status ScheduledLoadGeneratorStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ScheduledLoadGeneratorList contains a list of ScheduledLoadGenerator
+type ScheduledLoadGeneratorList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ScheduledLoadGenerator `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ScheduledLoadGenerator{}, &ScheduledLoadGeneratorList{})
+}
@@ -0,0 +1,71 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ServiceFrameworkSpec defines the image and runtime defaults of a service framework
+type ServiceFrameworkSpec struct {
+	Language string `json:"language"`
+	// Image of the service, it must serve the MicroSim request schema on Port
+	// +kubebuilder:validation:MinLength=1
+	Image string `json:"image"`
+	// +optional
+	// +kubebuilder:default=8080
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	Port int32 `json:"port,omitempty"`
+	// SupportedFaults are the fault types the service can execute
+	// +optional
+	SupportedFaults []string `json:"supportedFaults,omitempty"`
+	// Env is added to every service using this framework
+	// +optional
+	Env []v1.EnvVar `json:"env,omitempty"`
+	// +optional
+	ReadinessProbe *v1.Probe `json:"readinessProbe,omitempty"`
+	// +optional
+	LivenessProbe *v1.Probe `json:"livenessProbe,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:scope=Cluster
+//+kubebuilder:printcolumn:name="Language",type=string,JSONPath=`.spec.language`
+//+kubebuilder:printcolumn:name="Image",type=string,JSONPath=`.spec.image`
+
+// ServiceFramework is the Schema for the serviceframeworks API
+type ServiceFramework struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec ServiceFrameworkSpec `json:"spec,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ServiceFrameworkList contains a list of ServiceFramework
+type ServiceFrameworkList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ServiceFramework `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ServiceFramework{}, &ServiceFrameworkList{})
+}
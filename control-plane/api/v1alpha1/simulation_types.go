@@ -17,6 +17,11 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"fmt"
+	"strings"
+
+	autoscalingv2beta2 "k8s.io/api/autoscaling/v2beta2"
+	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -24,28 +29,123 @@ import (
 // NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
 
 type ServiceSpec struct {
+	// FrameworkRef is the name of the ServiceFramework used for this service, when it's
+	// not set the image is taken from the default registry using Language and Framework
+	// +optional
+	FrameworkRef string `json:"frameworkRef,omitempty"`
+	// +optional
+	Language string `json:"language,omitempty"`
+	// +optional
+	Framework string `json:"framework,omitempty"`
+	// Versions run side by side behind the shared service, each one is deployed separately
+	// and can be called directly with the <service>@<version> designation
+	// +optional
+	Versions []ServiceVersion `json:"versions,omitempty"`
+	// Resources of the service container, CPU and memory requests are required for utilization targets
+	// +optional
+	Resources v1.ResourceRequirements `json:"resources,omitempty"`
+	// Autoscaling adds a HorizontalPodAutoscaler to each Deployment of the service
+	// +optional
+	Autoscaling *AutoscalingSpec `json:"autoscaling,omitempty"`
+}
+
+type AutoscalingSpec struct {
+	// +optional
+	// +kubebuilder:default=1
+	// +kubebuilder:validation:Minimum=1
+	MinReplicas *int32 `json:"minReplicas,omitempty"`
+	// +kubebuilder:validation:Minimum=1
+	MaxReplicas int32 `json:"maxReplicas"`
+	// TargetCPUUtilization is the average CPU utilization of the pods as a percentage of the requested CPU
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	TargetCPUUtilization *int32 `json:"targetCPUUtilization,omitempty"`
+	// TargetMemoryUtilization is the average memory utilization of the pods as a percentage of the requested memory
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	TargetMemoryUtilization *int32 `json:"targetMemoryUtilization,omitempty"`
+	// Metrics are added to the HorizontalPodAutoscaler as is, use them for custom metric targets
+	// +optional
+	Metrics []autoscalingv2beta2.MetricSpec `json:"metrics,omitempty"`
+	// +optional
+	Behavior *autoscalingv2beta2.HorizontalPodAutoscalerBehavior `json:"behavior,omitempty"`
+}
+
+// ServiceVersion overrides the framework or image of a service and adds faults that are
+// injected into every request the version receives
+type ServiceVersion struct {
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	Name string `json:"name"`
+	// Weight is the relative share of the requests sent to the service that are routed to this version
+	// +optional
+	// +kubebuilder:default=1
+	// +kubebuilder:validation:Minimum=0
+	Weight int `json:"weight"`
+	// +optional
+	FrameworkRef string `json:"frameworkRef,omitempty"`
+	// +optional
+	Language string `json:"language,omitempty"`
+	// +optional
+	Framework string `json:"framework,omitempty"`
+	// Image overrides the image of the framework
+	// +optional
+	Image string `json:"image,omitempty"`
+	// Faults are added to the faults of every request routed to this version
+	// +optional
+	Faults Faults `json:"faults"`
+}
+
+type ServiceVersionStatus struct {
+	Endpoint  string `json:"endpoint"`
+	Weight    int    `json:"weight"`
 	Language  string `json:"language"`
 	Framework string `json:"framework"`
+	Image     string `json:"image"`
+	// +optional
+	Faults Faults `json:"faults"`
+	// +optional
+	Replicas int32 `json:"replicas"`
+	// +optional
+	DesiredReplicas int32 `json:"desiredReplicas"`
 }
 
 type ServiceStatus struct {
 	Endpoint  string `json:"endpoint"`
 	Language  string `json:"language"`
 	Framework string `json:"framework"`
+	// +optional
+	Image string `json:"image,omitempty"`
+	// +optional
+	SupportedFaults []string `json:"supportedFaults,omitempty"`
+	// +optional
+	Versions map[string]ServiceVersionStatus `json:"versions,omitempty"`
+	// Replicas is the number of running pods, summed over the versions
+	// +optional
+	Replicas int32 `json:"replicas"`
+	// DesiredReplicas is the number of pods the autoscaler or the Deployment asks for, summed over the versions
+	// +optional
+	DesiredReplicas int32 `json:"desiredReplicas"`
 }
 
 // SimulationSpec defines the desired state of Simulation
 type SimulationSpec struct {
 	Services map[string]ServiceSpec `json:"services"`
+	// Suspend scales every service to zero and pauses the load generators targeting the simulation,
+	// the replica counts are restored once it's unset
+	// +optional
+	Suspend bool `json:"suspend,omitempty"`
 }
 
 // SimulationStatus defines the observed state of Simulation
 type SimulationStatus struct {
 	Services map[string]ServiceStatus `json:"services"`
+	// +optional
+	Suspended bool `json:"suspended"`
 }
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Suspended",type=boolean,JSONPath=`.status.suspended`
 
 // Simulation is the Schema for the simulations API
 type Simulation struct {
@@ -68,3 +168,8 @@ type SimulationList struct {
 func init() {
 	SchemeBuilder.Register(&Simulation{}, &SimulationList{})
 }
+
+// ServiceName returns the name of the Kubernetes resources of a service in the simulation
+func (in *Simulation) ServiceName(name string) string {
+	return fmt.Sprintf("%s-%s", strings.Replace(name, "_", "-", -1), in.ObjectMeta.UID[:8])
+}
@@ -1,19 +1,93 @@
 package v1alpha1
 
-import "encoding/json"
-
+// Response is the response tree returned by the services, Response holds the responses of
+// the routes in the order they were sent, null when a route didn't respond
 // +kubebuilder:object:generate=false
 type Response struct {
-	Service  string    `json:"service"`
-	Address  string    `json:"address"`
-	Errors   []string  `json:"errors"`
-	Response *Response `json:"response"`
+	Service  string      `json:"service"`
+	Address  string      `json:"address"`
+	Errors   []string    `json:"errors"`
+	Response []*Response `json:"response"`
+	// Duration is the time the service took to respond in milliseconds, it's only set by services that report it
+	Duration *float64 `json:"duration,omitempty"`
 }
 
+// Route is the request tree as it is sent over the wire to the services
 // +kubebuilder:object:generate=false
 type Route struct {
-	Designation string          `json:"designation"`
-	Probability int             `json:"probability"`
-	Faults      json.RawMessage `json:"faults"`
-	Routes      []Route         `json:"routes"`
+	Designation string  `json:"designation"`
+	Probability int     `json:"probability"`
+	Faults      Faults  `json:"faults"`
+	Routes      []Route `json:"routes"`
+}
+
+// FaultArgs holds the arguments of every supported fault type, only the ones
+// relevant to Fault.Type are read by the service
+type FaultArgs struct {
+	// Delay added by a latency fault in milliseconds
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	Delay *int `json:"delay,omitempty"`
+	// Size of the memory allocated by a memory-leak fault in megabytes
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	Size *int `json:"size,omitempty"`
+	// Duration a memory-leak fault holds the memory in milliseconds
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	Duration *int `json:"duration,omitempty"`
+}
+
+type Fault struct {
+	// +kubebuilder:validation:Enum=latency;memory-leak
+	Type string `json:"type"`
+	// +optional
+	Args FaultArgs `json:"args"`
+}
+
+// Faults are executed by a service before and after it forwards the request to its routes
+type Faults struct {
+	// +optional
+	Before []Fault `json:"before"`
+	// +optional
+	After []Fault `json:"after"`
+}
+
+// Hop is a single service call within a RouteTemplate
+type Hop struct {
+	// Name is used by other hops to refer to this one, defaults to the designation
+	// +optional
+	Name string `json:"name,omitempty"`
+	// Designation is the service name in the simulation or an absolute http(s) URL
+	// +kubebuilder:validation:MinLength=1
+	Designation string `json:"designation"`
+	// Probability is the chance in percent the parent hop calls this one, it's ignored on the first hop
+	// +optional
+	// +kubebuilder:default=100
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	Probability *int `json:"probability,omitempty"`
+	// +optional
+	Faults Faults `json:"faults"`
+	// Routes are the names of the hops this service forwards the request to
+	// +optional
+	Routes []string `json:"routes,omitempty"`
+}
+
+// RouteTemplate is a request tree sent by the load generator.
+// CRD schemas can not be recursive, so the tree is flattened into a list of hops where
+// the first hop is the entrypoint and children are referenced by name. Every hop has to be
+// reachable from the first one.
+type RouteTemplate struct {
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=128
+	Hops []Hop `json:"hops"`
+	// Weight is the relative share of the requests sent with this template, like 70 browse, 25 search
+	// and 5 checkout. Templates with equal weights take turns
+	// +optional
+	// +kubebuilder:default=1
+	// +kubebuilder:validation:Minimum=0
+	Weight int `json:"weight"`
 }
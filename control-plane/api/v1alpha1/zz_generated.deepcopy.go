@@ -22,31 +22,1283 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"k8s.io/api/autoscaling/v2beta2"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AbortSpec) DeepCopyInto(out *AbortSpec) {
+	*out = *in
+	if in.MaxErrorRate != nil {
+		in, out := &in.MaxErrorRate, &out.MaxErrorRate
+		*out = new(int)
+		**out = **in
+	}
+	out.Window = in.Window
+	if in.MaxLatency != nil {
+		in, out := &in.MaxLatency, &out.MaxLatency
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AbortSpec.
+func (in *AbortSpec) DeepCopy() *AbortSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(AbortSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Assertion) DeepCopyInto(out *Assertion) {
+	*out = *in
+	if in.MaxErrorRate != nil {
+		in, out := &in.MaxErrorRate, &out.MaxErrorRate
+		*out = new(int)
+		**out = **in
+	}
+	if in.MaxLatency != nil {
+		in, out := &in.MaxLatency, &out.MaxLatency
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.MaxErrors != nil {
+		in, out := &in.MaxErrors, &out.MaxErrors
+		*out = new(int)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Assertion.
+func (in *Assertion) DeepCopy() *Assertion {
+	if in == nil {
+		return nil
+	}
+	out := new(Assertion)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AssertionResult) DeepCopyInto(out *AssertionResult) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AssertionResult.
+func (in *AssertionResult) DeepCopy() *AssertionResult {
+	if in == nil {
+		return nil
+	}
+	out := new(AssertionResult)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AutoscalingSpec) DeepCopyInto(out *AutoscalingSpec) {
+	*out = *in
+	if in.MinReplicas != nil {
+		in, out := &in.MinReplicas, &out.MinReplicas
+		*out = new(int32)
+		**out = **in
+	}
+	if in.TargetCPUUtilization != nil {
+		in, out := &in.TargetCPUUtilization, &out.TargetCPUUtilization
+		*out = new(int32)
+		**out = **in
+	}
+	if in.TargetMemoryUtilization != nil {
+		in, out := &in.TargetMemoryUtilization, &out.TargetMemoryUtilization
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Metrics != nil {
+		in, out := &in.Metrics, &out.Metrics
+		*out = make([]v2beta2.MetricSpec, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Behavior != nil {
+		in, out := &in.Behavior, &out.Behavior
+		*out = new(v2beta2.HorizontalPodAutoscalerBehavior)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AutoscalingSpec.
+func (in *AutoscalingSpec) DeepCopy() *AutoscalingSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(AutoscalingSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DurationDelta) DeepCopyInto(out *DurationDelta) {
+	*out = *in
+	out.Baseline = in.Baseline
+	out.Candidate = in.Candidate
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DurationDelta.
+func (in *DurationDelta) DeepCopy() *DurationDelta {
+	if in == nil {
+		return nil
+	}
+	out := new(DurationDelta)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ExportSpec) DeepCopyInto(out *ExportSpec) {
+	*out = *in
+	if in.Formats != nil {
+		in, out := &in.Formats, &out.Formats
+		*out = make([]ExportFormat, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ExportSpec.
+func (in *ExportSpec) DeepCopy() *ExportSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ExportSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Fault) DeepCopyInto(out *Fault) {
+	*out = *in
+	in.Args.DeepCopyInto(&out.Args)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Fault.
+func (in *Fault) DeepCopy() *Fault {
+	if in == nil {
+		return nil
+	}
+	out := new(Fault)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FaultArgs) DeepCopyInto(out *FaultArgs) {
+	*out = *in
+	if in.Delay != nil {
+		in, out := &in.Delay, &out.Delay
+		*out = new(int)
+		**out = **in
+	}
+	if in.Size != nil {
+		in, out := &in.Size, &out.Size
+		*out = new(int)
+		**out = **in
+	}
+	if in.Duration != nil {
+		in, out := &in.Duration, &out.Duration
+		*out = new(int)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FaultArgs.
+func (in *FaultArgs) DeepCopy() *FaultArgs {
+	if in == nil {
+		return nil
+	}
+	out := new(FaultArgs)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Faults) DeepCopyInto(out *Faults) {
+	*out = *in
+	if in.Before != nil {
+		in, out := &in.Before, &out.Before
+		*out = make([]Fault, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.After != nil {
+		in, out := &in.After, &out.After
+		*out = make([]Fault, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Faults.
+func (in *Faults) DeepCopy() *Faults {
+	if in == nil {
+		return nil
+	}
+	out := new(Faults)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Hop) DeepCopyInto(out *Hop) {
+	*out = *in
+	if in.Probability != nil {
+		in, out := &in.Probability, &out.Probability
+		*out = new(int)
+		**out = **in
+	}
+	in.Faults.DeepCopyInto(&out.Faults)
+	if in.Routes != nil {
+		in, out := &in.Routes, &out.Routes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Hop.
+func (in *Hop) DeepCopy() *Hop {
+	if in == nil {
+		return nil
+	}
+	out := new(Hop)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LatencyComparison) DeepCopyInto(out *LatencyComparison) {
+	*out = *in
+	out.P50 = in.P50
+	out.P90 = in.P90
+	out.P95 = in.P95
+	out.P99 = in.P99
+	out.Mean = in.Mean
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LatencyComparison.
+func (in *LatencyComparison) DeepCopy() *LatencyComparison {
+	if in == nil {
+		return nil
+	}
+	out := new(LatencyComparison)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LatencyStats) DeepCopyInto(out *LatencyStats) {
+	*out = *in
+	out.P50 = in.P50
+	out.P90 = in.P90
+	out.P95 = in.P95
+	out.P99 = in.P99
+	out.Max = in.Max
+	out.Mean = in.Mean
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LatencyStats.
+func (in *LatencyStats) DeepCopy() *LatencyStats {
+	if in == nil {
+		return nil
+	}
+	out := new(LatencyStats)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *LoadGenerator) DeepCopyInto(out *LoadGenerator) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	in.Status.DeepCopyInto(&out.Status)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LoadGenerator.
+func (in *LoadGenerator) DeepCopy() *LoadGenerator {
+	if in == nil {
+		return nil
+	}
+	out := new(LoadGenerator)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *LoadGenerator) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LoadGeneratorList) DeepCopyInto(out *LoadGeneratorList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]LoadGenerator, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LoadGeneratorList.
+func (in *LoadGeneratorList) DeepCopy() *LoadGeneratorList {
+	if in == nil {
+		return nil
+	}
+	out := new(LoadGeneratorList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *LoadGeneratorList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LoadGeneratorRef) DeepCopyInto(out *LoadGeneratorRef) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LoadGeneratorRef.
+func (in *LoadGeneratorRef) DeepCopy() *LoadGeneratorRef {
+	if in == nil {
+		return nil
+	}
+	out := new(LoadGeneratorRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LoadGeneratorSpec) DeepCopyInto(out *LoadGeneratorSpec) {
+	*out = *in
+	if in.Routes != nil {
+		in, out := &in.Routes, &out.Routes
+		*out = make([]RouteTemplate, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Requests != nil {
+		in, out := &in.Requests, &out.Requests
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Scenarios != nil {
+		in, out := &in.Scenarios, &out.Scenarios
+		*out = make([]Scenario, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	out.SimulationRef = in.SimulationRef
+	if in.RequestCount != nil {
+		in, out := &in.RequestCount, &out.RequestCount
+		*out = new(int)
+		**out = **in
+	}
+	if in.Timeout != nil {
+		in, out := &in.Timeout, &out.Timeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.Seed != nil {
+		in, out := &in.Seed, &out.Seed
+		*out = new(int64)
+		**out = **in
+	}
+	if in.Abort != nil {
+		in, out := &in.Abort, &out.Abort
+		*out = new(AbortSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Warmup != nil {
+		in, out := &in.Warmup, &out.Warmup
+		*out = new(WarmupSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Assertions != nil {
+		in, out := &in.Assertions, &out.Assertions
+		*out = make([]Assertion, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	out.BetweenDelay = in.BetweenDelay
+	if in.Rate != nil {
+		in, out := &in.Rate, &out.Rate
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.Concurrency != nil {
+		in, out := &in.Concurrency, &out.Concurrency
+		*out = new(int)
+		**out = **in
+	}
+	if in.Sampling != nil {
+		in, out := &in.Sampling, &out.Sampling
+		*out = new(SamplingSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Export != nil {
+		in, out := &in.Export, &out.Export
+		*out = new(ExportSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Record != nil {
+		in, out := &in.Record, &out.Record
+		*out = new(RecordSpec)
+		**out = **in
+	}
+	if in.Notify != nil {
+		in, out := &in.Notify, &out.Notify
+		*out = new(NotifySpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Replay != nil {
+		in, out := &in.Replay, &out.Replay
+		*out = new(ReplaySpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Profile != nil {
+		in, out := &in.Profile, &out.Profile
+		*out = new(LoadProfile)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LoadGeneratorSpec.
+func (in *LoadGeneratorSpec) DeepCopy() *LoadGeneratorSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(LoadGeneratorSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LoadGeneratorStatus) DeepCopyInto(out *LoadGeneratorStatus) {
+	*out = *in
+	if in.Samples != nil {
+		in, out := &in.Samples, &out.Samples
+		*out = new(Samples)
+		(*in).DeepCopyInto(*out)
+	}
+	out.TotalResponseTime = in.TotalResponseTime
+	if in.PausedAt != nil {
+		in, out := &in.PausedAt, &out.PausedAt
+		*out = (*in).DeepCopy()
+	}
+	out.PausedFor = in.PausedFor
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Seed != nil {
+		in, out := &in.Seed, &out.Seed
+		*out = new(int64)
+		**out = **in
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.StatusClasses != nil {
+		in, out := &in.StatusClasses, &out.StatusClasses
+		*out = make(map[string]int, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Errors != nil {
+		in, out := &in.Errors, &out.Errors
+		*out = make(map[string]int, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Latency != nil {
+		in, out := &in.Latency, &out.Latency
+		*out = new(LatencyStats)
+		**out = **in
+	}
+	if in.Services != nil {
+		in, out := &in.Services, &out.Services
+		*out = make(map[string]ServiceCallStats, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
+	if in.Templates != nil {
+		in, out := &in.Templates, &out.Templates
+		*out = make(map[string]TemplateStats, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
+	if in.Scenarios != nil {
+		in, out := &in.Scenarios, &out.Scenarios
+		*out = make(map[string]ScenarioStats, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
+	if in.Throughput != nil {
+		in, out := &in.Throughput, &out.Throughput
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.Warmup != nil {
+		in, out := &in.Warmup, &out.Warmup
+		*out = new(WarmupStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Assertions != nil {
+		in, out := &in.Assertions, &out.Assertions
+		*out = make([]AssertionResult, len(*in))
+		copy(*out, *in)
+	}
+	if in.Recording != nil {
+		in, out := &in.Recording, &out.Recording
+		*out = new(RecordingStatus)
+		**out = **in
+	}
+	if in.Notifications != nil {
+		in, out := &in.Notifications, &out.Notifications
+		*out = make([]NotificationStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Shards != nil {
+		in, out := &in.Shards, &out.Shards
+		*out = make([]ShardStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LoadGeneratorStatus.
+func (in *LoadGeneratorStatus) DeepCopy() *LoadGeneratorStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(LoadGeneratorStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LoadProfile) DeepCopyInto(out *LoadProfile) {
+	*out = *in
+	if in.Stages != nil {
+		in, out := &in.Stages, &out.Stages
+		*out = make([]LoadStage, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LoadProfile.
+func (in *LoadProfile) DeepCopy() *LoadProfile {
+	if in == nil {
+		return nil
+	}
+	out := new(LoadProfile)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LoadStage) DeepCopyInto(out *LoadStage) {
+	*out = *in
+	out.Duration = in.Duration
+	if in.Rate != nil {
+		in, out := &in.Rate, &out.Rate
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.Concurrency != nil {
+		in, out := &in.Concurrency, &out.Concurrency
+		*out = new(int)
+		**out = **in
+	}
+	if in.Amplitude != nil {
+		in, out := &in.Amplitude, &out.Amplitude
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.Period != nil {
+		in, out := &in.Period, &out.Period
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LoadStage.
+func (in *LoadStage) DeepCopy() *LoadStage {
+	if in == nil {
+		return nil
+	}
+	out := new(LoadStage)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NotificationStatus) DeepCopyInto(out *NotificationStatus) {
+	*out = *in
+	if in.LastAttempt != nil {
+		in, out := &in.LastAttempt, &out.LastAttempt
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NotificationStatus.
+func (in *NotificationStatus) DeepCopy() *NotificationStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(NotificationStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NotifySpec) DeepCopyInto(out *NotifySpec) {
+	*out = *in
+	if in.Webhooks != nil {
+		in, out := &in.Webhooks, &out.Webhooks
+		*out = make([]Webhook, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NotifySpec.
+func (in *NotifySpec) DeepCopy() *NotifySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(NotifySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RateComparison) DeepCopyInto(out *RateComparison) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RateComparison.
+func (in *RateComparison) DeepCopy() *RateComparison {
+	if in == nil {
+		return nil
+	}
+	out := new(RateComparison)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RecordSpec) DeepCopyInto(out *RecordSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RecordSpec.
+func (in *RecordSpec) DeepCopy() *RecordSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(RecordSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RecordingStatus) DeepCopyInto(out *RecordingStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RecordingStatus.
+func (in *RecordingStatus) DeepCopy() *RecordingStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(RecordingStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ReplaySpec) DeepCopyInto(out *ReplaySpec) {
+	*out = *in
+	if in.From != nil {
+		in, out := &in.From, &out.From
+		*out = new(LoadGeneratorRef)
+		**out = **in
+	}
+	if in.Speed != nil {
+		in, out := &in.Speed, &out.Speed
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReplaySpec.
+func (in *ReplaySpec) DeepCopy() *ReplaySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ReplaySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RouteDecision) DeepCopyInto(out *RouteDecision) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RouteDecision.
+func (in *RouteDecision) DeepCopy() *RouteDecision {
+	if in == nil {
+		return nil
+	}
+	out := new(RouteDecision)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RouteTemplate) DeepCopyInto(out *RouteTemplate) {
+	*out = *in
+	if in.Hops != nil {
+		in, out := &in.Hops, &out.Hops
+		*out = make([]Hop, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RouteTemplate.
+func (in *RouteTemplate) DeepCopy() *RouteTemplate {
+	if in == nil {
+		return nil
+	}
+	out := new(RouteTemplate)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RunComparison) DeepCopyInto(out *RunComparison) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec = in.Spec
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RunComparison.
+func (in *RunComparison) DeepCopy() *RunComparison {
+	if in == nil {
+		return nil
+	}
+	out := new(RunComparison)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *RunComparison) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RunComparisonList) DeepCopyInto(out *RunComparisonList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]RunComparison, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RunComparisonList.
+func (in *RunComparisonList) DeepCopy() *RunComparisonList {
+	if in == nil {
+		return nil
+	}
+	out := new(RunComparisonList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *RunComparisonList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RunComparisonSpec) DeepCopyInto(out *RunComparisonSpec) {
+	*out = *in
+	out.Baseline = in.Baseline
+	out.Candidate = in.Candidate
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RunComparisonSpec.
+func (in *RunComparisonSpec) DeepCopy() *RunComparisonSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(RunComparisonSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RunComparisonStatus) DeepCopyInto(out *RunComparisonStatus) {
+	*out = *in
+	if in.Baseline != nil {
+		in, out := &in.Baseline, &out.Baseline
+		*out = new(RunReference)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Candidate != nil {
+		in, out := &in.Candidate, &out.Candidate
+		*out = new(RunReference)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Latency != nil {
+		in, out := &in.Latency, &out.Latency
+		*out = new(LatencyComparison)
+		**out = **in
+	}
+	if in.ErrorRate != nil {
+		in, out := &in.ErrorRate, &out.ErrorRate
+		*out = new(RateComparison)
+		**out = **in
+	}
+	if in.Services != nil {
+		in, out := &in.Services, &out.Services
+		*out = make(map[string]ServiceComparison, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RunComparisonStatus.
+func (in *RunComparisonStatus) DeepCopy() *RunComparisonStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(RunComparisonStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RunReference) DeepCopyInto(out *RunReference) {
+	*out = *in
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Seed != nil {
+		in, out := &in.Seed, &out.Seed
+		*out = new(int64)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RunReference.
+func (in *RunReference) DeepCopy() *RunReference {
+	if in == nil {
+		return nil
+	}
+	out := new(RunReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Sample) DeepCopyInto(out *Sample) {
+	*out = *in
+	in.Time.DeepCopyInto(&out.Time)
+	out.Latency = in.Latency
+	if in.Route != nil {
+		in, out := &in.Route, &out.Route
+		*out = make([]RouteDecision, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Sample.
+func (in *Sample) DeepCopy() *Sample {
+	if in == nil {
+		return nil
+	}
+	out := new(Sample)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Samples) DeepCopyInto(out *Samples) {
+	*out = *in
+	if in.Errors != nil {
+		in, out := &in.Errors, &out.Errors
+		*out = make([]Sample, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Successes != nil {
+		in, out := &in.Successes, &out.Successes
+		*out = make([]Sample, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Samples.
+func (in *Samples) DeepCopy() *Samples {
+	if in == nil {
+		return nil
+	}
+	out := new(Samples)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SamplingSpec) DeepCopyInto(out *SamplingSpec) {
+	*out = *in
+	if in.Spill != nil {
+		in, out := &in.Spill, &out.Spill
+		*out = new(SpillSpec)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SamplingSpec.
+func (in *SamplingSpec) DeepCopy() *SamplingSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(SamplingSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Scenario) DeepCopyInto(out *Scenario) {
+	*out = *in
+	if in.Steps != nil {
+		in, out := &in.Steps, &out.Steps
+		*out = make([]ScenarioStep, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ThinkTime != nil {
+		in, out := &in.ThinkTime, &out.ThinkTime
+		*out = new(ThinkTime)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Scenario.
+func (in *Scenario) DeepCopy() *Scenario {
+	if in == nil {
+		return nil
+	}
+	out := new(Scenario)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ScenarioStats) DeepCopyInto(out *ScenarioStats) {
+	*out = *in
+	if in.Duration != nil {
+		in, out := &in.Duration, &out.Duration
+		*out = new(LatencyStats)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ScenarioStats.
+func (in *ScenarioStats) DeepCopy() *ScenarioStats {
+	if in == nil {
+		return nil
+	}
+	out := new(ScenarioStats)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ScenarioStep) DeepCopyInto(out *ScenarioStep) {
+	*out = *in
+	if in.ThinkTime != nil {
+		in, out := &in.ThinkTime, &out.ThinkTime
+		*out = new(ThinkTime)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ScenarioStep.
+func (in *ScenarioStep) DeepCopy() *ScenarioStep {
+	if in == nil {
+		return nil
+	}
+	out := new(ScenarioStep)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ScheduledLoadGenerator) DeepCopyInto(out *ScheduledLoadGenerator) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ScheduledLoadGenerator.
+func (in *ScheduledLoadGenerator) DeepCopy() *ScheduledLoadGenerator {
+	if in == nil {
+		return nil
+	}
+	out := new(ScheduledLoadGenerator)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ScheduledLoadGenerator) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ScheduledLoadGeneratorList) DeepCopyInto(out *ScheduledLoadGeneratorList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ScheduledLoadGenerator, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ScheduledLoadGeneratorList.
+func (in *ScheduledLoadGeneratorList) DeepCopy() *ScheduledLoadGeneratorList {
+	if in == nil {
+		return nil
+	}
+	out := new(ScheduledLoadGeneratorList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ScheduledLoadGeneratorList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ScheduledLoadGeneratorSpec) DeepCopyInto(out *ScheduledLoadGeneratorSpec) {
+	*out = *in
+	if in.StartingDeadlineSeconds != nil {
+		in, out := &in.StartingDeadlineSeconds, &out.StartingDeadlineSeconds
+		*out = new(int64)
+		**out = **in
+	}
+	in.Template.DeepCopyInto(&out.Template)
+	if in.SuccessfulRunsHistoryLimit != nil {
+		in, out := &in.SuccessfulRunsHistoryLimit, &out.SuccessfulRunsHistoryLimit
+		*out = new(int32)
+		**out = **in
+	}
+	if in.FailedRunsHistoryLimit != nil {
+		in, out := &in.FailedRunsHistoryLimit, &out.FailedRunsHistoryLimit
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ScheduledLoadGeneratorSpec.
+func (in *ScheduledLoadGeneratorSpec) DeepCopy() *ScheduledLoadGeneratorSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ScheduledLoadGeneratorSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ScheduledLoadGeneratorStatus) DeepCopyInto(out *ScheduledLoadGeneratorStatus) {
+	*out = *in
+	if in.Active != nil {
+		in, out := &in.Active, &out.Active
+		*out = make([]corev1.ObjectReference, len(*in))
+		copy(*out, *in)
+	}
+	if in.LastScheduleTime != nil {
+		in, out := &in.LastScheduleTime, &out.LastScheduleTime
+		*out = (*in).DeepCopy()
+	}
+	if in.LastSuccessfulTime != nil {
+		in, out := &in.LastSuccessfulTime, &out.LastSuccessfulTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ScheduledLoadGeneratorStatus.
+func (in *ScheduledLoadGeneratorStatus) DeepCopy() *ScheduledLoadGeneratorStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ScheduledLoadGeneratorStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ServiceCallStats) DeepCopyInto(out *ServiceCallStats) {
+	*out = *in
+	if in.ErrorMessages != nil {
+		in, out := &in.ErrorMessages, &out.ErrorMessages
+		*out = make(map[string]int, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Latency != nil {
+		in, out := &in.Latency, &out.Latency
+		*out = new(LatencyStats)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceCallStats.
+func (in *ServiceCallStats) DeepCopy() *ServiceCallStats {
+	if in == nil {
+		return nil
+	}
+	out := new(ServiceCallStats)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ServiceComparison) DeepCopyInto(out *ServiceComparison) {
+	*out = *in
+	if in.ErrorRate != nil {
+		in, out := &in.ErrorRate, &out.ErrorRate
+		*out = new(RateComparison)
+		**out = **in
+	}
+	if in.Latency != nil {
+		in, out := &in.Latency, &out.Latency
+		*out = new(LatencyComparison)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceComparison.
+func (in *ServiceComparison) DeepCopy() *ServiceComparison {
+	if in == nil {
+		return nil
+	}
+	out := new(ServiceComparison)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ServiceFramework) DeepCopyInto(out *ServiceFramework) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LoadGenerator.
-func (in *LoadGenerator) DeepCopy() *LoadGenerator {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceFramework.
+func (in *ServiceFramework) DeepCopy() *ServiceFramework {
 	if in == nil {
 		return nil
 	}
-	out := new(LoadGenerator)
+	out := new(ServiceFramework)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *LoadGenerator) DeepCopyObject() runtime.Object {
+func (in *ServiceFramework) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -54,31 +1306,31 @@ func (in *LoadGenerator) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *LoadGeneratorList) DeepCopyInto(out *LoadGeneratorList) {
+func (in *ServiceFrameworkList) DeepCopyInto(out *ServiceFrameworkList) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ListMeta.DeepCopyInto(&out.ListMeta)
 	if in.Items != nil {
 		in, out := &in.Items, &out.Items
-		*out = make([]LoadGenerator, len(*in))
+		*out = make([]ServiceFramework, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LoadGeneratorList.
-func (in *LoadGeneratorList) DeepCopy() *LoadGeneratorList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceFrameworkList.
+func (in *ServiceFrameworkList) DeepCopy() *ServiceFrameworkList {
 	if in == nil {
 		return nil
 	}
-	out := new(LoadGeneratorList)
+	out := new(ServiceFrameworkList)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *LoadGeneratorList) DeepCopyObject() runtime.Object {
+func (in *ServiceFrameworkList) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -86,101 +1338,142 @@ func (in *LoadGeneratorList) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *LoadGeneratorSpec) DeepCopyInto(out *LoadGeneratorSpec) {
+func (in *ServiceFrameworkSpec) DeepCopyInto(out *ServiceFrameworkSpec) {
 	*out = *in
-	if in.Routes != nil {
-		in, out := &in.Routes, &out.Routes
+	if in.SupportedFaults != nil {
+		in, out := &in.SupportedFaults, &out.SupportedFaults
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
-	out.SimulationRef = in.SimulationRef
-	if in.RequestCount != nil {
-		in, out := &in.RequestCount, &out.RequestCount
-		*out = new(int)
-		**out = **in
+	if in.Env != nil {
+		in, out := &in.Env, &out.Env
+		*out = make([]corev1.EnvVar, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
 	}
-	if in.Timeout != nil {
-		in, out := &in.Timeout, &out.Timeout
-		*out = new(v1.Duration)
-		**out = **in
+	if in.ReadinessProbe != nil {
+		in, out := &in.ReadinessProbe, &out.ReadinessProbe
+		*out = new(corev1.Probe)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.LivenessProbe != nil {
+		in, out := &in.LivenessProbe, &out.LivenessProbe
+		*out = new(corev1.Probe)
+		(*in).DeepCopyInto(*out)
 	}
-	out.BetweenDelay = in.BetweenDelay
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LoadGeneratorSpec.
-func (in *LoadGeneratorSpec) DeepCopy() *LoadGeneratorSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceFrameworkSpec.
+func (in *ServiceFrameworkSpec) DeepCopy() *ServiceFrameworkSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(LoadGeneratorSpec)
+	out := new(ServiceFrameworkSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *LoadGeneratorStatus) DeepCopyInto(out *LoadGeneratorStatus) {
+func (in *ServiceSpec) DeepCopyInto(out *ServiceSpec) {
+	*out = *in
+	if in.Versions != nil {
+		in, out := &in.Versions, &out.Versions
+		*out = make([]ServiceVersion, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	in.Resources.DeepCopyInto(&out.Resources)
+	if in.Autoscaling != nil {
+		in, out := &in.Autoscaling, &out.Autoscaling
+		*out = new(AutoscalingSpec)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceSpec.
+func (in *ServiceSpec) DeepCopy() *ServiceSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ServiceSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ServiceStatus) DeepCopyInto(out *ServiceStatus) {
 	*out = *in
-	if in.Responses != nil {
-		in, out := &in.Responses, &out.Responses
-		*out = make(map[string]Responses, len(*in))
+	if in.SupportedFaults != nil {
+		in, out := &in.SupportedFaults, &out.SupportedFaults
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Versions != nil {
+		in, out := &in.Versions, &out.Versions
+		*out = make(map[string]ServiceVersionStatus, len(*in))
 		for key, val := range *in {
-			(*out)[key] = val
+			(*out)[key] = *val.DeepCopy()
 		}
 	}
-	out.TotalResponseTime = in.TotalResponseTime
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LoadGeneratorStatus.
-func (in *LoadGeneratorStatus) DeepCopy() *LoadGeneratorStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceStatus.
+func (in *ServiceStatus) DeepCopy() *ServiceStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(LoadGeneratorStatus)
+	out := new(ServiceStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *Responses) DeepCopyInto(out *Responses) {
+func (in *ServiceVersion) DeepCopyInto(out *ServiceVersion) {
 	*out = *in
+	in.Faults.DeepCopyInto(&out.Faults)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Responses.
-func (in *Responses) DeepCopy() *Responses {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceVersion.
+func (in *ServiceVersion) DeepCopy() *ServiceVersion {
 	if in == nil {
 		return nil
 	}
-	out := new(Responses)
+	out := new(ServiceVersion)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ServiceSpec) DeepCopyInto(out *ServiceSpec) {
+func (in *ServiceVersionStatus) DeepCopyInto(out *ServiceVersionStatus) {
 	*out = *in
+	in.Faults.DeepCopyInto(&out.Faults)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceSpec.
-func (in *ServiceSpec) DeepCopy() *ServiceSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceVersionStatus.
+func (in *ServiceVersionStatus) DeepCopy() *ServiceVersionStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(ServiceSpec)
+	out := new(ServiceVersionStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ServiceStatus) DeepCopyInto(out *ServiceStatus) {
+func (in *ShardStatus) DeepCopyInto(out *ShardStatus) {
 	*out = *in
+	out.Elapsed = in.Elapsed
+	in.LastReport.DeepCopyInto(&out.LastReport)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceStatus.
-func (in *ServiceStatus) DeepCopy() *ServiceStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ShardStatus.
+func (in *ShardStatus) DeepCopy() *ShardStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(ServiceStatus)
+	out := new(ShardStatus)
 	in.DeepCopyInto(out)
 	return out
 }
@@ -266,7 +1559,7 @@ func (in *SimulationSpec) DeepCopyInto(out *SimulationSpec) {
 		in, out := &in.Services, &out.Services
 		*out = make(map[string]ServiceSpec, len(*in))
 		for key, val := range *in {
-			(*out)[key] = val
+			(*out)[key] = *val.DeepCopy()
 		}
 	}
 }
@@ -288,7 +1581,7 @@ func (in *SimulationStatus) DeepCopyInto(out *SimulationStatus) {
 		in, out := &in.Services, &out.Services
 		*out = make(map[string]ServiceStatus, len(*in))
 		for key, val := range *in {
-			(*out)[key] = val
+			(*out)[key] = *val.DeepCopy()
 		}
 	}
 }
@@ -302,3 +1595,156 @@ func (in *SimulationStatus) DeepCopy() *SimulationStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SpillSpec) DeepCopyInto(out *SpillSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SpillSpec.
+func (in *SpillSpec) DeepCopy() *SpillSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(SpillSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SteadyStateSpec) DeepCopyInto(out *SteadyStateSpec) {
+	*out = *in
+	out.Window = in.Window
+	out.Timeout = in.Timeout
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SteadyStateSpec.
+func (in *SteadyStateSpec) DeepCopy() *SteadyStateSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(SteadyStateSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TemplateStats) DeepCopyInto(out *TemplateStats) {
+	*out = *in
+	if in.Latency != nil {
+		in, out := &in.Latency, &out.Latency
+		*out = new(LatencyStats)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TemplateStats.
+func (in *TemplateStats) DeepCopy() *TemplateStats {
+	if in == nil {
+		return nil
+	}
+	out := new(TemplateStats)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ThinkTime) DeepCopyInto(out *ThinkTime) {
+	*out = *in
+	if in.Mean != nil {
+		in, out := &in.Mean, &out.Mean
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.StdDev != nil {
+		in, out := &in.StdDev, &out.StdDev
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.Min != nil {
+		in, out := &in.Min, &out.Min
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.Max != nil {
+		in, out := &in.Max, &out.Max
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThinkTime.
+func (in *ThinkTime) DeepCopy() *ThinkTime {
+	if in == nil {
+		return nil
+	}
+	out := new(ThinkTime)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WarmupSpec) DeepCopyInto(out *WarmupSpec) {
+	*out = *in
+	if in.Duration != nil {
+		in, out := &in.Duration, &out.Duration
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.Requests != nil {
+		in, out := &in.Requests, &out.Requests
+		*out = new(int)
+		**out = **in
+	}
+	if in.SteadyState != nil {
+		in, out := &in.SteadyState, &out.SteadyState
+		*out = new(SteadyStateSpec)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WarmupSpec.
+func (in *WarmupSpec) DeepCopy() *WarmupSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(WarmupSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WarmupStatus) DeepCopyInto(out *WarmupStatus) {
+	*out = *in
+	if in.Latency != nil {
+		in, out := &in.Latency, &out.Latency
+		*out = new(LatencyStats)
+		**out = **in
+	}
+	out.Duration = in.Duration
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WarmupStatus.
+func (in *WarmupStatus) DeepCopy() *WarmupStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(WarmupStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Webhook) DeepCopyInto(out *Webhook) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Webhook.
+func (in *Webhook) DeepCopy() *Webhook {
+	if in == nil {
+		return nil
+	}
+	out := new(Webhook)
+	in.DeepCopyInto(out)
+	return out
+}
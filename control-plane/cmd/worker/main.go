@@ -0,0 +1,90 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"flag"
+	"os"
+
+	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
+	// to ensure that exec-entrypoint and run can make use of them.
+	_ "k8s.io/client-go/plugin/pkg/client/auth"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	microsimv1alpha1 "github.com/MrSupiri/MicroSim/api/v1alpha1"
+	"github.com/MrSupiri/MicroSim/worker"
+)
+
+var (
+	scheme   = runtime.NewScheme()
+	setupLog = ctrl.Log.WithName("setup")
+)
+
+func init() {
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+
+	utilruntime.Must(microsimv1alpha1.AddToScheme(scheme))
+}
+
+func main() {
+	var key types.NamespacedName
+	var shard int
+	var shards int
+	flag.StringVar(&key.Name, "load-generator", "", "The name of the LoadGenerator to run.")
+	flag.StringVar(&key.Namespace, "namespace", os.Getenv("POD_NAMESPACE"), "The namespace of the LoadGenerator.")
+	flag.IntVar(&shard, "shard", 0, "The shard of the load sent by this worker, starting from 0.")
+	flag.IntVar(&shards, "shards", 1, "The number of workers the load is split across.")
+	opts := zap.Options{
+		Development: true,
+	}
+	opts.BindFlags(flag.CommandLine)
+	flag.Parse()
+
+	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
+
+	if key.Name == "" || key.Namespace == "" || shard < 0 || shard >= shards {
+		setupLog.Info("a load generator, its namespace and a shard below the number of shards must be set")
+		os.Exit(2)
+	}
+
+	c, err := client.New(ctrl.GetConfigOrDie(), client.Options{Scheme: scheme})
+	if err != nil {
+		setupLog.Error(err, "unable to create client")
+		os.Exit(1)
+	}
+
+	logger := ctrl.Log.WithName("worker").WithValues("loadGenerator", key, "shard", shard)
+	ctx := log.IntoContext(ctrl.SetupSignalHandler(), logger)
+	if err := (&worker.Worker{
+		Client:        c,
+		Scheme:        scheme,
+		LoadGenerator: key,
+		Shard:         shard,
+		Shards:        shards,
+	}).Run(ctx); err != nil {
+		logger.Error(err, "worker failed")
+		os.Exit(1)
+	}
+}
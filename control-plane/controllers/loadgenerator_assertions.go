@@ -0,0 +1,133 @@
+package controllers
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	microsimv1alpha1 "github.com/MrSupiri/MicroSim/api/v1alpha1"
+	"github.com/MrSupiri/MicroSim/engine"
+)
+
+// checkAssertions checks the assertions against the statistics of the run so far
+func checkAssertions(assertions []microsimv1alpha1.Assertion, stats engine.Snapshot) []microsimv1alpha1.AssertionResult {
+	var results []microsimv1alpha1.AssertionResult
+	for i, assertion := range assertions {
+		result := checkAssertion(assertion, stats)
+		if result.Name == "" {
+			result.Name = fmt.Sprintf("assertion-%d", i)
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+func checkAssertion(assertion microsimv1alpha1.Assertion, stats engine.Snapshot) microsimv1alpha1.AssertionResult {
+	// The requests of the run, or the calls of the service the assertion is scoped to
+	requests, failed, latency := stats.Latency.Total, stats.Failed, stats.Latency
+	subject := "requests"
+	if assertion.Service != "" {
+		requests, failed, latency = 0, 0, engine.Histogram{}
+		for designation, service := range stats.Services {
+			if matchesService(designation, assertion.Service) {
+				requests += service.Calls
+				failed += service.Failed
+				latency.Merge(service.Latency)
+			}
+		}
+		subject = "calls to " + assertion.Service
+	}
+
+	var checks, failures []string
+	check := func(description string, passed bool, message string) {
+		checks = append(checks, description)
+		if !passed {
+			failures = append(failures, message)
+		}
+	}
+	if assertion.MaxErrorRate != nil {
+		errorRate := 0.0
+		if requests > 0 {
+			errorRate = float64(failed) / float64(requests) * 100
+		}
+		check(fmt.Sprintf("error rate <= %d%%", *assertion.MaxErrorRate), requests > 0 && errorRate <= float64(*assertion.MaxErrorRate),
+			fmt.Sprintf("%.1f%% of %d %s failed", errorRate, requests, subject))
+	}
+	if assertion.MaxLatency != nil {
+		percentile, value := latency.Latency().At(assertion.Percentile)
+		check(fmt.Sprintf("%s <= %v", percentile, assertion.MaxLatency.Duration), latency.Total > 0 && value <= assertion.MaxLatency.Duration,
+			fmt.Sprintf("%s latency of %d %s is %v", percentile, latency.Total, subject, value.Round(time.Millisecond)))
+	}
+	if assertion.MaxErrors != nil {
+		check(fmt.Sprintf("errors <= %d", *assertion.MaxErrors), failed <= uint64(*assertion.MaxErrors),
+			fmt.Sprintf("%d of %d %s failed", failed, requests, subject))
+	}
+	if assertion.Called != "" {
+		calls := uint64(0)
+		for designation, service := range stats.Services {
+			if matchesService(designation, assertion.Called) {
+				calls += service.Calls
+			}
+		}
+		check(fmt.Sprintf("%s is called", assertion.Called), calls > 0, fmt.Sprintf("%s was never called", assertion.Called))
+	}
+
+	result := microsimv1alpha1.AssertionResult{
+		Name:   assertion.Name,
+		Passed: len(failures) == 0,
+	}
+	if result.Name == "" {
+		result.Name = strings.Join(checks, ", ")
+		if assertion.Service != "" {
+			result.Name = assertion.Service + " " + result.Name
+		}
+	}
+	if len(checks) == 0 {
+		result.Passed = false
+		result.Message = "The assertion doesn't check anything"
+	} else if len(failures) > 0 {
+		result.Message = strings.Join(failures, ", ")
+	}
+	return result
+}
+
+// matchesService reports whether the designation is the service, a service without a version matches every version of it
+func matchesService(designation string, service string) bool {
+	return designation == service || (!strings.Contains(service, "@") && strings.HasPrefix(designation, service+"@"))
+}
+
+// passedCondition is the verdict of the assertions, it's only decided once the run is finished
+func passedCondition(results []microsimv1alpha1.AssertionResult, finished bool, generation int64) metav1.Condition {
+	var failing []string
+	for _, result := range results {
+		if !result.Passed {
+			failing = append(failing, result.Name)
+		}
+	}
+
+	condition := metav1.Condition{
+		Type:               microsimv1alpha1.ConditionPassed,
+		ObservedGeneration: generation,
+	}
+	switch {
+	case !finished && len(failing) == 0:
+		condition.Status = metav1.ConditionUnknown
+		condition.Reason = microsimv1alpha1.ReasonRunning
+		condition.Message = "The assertions hold so far"
+	case !finished:
+		condition.Status = metav1.ConditionUnknown
+		condition.Reason = microsimv1alpha1.ReasonAssertionsFailing
+		condition.Message = fmt.Sprintf("Failing so far: %s", strings.Join(failing, "; "))
+	case len(failing) == 0:
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = microsimv1alpha1.ReasonAssertionsPassed
+		condition.Message = fmt.Sprintf("All %d assertions passed", len(results))
+	default:
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = microsimv1alpha1.ReasonAssertionsFailed
+		condition.Message = fmt.Sprintf("Failed: %s", strings.Join(failing, "; "))
+	}
+	return condition
+}
@@ -0,0 +1,164 @@
+package controllers
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	microsimv1alpha1 "github.com/MrSupiri/MicroSim/api/v1alpha1"
+	"github.com/MrSupiri/MicroSim/engine"
+)
+
+// histogram records count latencies of d
+func histogram(count int, d time.Duration) engine.Histogram {
+	var h engine.Histogram
+	for i := 0; i < count; i++ {
+		h.Record(d)
+	}
+	return h
+}
+
+func TestCheckAssertion(t *testing.T) {
+	// 100 requests of 100ms, 5 of them failed. front@v1 is fast, front@v2 slow and failing, cart never reports a latency
+	stats := engine.Snapshot{
+		Latency:   histogram(100, 100*time.Millisecond),
+		Succeeded: 95,
+		Failed:    5,
+		Services: map[string]*engine.ServiceSnapshot{
+			"front@v1": {Calls: 90, Latency: histogram(90, 10*time.Millisecond)},
+			"front@v2": {Calls: 10, Failed: 5, Latency: histogram(10, time.Second)},
+			"cart":     {Calls: 20},
+		},
+	}
+	intPtr := func(i int) *int { return &i }
+	duration := func(d time.Duration) *metav1.Duration { return &metav1.Duration{Duration: d} }
+	tests := []struct {
+		name      string
+		assertion microsimv1alpha1.Assertion
+		// stats replace the statistics of the run when they're set
+		stats *engine.Snapshot
+		want  microsimv1alpha1.AssertionResult
+	}{
+		{
+			name:      "error rate at the limit",
+			assertion: microsimv1alpha1.Assertion{MaxErrorRate: intPtr(5)},
+			want:      microsimv1alpha1.AssertionResult{Name: "error rate <= 5%", Passed: true},
+		},
+		{
+			name:      "error rate over the limit",
+			assertion: microsimv1alpha1.Assertion{MaxErrorRate: intPtr(4)},
+			want:      microsimv1alpha1.AssertionResult{Name: "error rate <= 4%", Message: "5.0% of 100 requests failed"},
+		},
+		{
+			name:      "error rate without requests",
+			assertion: microsimv1alpha1.Assertion{MaxErrorRate: intPtr(100)},
+			stats:     &engine.Snapshot{},
+			want:      microsimv1alpha1.AssertionResult{Name: "error rate <= 100%", Message: "0.0% of 0 requests failed"},
+		},
+		{
+			name:      "latency",
+			assertion: microsimv1alpha1.Assertion{Name: "fast", MaxLatency: duration(200 * time.Millisecond), Percentile: "p50"},
+			want:      microsimv1alpha1.AssertionResult{Name: "fast", Passed: true},
+		},
+		{
+			name:      "max errors",
+			assertion: microsimv1alpha1.Assertion{MaxErrors: intPtr(0)},
+			want:      microsimv1alpha1.AssertionResult{Name: "errors <= 0", Message: "5 of 100 requests failed"},
+		},
+		{
+			name:      "every version of a service",
+			assertion: microsimv1alpha1.Assertion{Service: "front", MaxErrorRate: intPtr(5)},
+			want:      microsimv1alpha1.AssertionResult{Name: "front error rate <= 5%", Passed: true},
+		},
+		{
+			name:      "a single version",
+			assertion: microsimv1alpha1.Assertion{Service: "front@v2", MaxErrorRate: intPtr(5), MaxLatency: duration(500 * time.Millisecond)},
+			want: microsimv1alpha1.AssertionResult{
+				Name:    "front@v2 error rate <= 5%, p99 <= 500ms",
+				Message: "50.0% of 10 calls to front@v2 failed, p99 latency of 10 calls to front@v2 is 1s",
+			},
+		},
+		{
+			name:      "latency of a service that doesn't report it",
+			assertion: microsimv1alpha1.Assertion{Service: "cart", MaxLatency: duration(time.Second)},
+			want:      microsimv1alpha1.AssertionResult{Name: "cart p99 <= 1s", Message: "p99 latency of 0 calls to cart is 0s"},
+		},
+		{
+			name:      "called",
+			assertion: microsimv1alpha1.Assertion{Called: "cart"},
+			want:      microsimv1alpha1.AssertionResult{Name: "cart is called", Passed: true},
+		},
+		{
+			name:      "never called",
+			assertion: microsimv1alpha1.Assertion{Called: "payment"},
+			want:      microsimv1alpha1.AssertionResult{Name: "payment is called", Message: "payment was never called"},
+		},
+		{
+			name:      "nothing to check",
+			assertion: microsimv1alpha1.Assertion{Name: "empty"},
+			want:      microsimv1alpha1.AssertionResult{Name: "empty", Message: "The assertion doesn't check anything"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := stats
+			if tt.stats != nil {
+				s = *tt.stats
+			}
+			if got := checkAssertion(tt.assertion, s); got != tt.want {
+				t.Errorf("checkAssertion() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchesService(t *testing.T) {
+	tests := []struct {
+		designation string
+		service     string
+		want        bool
+	}{
+		{designation: "front", service: "front", want: true},
+		{designation: "front@v1", service: "front", want: true},
+		{designation: "front@v1", service: "front@v1", want: true},
+		{designation: "front@v2", service: "front@v1"},
+		{designation: "front", service: "front@v1"},
+		{designation: "frontend@v1", service: "front"},
+	}
+	for _, tt := range tests {
+		if got := matchesService(tt.designation, tt.service); got != tt.want {
+			t.Errorf("matchesService(%q, %q) = %v, want %v", tt.designation, tt.service, got, tt.want)
+		}
+	}
+}
+
+func TestPassedCondition(t *testing.T) {
+	passing := []microsimv1alpha1.AssertionResult{{Name: "a", Passed: true}, {Name: "b", Passed: true}}
+	failing := []microsimv1alpha1.AssertionResult{{Name: "a", Passed: true}, {Name: "b"}, {Name: "c"}}
+	tests := []struct {
+		name     string
+		results  []microsimv1alpha1.AssertionResult
+		finished bool
+		status   metav1.ConditionStatus
+		reason   string
+		message  string
+	}{
+		{name: "holding while running", results: passing, status: metav1.ConditionUnknown, reason: microsimv1alpha1.ReasonRunning},
+		{name: "failing while running", results: failing, status: metav1.ConditionUnknown, reason: microsimv1alpha1.ReasonAssertionsFailing, message: "b; c"},
+		{name: "passed", results: passing, finished: true, status: metav1.ConditionTrue, reason: microsimv1alpha1.ReasonAssertionsPassed},
+		{name: "failed", results: failing, finished: true, status: metav1.ConditionFalse, reason: microsimv1alpha1.ReasonAssertionsFailed, message: "b; c"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := passedCondition(tt.results, tt.finished, 3)
+			if got.Type != microsimv1alpha1.ConditionPassed || got.Status != tt.status || got.Reason != tt.reason || got.ObservedGeneration != 3 {
+				t.Errorf("passedCondition() = %s %s (%s) of generation %d, want %s (%s) of generation 3", got.Type, got.Status, got.Reason, got.ObservedGeneration, tt.status, tt.reason)
+			}
+			if !strings.Contains(got.Message, tt.message) {
+				t.Errorf("message = %q, want it to name %q", got.Message, tt.message)
+			}
+		})
+	}
+}
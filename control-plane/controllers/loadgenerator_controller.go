@@ -17,38 +17,52 @@ limitations under the License.
 package controllers
 
 import (
-	"bytes"
 	"context"
-	"crypto/md5"
-	"encoding/hex"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"github.com/google/uuid"
-	"io/ioutil"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
-	"math/rand"
-	"net/http"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
-	"strings"
-	"time"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	microsimv1alpha1 "github.com/MrSupiri/MicroSim/api/v1alpha1"
+	"github.com/MrSupiri/MicroSim/engine"
+	"github.com/MrSupiri/MicroSim/worker"
+)
+
+const (
+	// reportInterval is how often the reports of the workers are added up into the status while they run
+	reportInterval = 5 * time.Second
+	// workerGracePeriod is how long a shard is given past its share of the timeout to report before it's stopped
+	workerGracePeriod = time.Minute
 )
 
 // LoadGeneratorReconciler reconciles a LoadGenerator object
 type LoadGeneratorReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
-}
 
-type requestStatus struct {
-	Response      map[string]microsimv1alpha1.Responses
-	ResponseTimes time.Duration
+	// ResultsDir is where samples spilled to the results store and exported results are written
+	ResultsDir string
+	// WorkerImage is the image of the worker pods, it has to contain the /worker binary
+	WorkerImage string
+	// Recorder emits the Events of finished runs
+	Recorder record.EventRecorder
 }
 
 func eventFilter() predicate.Predicate {
@@ -63,15 +77,45 @@ func eventFilter() predicate.Predicate {
 // SetupWithManager sets up the controller with the Manager.
 func (r *LoadGeneratorReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&microsimv1alpha1.LoadGenerator{}).
-		WithEventFilter(eventFilter()).
+		For(&microsimv1alpha1.LoadGenerator{}, builder.WithPredicates(eventFilter())).
+		// Workers finishing or failing are picked up right away, the reports are read every reportInterval
+		Owns(&batchv1.Job{}).
+		Watches(&source.Kind{Type: &microsimv1alpha1.Simulation{}}, handler.EnqueueRequestsFromMapFunc(r.loadGeneratorsOf),
+			builder.WithPredicates(eventFilter())).
 		Complete(r)
 }
 
+// loadGeneratorsOf maps a simulation to the load generators referencing it, so they
+// are paused and resumed along with the simulation
+func (r *LoadGeneratorReconciler) loadGeneratorsOf(simulation client.Object) []reconcile.Request {
+	var loadGeneratorList microsimv1alpha1.LoadGeneratorList
+	if err := r.List(context.Background(), &loadGeneratorList); err != nil {
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, loadGenerator := range loadGeneratorList.Items {
+		ref := loadGenerator.Spec.SimulationRef
+		if ref.Name == simulation.GetName() && ref.Namespace == simulation.GetNamespace() {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{
+				Namespace: loadGenerator.GetNamespace(),
+				Name:      loadGenerator.GetName(),
+			}})
+		}
+	}
+	return requests
+}
+
 //+kubebuilder:rbac:groups=microsim.isala.me,resources=loadgenerators,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=microsim.isala.me,resources=loadgenerators/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=microsim.isala.me,resources=loadgenerators/finalizers,verbs=update
 //+kubebuilder:rbac:groups=microsim.isala.me,resources=simulations,verbs=get;list;watch
+//+kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch;create;update;patch
+//+kubebuilder:rbac:groups=core,resources=serviceaccounts,verbs=get;create
+//+kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
+//+kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;delete
+//+kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=rolebindings,verbs=get;create
+//+kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=clusterroles,verbs=bind,resourceNames=microsim-worker
 
 func (r *LoadGeneratorReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	logger := log.FromContext(ctx)
@@ -80,186 +124,240 @@ func (r *LoadGeneratorReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 
 	var loadGenerator microsimv1alpha1.LoadGenerator
 	if err := r.Get(ctx, req.NamespacedName, &loadGenerator); err != nil {
+		// The workers and their reports are owned by the load generator and removed with it
+		if apierrors.IsNotFound(err) {
+			loadGeneratorCollector.forget(req.NamespacedName)
+		}
 		return ctrl.Result{Requeue: false}, client.IgnoreNotFound(err)
 	}
-	ctx = context.WithValue(ctx, "loadgenerator", loadGenerator)
 
 	var simulation microsimv1alpha1.Simulation
-	if err := r.Get(ctx, types.NamespacedName{
-		Namespace: loadGenerator.Spec.SimulationRef.Namespace,
-		Name:      loadGenerator.Spec.SimulationRef.Name,
-	}, &simulation); err != nil {
+	if err := r.Get(ctx, loadGenerator.Spec.SimulationRef.NamespacedName(), &simulation); err != nil {
 		return ctrl.Result{}, err
 	}
-	ctx = context.WithValue(ctx, "simulation", simulation)
 
-	// Stop if the request count is met
-	if loadGenerator.Spec.RequestCount != nil {
-		if loadGenerator.Status.DoneRequests >= *loadGenerator.Spec.RequestCount {
-			logger.V(1).Info("request count met, stopping load generator")
-			return ctrl.Result{Requeue: false}, nil
+	// Don't send any load while the simulation is suspended, resuming it will trigger a reconcile
+	if simulation.Spec.Suspend != loadGenerator.Status.Paused {
+		if err := r.writeStatus(ctx, req.NamespacedName, func(status *microsimv1alpha1.LoadGeneratorStatus) {
+			status.Paused = simulation.Spec.Suspend
+			now := metav1.Now()
+			switch {
+			case status.Paused:
+				status.PausedAt = &now
+			case status.PausedAt != nil:
+				status.PausedFor.Duration += now.Sub(status.PausedAt.Time)
+				status.PausedAt = nil
+			}
+		}); err != nil {
+			return ctrl.Result{}, err
 		}
+		loadGenerator.Status.Paused = simulation.Spec.Suspend
 	}
-
-	// Stop if time out was passed
-	if loadGenerator.Spec.Timeout != nil {
-		timeout := loadGenerator.ObjectMeta.CreationTimestamp.Add(loadGenerator.Spec.Timeout.Duration)
-		if loadGenerator.ObjectMeta.CreationTimestamp.After(timeout) {
-			logger.V(1).Info("timeout reached, stopping load generator")
-			return ctrl.Result{Requeue: false}, nil
-		}
+	if simulation.Spec.Suspend {
+		logger.V(1).Info("simulation is suspended, pausing load generator")
+		return ctrl.Result{Requeue: false}, r.deleteWorkers(ctx, &loadGenerator)
 	}
 
-	for _, request := range loadGenerator.Spec.Routes {
-		var route microsimv1alpha1.Route
-		if err := json.Unmarshal([]byte(request), &route); err != nil {
-			logger.Error(err, "error while decoding request spec")
-		}
-		route = overwriteDesignations(ctx, route)
-		// Send all the requests in background thread
-		// This is done because some request may take 10+ seconds and that value will be added spec.delayBetween
-		go r.processRequests(ctx, req, route)
+	// Workers of an older generation are replaced by the ones of the current spec
+	if err := r.deleteStaleWorkers(ctx, &loadGenerator); err != nil {
+		return ctrl.Result{}, err
 	}
 
-	logger.V(1).Info(fmt.Sprintf("requeuing in %s", loadGenerator.Spec.BetweenDelay.Duration))
-	return ctrl.Result{RequeueAfter: loadGenerator.Spec.BetweenDelay.Duration}, nil
-}
-
-func (r *LoadGeneratorReconciler) processRequests(ctx context.Context, req ctrl.Request, payload microsimv1alpha1.Route) {
-	logger := log.FromContext(ctx)
-	loadGenerator := ctx.Value("loadgenerator").(microsimv1alpha1.LoadGenerator)
-
-	results := make(chan *requestStatus, loadGenerator.Spec.Replicas)
-	for i := 0; i < loadGenerator.Spec.Replicas; i++ {
-		// Run this on the background
-		go r.forwardRequest(ctx, payload, results)
+	// Don't start over once this generation finished
+	finished := meta.FindStatusCondition(loadGenerator.Status.Conditions, microsimv1alpha1.ConditionFinished)
+	if finished != nil && finished.Status == metav1.ConditionTrue && finished.ObservedGeneration == loadGenerator.Generation {
+		logger.V(1).Info("load generator finished", "reason", finished.Reason)
+		if err := r.deleteWorkers(ctx, &loadGenerator); err != nil {
+			return ctrl.Result{}, err
+		}
+		return r.deliverNotifications(ctx, &loadGenerator)
 	}
 
-	// Merge the responses
-	var responseTime time.Duration
-	responses := make(map[string]microsimv1alpha1.Responses)
-	for i := 0; i < loadGenerator.Spec.Replicas; i++ {
-		if res := <-results; res != nil {
-			responseTime += res.ResponseTimes
-			for s, m := range res.Response {
-				responses[s] = m
+	// Check the spec before launching the workers, an invalid spec won't get fixed by retrying
+	var templates []microsimv1alpha1.RouteTemplate
+	if replay := loadGenerator.Spec.Replay; replay != nil {
+		// A replay starts once the run it replays finished recording
+		if replay.From != nil && (finished == nil || finished.ObservedGeneration != loadGenerator.Generation) {
+			ready, err := r.recordingReady(ctx, &loadGenerator, *replay.From)
+			if errors.Is(err, errNotRecorded) {
+				logger.Error(err, "invalid replay")
+				return ctrl.Result{Requeue: false}, nil
+			}
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+			if !ready {
+				logger.Info("waiting for the recorded run to finish", "load generator", replay.From.Name)
+				return ctrl.Result{RequeueAfter: reportInterval}, nil
 			}
 		}
-	}
-
-	// Fetch new status because one we have might be outdated
-	var newLoadGenerator microsimv1alpha1.LoadGenerator
-	if err := r.Get(ctx, req.NamespacedName, &newLoadGenerator); err != nil {
-		logger.Error(err, "failed fetch load generator", "namespacedName", req.NamespacedName)
-		return
-	}
-
-	newLoadGenerator.Status.DoneRequests += 1
-	newLoadGenerator.Status.TotalResponseTime.Duration += responseTime
-	if newLoadGenerator.Status.Responses == nil {
-		newLoadGenerator.Status.Responses = responses
 	} else {
-		for s, m := range responses {
-			newLoadGenerator.Status.Responses[s] = m
+		var err error
+		if templates, err = loadGenerator.Spec.RouteTemplates(); err != nil {
+			logger.Error(err, "error while decoding request spec")
+			return ctrl.Result{Requeue: false}, nil
+		}
+		if err := engine.CheckTemplates(templates); err != nil {
+			logger.Error(err, "error while expanding route templates")
+			return ctrl.Result{Requeue: false}, nil
+		}
+		if err := engine.CheckScenarios(templates, loadGenerator.Spec.Scenarios); err != nil {
+			logger.Error(err, "invalid scenarios")
+			return ctrl.Result{Requeue: false}, nil
 		}
 	}
-
-	// Coz why not
-	newLoadGenerator.Status.Replicas = newLoadGenerator.Spec.Replicas
-
-	// Update the status
-	// State here is only should be to get rough idea
-	// If a race condition was met this update will be ignored
-	if err := r.Status().Update(ctx, &newLoadGenerator); err != nil {
-		logger.Error(err, "failed to update load generator status")
+	shards := shardsOf(loadGenerator.Spec)
+	if _, err := worker.EngineConfig(loadGenerator.Spec, len(templates), 0, shards); err != nil {
+		logger.Error(err, "invalid load model")
+		return ctrl.Result{Requeue: false}, nil
 	}
 
-}
-
-func (r *LoadGeneratorReconciler) forwardRequest(ctx context.Context, route microsimv1alpha1.Route, results chan *requestStatus) {
-	logger := log.FromContext(ctx)
-	responses := make(map[string]microsimv1alpha1.Responses)
-	startedTime := time.Now()
-
-	//for i, r2 := range route.Routes {
-	logger.V(1).Info("sending request", "designation", route.Designation)
-	reqBody, err := json.Marshal(route)
-	if err != nil {
-		logger.Error(err, "failed encoded request route #%d", "route", route)
-		results <- nil
-		return
+	// The workers count the timeout from the start time so it has to be set before they're launched,
+	// a new generation is a new run
+	if loadGenerator.Status.StartTime == nil || (finished != nil && finished.ObservedGeneration != loadGenerator.Generation) {
+		now := metav1.Now()
+		// The seed is kept for the whole run so restarted workers sample the same routes
+		seed := loadGenerator.Spec.Seed
+		if seed == nil {
+			random := now.UnixNano()
+			seed = &random
+		}
+		if err := r.writeStatus(ctx, req.NamespacedName, func(status *microsimv1alpha1.LoadGeneratorStatus) {
+			status.StartTime = &now
+			status.PausedFor = metav1.Duration{}
+			status.Seed = seed
+			meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+				Type:               microsimv1alpha1.ConditionFinished,
+				Status:             metav1.ConditionFalse,
+				ObservedGeneration: loadGenerator.Generation,
+				Reason:             microsimv1alpha1.ReasonRunning,
+				Message:            "Sending requests",
+			})
+		}); err != nil {
+			return ctrl.Result{}, err
+		}
+		loadGenerator.Status.StartTime = &now
+		loadGenerator.Status.Seed = seed
 	}
 
-	httpClient := http.Client{
-		Transport: &http.Transport{DisableKeepAlives: true},
-	}
-	defer httpClient.CloseIdleConnections()
-	req, err := http.NewRequest("POST", route.Designation, bytes.NewBuffer(reqBody))
-	if err != nil {
-		logger.Error(err, "failed send the request to designation", "designation", route.Designation)
-		results <- nil
-		return
+	if err := r.provisionWorkerAccess(ctx, &loadGenerator); err != nil {
+		return ctrl.Result{}, err
 	}
-	reqID := uuid.New()
-	req.Header = http.Header{
-		"Content-Type": []string{"application/json"},
-		"X-Request-ID": []string{reqID.String()},
+	jobs, err := r.provisionWorkers(ctx, &loadGenerator, shards)
+	if errors.Is(err, errStaleWorker) {
+		// The Jobs of the previous run are deleted in the background, the reconcile after picks up from here
+		logger.V(1).Info("waiting for the workers of the previous generation to be deleted", "reason", err.Error())
+		return ctrl.Result{RequeueAfter: time.Second}, nil
 	}
-
-	resp, err := httpClient.Do(req)
 	if err != nil {
-		logger.Error(err, "failed send the request to designation", "designation", route.Designation)
-		results <- nil
-		return
+		return ctrl.Result{}, err
 	}
-	defer resp.Body.Close()
-
-	buf, err := ioutil.ReadAll(resp.Body)
 
+	result, err := r.collectReports(ctx, &loadGenerator, shards, jobs)
 	if err != nil {
-		logger.Error(err, "failed decoded response for route", "route", route)
-		results <- nil
-		return
+		return ctrl.Result{}, err
 	}
-
-	// Store only unique requests and responses
-	reqRespHash := GetMD5Hash(append(buf, reqBody...))
-
-	responses[reqRespHash] = microsimv1alpha1.Responses{
-		Response: string(buf),
-		Request:  string(reqBody),
+	loadGeneratorCollector.set(req.NamespacedName, result.stats, result.inFlight, result.finished)
+	var status *microsimv1alpha1.LoadGeneratorStatus
+	if result.finished {
+		logger.Info("load generator finished", "reason", result.condition.Reason)
+		if err := r.deleteWorkers(ctx, &loadGenerator); err != nil {
+			return ctrl.Result{}, err
+		}
+		// Failures are reported in the Exported condition, the run itself is over either way
+		var failures []string
+		if err := r.collectSpilledSamples(ctx, &loadGenerator, shards); err != nil {
+			logger.Error(err, "failed to collect spilled samples")
+			failures = append(failures, fmt.Sprintf("failed to collect spilled samples: %v", err))
+		}
+		if err := r.collectRecording(ctx, &loadGenerator, shards); err != nil {
+			logger.Error(err, "failed to collect recording")
+			failures = append(failures, fmt.Sprintf("failed to collect recording: %v", err))
+		}
+		result.notifications = newNotifications(loadGenerator.Spec)
+		status = loadGenerator.Status.DeepCopy()
+		result.apply(status)
+		if result.exportedTo, err = r.exportResults(ctx, &loadGenerator, status, shards); err != nil {
+			logger.Error(err, "failed to export results")
+			failures = append(failures, fmt.Sprintf("failed to export results: %v", err))
+		}
+		result.exported = exportedCondition(&loadGenerator, failures)
 	}
-	//}
-
-	results <- &requestStatus{
-		Response:      responses,
-		ResponseTimes: time.Now().Sub(startedTime),
+	if err := r.writeStatus(ctx, req.NamespacedName, result.apply); err != nil {
+		return ctrl.Result{}, err
+	}
+	if result.finished {
+		r.emitFinishedEvent(&loadGenerator, status)
+		// The webhooks are notified from the next reconcile, once the final status is in place
+		return ctrl.Result{Requeue: len(result.notifications) > 0}, nil
 	}
+	return ctrl.Result{RequeueAfter: reportInterval}, nil
 }
 
-func overwriteDesignations(ctx context.Context, route microsimv1alpha1.Route) microsimv1alpha1.Route {
-	logger := log.FromContext(ctx)
-	simulation := ctx.Value("simulation").(microsimv1alpha1.Simulation)
-	var newRoutes []microsimv1alpha1.Route
+// shardsOf returns the number of workers the load is split across
+func shardsOf(spec microsimv1alpha1.LoadGeneratorSpec) int {
+	return worker.Shards(spec)
+}
 
-	if !strings.HasPrefix(route.Designation, "http") {
-		if svc, ok := simulation.Status.Services[formatServiceName(route.Designation, simulation)]; ok {
-			route.Designation = svc.Endpoint
-		} else {
-			logger.V(-1).Info("service name was not found", "service name", route.Designation)
+// setSummary copies the statistics of the run to the status, replacing the ones of the previous flush
+func setSummary(status *microsimv1alpha1.LoadGeneratorStatus, summary engine.Summary) {
+	status.SucceededRequests = int(summary.Succeeded)
+	status.FailedRequests = int(summary.Failed)
+	status.StatusClasses = map[string]int{}
+	for class, count := range summary.StatusClasses {
+		status.StatusClasses[class] = int(count)
+	}
+	status.Errors = map[string]int{}
+	for errorType, count := range summary.Errors {
+		status.Errors[errorType] = int(count)
+	}
+	status.Latency = latencyStats(summary.Latency)
+	status.Services = map[string]microsimv1alpha1.ServiceCallStats{}
+	for designation, service := range summary.Services {
+		stats := microsimv1alpha1.ServiceCallStats{
+			Calls:         int(service.Calls),
+			Errors:        int(service.Failed),
+			NotCalled:     int(service.Skipped),
+			ErrorMessages: map[string]int{},
+		}
+		for message, count := range service.ErrorMessages {
+			stats.ErrorMessages[message] = int(count)
 		}
+		if service.Latency != nil {
+			stats.Latency = latencyStats(*service.Latency)
+		}
+		status.Services[designation] = stats
 	}
-
-	for _, p := range route.Routes {
-		if rand.Intn(100) <= route.Probability {
-			newRoutes = append(newRoutes, overwriteDesignations(ctx, p))
+	status.Templates = map[string]microsimv1alpha1.TemplateStats{}
+	for name, template := range summary.Templates {
+		status.Templates[name] = microsimv1alpha1.TemplateStats{
+			Requests: int(template.Requests),
+			Errors:   int(template.Failed),
+			Latency:  latencyStats(template.Latency),
 		}
 	}
-	route.Routes = newRoutes
-	return route
+	status.Scenarios = nil
+	if len(summary.Scenarios) > 0 {
+		status.Scenarios = map[string]microsimv1alpha1.ScenarioStats{}
+	}
+	for name, scenario := range summary.Scenarios {
+		status.Scenarios[name] = microsimv1alpha1.ScenarioStats{
+			Journeys: int(scenario.Journeys),
+			Failed:   int(scenario.Failed),
+			Duration: latencyStats(scenario.Duration),
+		}
+	}
+	status.Throughput = resource.NewMilliQuantity(int64(summary.Throughput*1000), resource.DecimalSI)
+	status.Summary = summary.String()
 }
 
-func GetMD5Hash(input []byte) string {
-	hash := md5.Sum(input)
-	return hex.EncodeToString(hash[:])
+func latencyStats(latency engine.Latency) *microsimv1alpha1.LatencyStats {
+	return &microsimv1alpha1.LatencyStats{
+		P50:  metav1.Duration{Duration: latency.P50},
+		P90:  metav1.Duration{Duration: latency.P90},
+		P95:  metav1.Duration{Duration: latency.P95},
+		P99:  metav1.Duration{Duration: latency.P99},
+		Max:  metav1.Duration{Duration: latency.Max},
+		Mean: metav1.Duration{Duration: latency.Mean},
+	}
 }
@@ -0,0 +1,39 @@
+package controllers
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	microsimv1alpha1 "github.com/MrSupiri/MicroSim/api/v1alpha1"
+)
+
+func TestLoadGeneratorsOf(t *testing.T) {
+	loadGenerator := func(namespace, name, simulationNamespace, simulation string) *microsimv1alpha1.LoadGenerator {
+		return &microsimv1alpha1.LoadGenerator{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+			Spec: microsimv1alpha1.LoadGeneratorSpec{
+				SimulationRef: microsimv1alpha1.SimulationRef{Namespace: simulationNamespace, Name: simulation},
+			},
+		}
+	}
+	r := &LoadGeneratorReconciler{Client: fake.NewClientBuilder().WithScheme(testScheme(t)).WithObjects(
+		loadGenerator("default", "browse", "default", "shop"),
+		loadGenerator("load", "checkout", "default", "shop"),
+		loadGenerator("default", "other", "default", "bank"),
+		loadGenerator("default", "elsewhere", "staging", "shop"),
+	).Build()}
+
+	requests := r.loadGeneratorsOf(&microsimv1alpha1.Simulation{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "shop"}})
+	var got []string
+	for _, request := range requests {
+		got = append(got, request.String())
+	}
+	sort.Strings(got)
+	if want := []string{"default/browse", "load/checkout"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("loadGeneratorsOf() = %v, want %v", got, want)
+	}
+}
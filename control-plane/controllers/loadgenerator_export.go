@@ -0,0 +1,365 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	microsimv1alpha1 "github.com/MrSupiri/MicroSim/api/v1alpha1"
+	"github.com/MrSupiri/MicroSim/worker"
+)
+
+// Files the results of a run are exported to
+const (
+	summaryFile    = "summary.json"
+	recordsCSVFile = "records.csv"
+	junitFile      = "junit.xml"
+)
+
+// runSummary is the content of summary.json
+type runSummary struct {
+	Name       string       `json:"name"`
+	Namespace  string       `json:"namespace"`
+	Generation int64        `json:"generation"`
+	Seed       *int64       `json:"seed,omitempty"`
+	StartTime  *metav1.Time `json:"startTime,omitempty"`
+	FinishTime metav1.Time  `json:"finishTime"`
+	// Reason and Message are the ones of the Finished condition
+	Reason  string `json:"reason"`
+	Message string `json:"message,omitempty"`
+	// Passed is the verdict of the assertions, it's left out when there are none
+	Passed        *bool                                        `json:"passed,omitempty"`
+	Requests      int                                          `json:"requests"`
+	Succeeded     int                                          `json:"succeeded"`
+	Failed        int                                          `json:"failed"`
+	StatusClasses map[string]int                               `json:"statusClasses,omitempty"`
+	Errors        map[string]int                               `json:"errors,omitempty"`
+	Latency       *microsimv1alpha1.LatencyStats               `json:"latency,omitempty"`
+	Services      map[string]microsimv1alpha1.ServiceCallStats `json:"services,omitempty"`
+	Templates     map[string]microsimv1alpha1.TemplateStats    `json:"templates,omitempty"`
+	Assertions    []microsimv1alpha1.AssertionResult           `json:"assertions,omitempty"`
+	Warmup        *microsimv1alpha1.WarmupStatus               `json:"warmup,omitempty"`
+	// Records is the number of requests in records.jsonl and records.csv
+	Records int `json:"records"`
+}
+
+// exportResults writes the results of a finished run where the spec asks for them, status is the
+// final status of the run. It returns where they were written
+func (r *LoadGeneratorReconciler) exportResults(ctx context.Context, loadGenerator *microsimv1alpha1.LoadGenerator, status *microsimv1alpha1.LoadGeneratorStatus, shards int) (string, error) {
+	export := loadGenerator.Spec.Export
+	if export == nil {
+		return "", nil
+	}
+	formats := map[microsimv1alpha1.ExportFormat]bool{}
+	for _, format := range export.Formats {
+		formats[format] = true
+	}
+	if len(formats) == 0 {
+		formats = map[microsimv1alpha1.ExportFormat]bool{
+			microsimv1alpha1.JSONLinesExport: true,
+			microsimv1alpha1.CSVExport:       true,
+			microsimv1alpha1.JUnitExport:     true,
+		}
+	}
+
+	records, err := r.collectRecords(ctx, loadGenerator, shards)
+	if err != nil {
+		return "", err
+	}
+	summary := summarize(loadGenerator, status)
+
+	files := map[string][]byte{}
+	if formats[microsimv1alpha1.JUnitExport] {
+		if files[junitFile], err = junitReport(loadGenerator, status, summary.FinishTime.Time); err != nil {
+			return "", err
+		}
+	}
+	// A ConfigMap has to fit in 1MiB, the last records are left out until it does
+	budget := -1
+	if export.Type != microsimv1alpha1.ResultsStoreExport {
+		budget = worker.MaxSpillSize - len(files[junitFile])
+	}
+	for {
+		summary.Records = len(records)
+		if files[summaryFile], err = json.MarshalIndent(summary, "", "  "); err != nil {
+			return "", err
+		}
+		size := len(files[summaryFile])
+		if formats[microsimv1alpha1.JSONLinesExport] {
+			if files[worker.RecordsKey], err = encodeJSONLines(records); err != nil {
+				return "", err
+			}
+			size += len(files[worker.RecordsKey])
+		}
+		if formats[microsimv1alpha1.CSVExport] {
+			if files[recordsCSVFile], err = encodeCSV(records); err != nil {
+				return "", err
+			}
+			size += len(files[recordsCSVFile])
+		}
+		if budget < 0 || size <= budget || len(records) == 0 {
+			break
+		}
+		keep := len(records) * budget / size
+		if keep >= len(records) {
+			keep = len(records) - 1
+		}
+		records = records[:keep]
+	}
+
+	if export.Type != microsimv1alpha1.ResultsStoreExport {
+		return r.exportToConfigMap(ctx, loadGenerator, files)
+	}
+	if r.ResultsDir == "" {
+		return "", fmt.Errorf("the controller was started without a results directory")
+	}
+	dir := filepath.Join(r.ResultsDir, loadGenerator.Namespace, loadGenerator.Name)
+	for name, data := range files {
+		if err := writeResultsFile(filepath.Join(dir, name), data); err != nil {
+			return "", err
+		}
+	}
+	return dir, nil
+}
+
+// exportedCondition returns the Exported condition of a finished run from what failed to be written, it's
+// nil when the spec doesn't write anything outside the status
+func exportedCondition(loadGenerator *microsimv1alpha1.LoadGenerator, failures []string) *metav1.Condition {
+	spec := loadGenerator.Spec
+	if spec.Export == nil && spec.Record == nil && (spec.Sampling == nil || spec.Sampling.Spill == nil) {
+		return nil
+	}
+	condition := &metav1.Condition{
+		Type:               microsimv1alpha1.ConditionExported,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: loadGenerator.Generation,
+		Reason:             microsimv1alpha1.ReasonResultsWritten,
+		Message:            "The results of the run were written",
+	}
+	if len(failures) > 0 {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = microsimv1alpha1.ReasonExportFailed
+		condition.Message = strings.Join(failures, "; ")
+	}
+	return condition
+}
+
+// collectRecords reads the records of every shard, ordered by the time they were sent
+func (r *LoadGeneratorReconciler) collectRecords(ctx context.Context, loadGenerator *microsimv1alpha1.LoadGenerator, shards int) ([]worker.Record, error) {
+	var records []worker.Record
+	for shard := 0; shard < shards; shard++ {
+		var configMap v1.ConfigMap
+		err := r.Get(ctx, types.NamespacedName{Namespace: loadGenerator.Namespace, Name: worker.RecordsName(loadGenerator.Name, shard)}, &configMap)
+		if err != nil {
+			if client.IgnoreNotFound(err) != nil {
+				return nil, err
+			}
+			continue
+		}
+		shardRecords, err := worker.DecodeRecords(configMap.Data[worker.RecordsKey])
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode records of %s: %w", configMap.Name, err)
+		}
+		records = append(records, shardRecords...)
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Time.Before(records[j].Time)
+	})
+	return records, nil
+}
+
+func (r *LoadGeneratorReconciler) exportToConfigMap(ctx context.Context, loadGenerator *microsimv1alpha1.LoadGenerator, files map[string][]byte) (string, error) {
+	configMap := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-results", loadGenerator.Name),
+			Namespace: loadGenerator.Namespace,
+		},
+	}
+	_, err := controllerutil.CreateOrUpdate(ctx, r.Client, configMap, func() error {
+		configMap.Labels = worker.Labels(configMap.Name, loadGenerator)
+		configMap.Data = map[string]string{}
+		for name, data := range files {
+			configMap.Data[name] = string(data)
+		}
+		return ctrl.SetControllerReference(loadGenerator, configMap, r.Scheme)
+	})
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("configmap/%s/%s", configMap.Namespace, configMap.Name), nil
+}
+
+func summarize(loadGenerator *microsimv1alpha1.LoadGenerator, status *microsimv1alpha1.LoadGeneratorStatus) runSummary {
+	summary := runSummary{
+		Name:          loadGenerator.Name,
+		Namespace:     loadGenerator.Namespace,
+		Generation:    loadGenerator.Generation,
+		Seed:          status.Seed,
+		StartTime:     status.StartTime,
+		FinishTime:    metav1.Now(),
+		Requests:      status.DoneRequests,
+		Succeeded:     status.SucceededRequests,
+		Failed:        status.FailedRequests,
+		StatusClasses: status.StatusClasses,
+		Errors:        status.Errors,
+		Latency:       status.Latency,
+		Services:      status.Services,
+		Templates:     status.Templates,
+		Assertions:    status.Assertions,
+		Warmup:        status.Warmup,
+	}
+	if finished := meta.FindStatusCondition(status.Conditions, microsimv1alpha1.ConditionFinished); finished != nil {
+		summary.Reason, summary.Message = finished.Reason, finished.Message
+		if finished.Status == metav1.ConditionTrue {
+			summary.FinishTime = finished.LastTransitionTime
+		}
+	}
+	if passed := meta.FindStatusCondition(status.Conditions, microsimv1alpha1.ConditionPassed); passed != nil {
+		ok := passed.Status == metav1.ConditionTrue
+		summary.Passed = &ok
+	}
+	return summary
+}
+
+func encodeJSONLines(records []worker.Record) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// encodeCSV writes the records with a header, the called paths of the route are separated by spaces
+func encodeCSV(records []worker.Record) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	if err := writer.Write([]string{"time", "shard", "sequence", "template", "latency_ms", "status_code", "error", "route", "warmup", "scenario", "step"}); err != nil {
+		return nil, err
+	}
+	for _, record := range records {
+		if err := writer.Write([]string{
+			record.Time.Format(time.RFC3339Nano),
+			strconv.Itoa(record.Shard),
+			strconv.FormatUint(record.Sequence, 10),
+			record.Template,
+			strconv.FormatFloat(record.LatencyMs, 'f', 3, 64),
+			strconv.Itoa(record.StatusCode),
+			record.Error,
+			strings.Join(record.Route, " "),
+			strconv.FormatBool(record.Warmup),
+			record.Scenario,
+			strconv.Itoa(record.Step),
+		}); err != nil {
+			return nil, err
+		}
+	}
+	writer.Flush()
+	return buf.Bytes(), writer.Error()
+}
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name       string          `xml:"name,attr"`
+	Tests      int             `xml:"tests,attr"`
+	Failures   int             `xml:"failures,attr"`
+	Time       string          `xml:"time,attr"`
+	Timestamp  string          `xml:"timestamp,attr,omitempty"`
+	Properties []junitProperty `xml:"properties>property,omitempty"`
+	Cases      []junitTestCase `xml:"testcase"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// junitReport writes the run as a test suite, the run itself is a test case that fails unless it completed
+// or reached its timeout and every assertion is a test case of its own
+func junitReport(loadGenerator *microsimv1alpha1.LoadGenerator, status *microsimv1alpha1.LoadGeneratorStatus, finishTime time.Time) ([]byte, error) {
+	className := fmt.Sprintf("%s.%s", loadGenerator.Namespace, loadGenerator.Name)
+	suite := junitTestSuite{Name: className, Time: "0"}
+	if status.StartTime != nil {
+		suite.Time = fmt.Sprintf("%.3f", finishTime.Sub(status.StartTime.Time).Seconds())
+		suite.Timestamp = status.StartTime.UTC().Format("2006-01-02T15:04:05")
+	}
+	if status.Seed != nil {
+		suite.Properties = append(suite.Properties, junitProperty{Name: "seed", Value: strconv.FormatInt(*status.Seed, 10)})
+	}
+	suite.Properties = append(suite.Properties, junitProperty{Name: "generation", Value: strconv.FormatInt(loadGenerator.Generation, 10)})
+
+	run := junitTestCase{Name: "run", ClassName: className, Time: suite.Time}
+	if finished := meta.FindStatusCondition(status.Conditions, microsimv1alpha1.ConditionFinished); finished != nil &&
+		finished.Reason != microsimv1alpha1.ReasonCompleted && finished.Reason != microsimv1alpha1.ReasonTimeout {
+		run.Failure = &junitFailure{Message: finished.Message, Type: finished.Reason, Text: finished.Message}
+	}
+	suite.Cases = append(suite.Cases, run)
+	for _, assertion := range status.Assertions {
+		testCase := junitTestCase{Name: assertion.Name, ClassName: className, Time: "0"}
+		if !assertion.Passed {
+			testCase.Failure = &junitFailure{Message: assertion.Message, Type: microsimv1alpha1.ReasonAssertionsFailed, Text: assertion.Message}
+		}
+		suite.Cases = append(suite.Cases, testCase)
+	}
+	for _, testCase := range suite.Cases {
+		suite.Tests++
+		if testCase.Failure != nil {
+			suite.Failures++
+		}
+	}
+
+	data, err := xml.MarshalIndent(junitTestSuites{Suites: []junitTestSuite{suite}}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(data, '\n')...), nil
+}
+
+// writeResultsFile writes to a temporary file first so readers never see a partial file
+func writeResultsFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,282 @@
+package controllers
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	microsimv1alpha1 "github.com/MrSupiri/MicroSim/api/v1alpha1"
+	"github.com/MrSupiri/MicroSim/worker"
+)
+
+// recordsConfigMap is the ConfigMap a worker writes its records to
+func recordsConfigMap(t *testing.T, loadGenerator string, shard int, records []worker.Record) *v1.ConfigMap {
+	var lines []string
+	for _, record := range records {
+		line, err := json.Marshal(record)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, string(line))
+	}
+	return &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: worker.RecordsName(loadGenerator, shard)},
+		Data:       map[string]string{worker.RecordsKey: strings.Join(lines, "\n") + "\n"},
+	}
+}
+
+func TestEncodeCSV(t *testing.T) {
+	records := []worker.Record{
+		{Time: time.Date(2021, 6, 1, 10, 0, 0, 0, time.UTC), Shard: 1, Sequence: 7, Template: "browse", LatencyMs: 12.5, StatusCode: 200, Route: []string{"front", "front/cart"}, Warmup: true},
+		{Time: time.Date(2021, 6, 1, 10, 0, 1, 0, time.UTC), Template: "checkout", Scenario: "purchase", Step: 2, Error: `service responded with status 500, "payment" failed`},
+	}
+	data, err := encodeCSV(records)
+	if err != nil {
+		t.Fatalf("encodeCSV() error = %v", err)
+	}
+	rows, err := csv.NewReader(strings.NewReader(string(data))).ReadAll()
+	if err != nil {
+		t.Fatalf("encoded CSV is invalid: %v", err)
+	}
+	want := [][]string{
+		{"time", "shard", "sequence", "template", "latency_ms", "status_code", "error", "route", "warmup", "scenario", "step"},
+		{"2021-06-01T10:00:00Z", "1", "7", "browse", "12.500", "200", "", "front front/cart", "true", "", "0"},
+		{"2021-06-01T10:00:01Z", "0", "0", "checkout", "0.000", "0", `service responded with status 500, "payment" failed`, "", "false", "purchase", "2"},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("rows = %q\nwant %q", rows, want)
+	}
+}
+
+func TestJUnitReport(t *testing.T) {
+	start := metav1.NewTime(time.Date(2021, 6, 1, 10, 0, 0, 0, time.UTC))
+	seed := int64(42)
+	tests := []struct {
+		name       string
+		reason     string
+		assertions []microsimv1alpha1.AssertionResult
+		tests      int
+		failures   int
+	}{
+		{name: "completed", reason: microsimv1alpha1.ReasonCompleted, tests: 1},
+		{name: "timeout", reason: microsimv1alpha1.ReasonTimeout, tests: 1},
+		{name: "aborted", reason: microsimv1alpha1.ReasonErrorRateExceeded, tests: 1, failures: 1},
+		{
+			name:   "assertions",
+			reason: microsimv1alpha1.ReasonCompleted,
+			assertions: []microsimv1alpha1.AssertionResult{
+				{Name: "fast", Passed: true},
+				{Name: "no errors", Message: "3 of 100 requests failed"},
+			},
+			tests:    3,
+			failures: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loadGenerator := &microsimv1alpha1.LoadGenerator{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "browse", Generation: 2}}
+			status := &microsimv1alpha1.LoadGeneratorStatus{
+				StartTime:  &start,
+				Seed:       &seed,
+				Assertions: tt.assertions,
+				Conditions: []metav1.Condition{{Type: microsimv1alpha1.ConditionFinished, Status: metav1.ConditionTrue, Reason: tt.reason, Message: "done"}},
+			}
+			data, err := junitReport(loadGenerator, status, start.Add(90*time.Second))
+			if err != nil {
+				t.Fatalf("junitReport() error = %v", err)
+			}
+			var report junitTestSuites
+			if err := xml.Unmarshal(data, &report); err != nil {
+				t.Fatalf("report is invalid XML: %v", err)
+			}
+			if len(report.Suites) != 1 {
+				t.Fatalf("report has %d suites, want 1", len(report.Suites))
+			}
+			suite := report.Suites[0]
+			if suite.Name != "default.browse" || suite.Time != "90.000" || suite.Timestamp != "2021-06-01T10:00:00" {
+				t.Errorf("suite %s took %s from %s, want default.browse taking 90.000 from 2021-06-01T10:00:00", suite.Name, suite.Time, suite.Timestamp)
+			}
+			if suite.Tests != tt.tests || suite.Failures != tt.failures || len(suite.Cases) != tt.tests {
+				t.Errorf("suite has %d tests (%d cases) and %d failures, want %d and %d", suite.Tests, len(suite.Cases), suite.Failures, tt.tests, tt.failures)
+			}
+			if want := []junitProperty{{Name: "seed", Value: "42"}, {Name: "generation", Value: "2"}}; !reflect.DeepEqual(suite.Properties, want) {
+				t.Errorf("properties = %+v, want %+v", suite.Properties, want)
+			}
+			if run := suite.Cases[0]; run.Name != "run" || (run.Failure != nil) != (tt.reason == microsimv1alpha1.ReasonErrorRateExceeded) {
+				t.Errorf("run case = %+v, want it to fail only when aborted", run)
+			}
+		})
+	}
+}
+
+// manyRecords returns n records of shard with long routes, sent a millisecond apart from start
+func manyRecords(shard int, n int, start time.Time) []worker.Record {
+	route := make([]string, 10)
+	for i := range route {
+		route[i] = fmt.Sprintf("front/service_%d@v1", i)
+	}
+	records := make([]worker.Record, n)
+	for i := range records {
+		records[i] = worker.Record{
+			Time:       start.Add(time.Duration(2*i+shard) * time.Millisecond),
+			Shard:      shard,
+			Sequence:   uint64(i),
+			Template:   "browse",
+			StatusCode: 200,
+			Route:      route,
+		}
+	}
+	return records
+}
+
+func TestExportResults(t *testing.T) {
+	start := time.Date(2021, 6, 1, 10, 0, 0, 0, time.UTC)
+	// Both shards together are well over the size of a ConfigMap
+	shards := [][]worker.Record{manyRecords(0, 2000, start), manyRecords(1, 2000, start)}
+
+	tests := []struct {
+		name    string
+		export  microsimv1alpha1.ExportSpec
+		files   []string
+		trimmed bool
+	}{
+		{
+			name:    "ConfigMap",
+			export:  microsimv1alpha1.ExportSpec{Type: microsimv1alpha1.ConfigMapExport},
+			files:   []string{summaryFile, worker.RecordsKey, recordsCSVFile, junitFile},
+			trimmed: true,
+		},
+		{
+			name:    "only CSV",
+			export:  microsimv1alpha1.ExportSpec{Formats: []microsimv1alpha1.ExportFormat{microsimv1alpha1.CSVExport}},
+			files:   []string{summaryFile, recordsCSVFile},
+			trimmed: true,
+		},
+		{
+			name:   "results store",
+			export: microsimv1alpha1.ExportSpec{Type: microsimv1alpha1.ResultsStoreExport},
+			files:  []string{summaryFile, worker.RecordsKey, recordsCSVFile, junitFile},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loadGenerator := &microsimv1alpha1.LoadGenerator{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "browse", UID: "0123456789"},
+				Spec:       microsimv1alpha1.LoadGeneratorSpec{Export: &tt.export},
+			}
+			builder := fake.NewClientBuilder().WithScheme(testScheme(t))
+			for shard, records := range shards {
+				builder.WithObjects(recordsConfigMap(t, loadGenerator.Name, shard, records))
+			}
+			r := &LoadGeneratorReconciler{Client: builder.Build(), Scheme: testScheme(t), ResultsDir: t.TempDir()}
+			status := &microsimv1alpha1.LoadGeneratorStatus{DoneRequests: 4000}
+
+			location, err := r.exportResults(context.Background(), loadGenerator, status, len(shards))
+			if err != nil {
+				t.Fatalf("exportResults() error = %v", err)
+			}
+
+			files := map[string]string{}
+			if tt.export.Type == microsimv1alpha1.ResultsStoreExport {
+				for _, name := range tt.files {
+					data, err := ioutil.ReadFile(filepath.Join(location, name))
+					if err != nil {
+						t.Fatal(err)
+					}
+					files[name] = string(data)
+				}
+			} else {
+				var configMap v1.ConfigMap
+				if err := r.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: "browse-results"}, &configMap); err != nil {
+					t.Fatal(err)
+				}
+				size := 0
+				for _, data := range configMap.Data {
+					size += len(data)
+				}
+				if size > worker.MaxSpillSize {
+					t.Errorf("results take %d bytes, want at most %d", size, worker.MaxSpillSize)
+				}
+				files = configMap.Data
+			}
+			if len(files) != len(tt.files) {
+				t.Errorf("files = %d, want %v", len(files), tt.files)
+			}
+
+			var summary runSummary
+			if err := json.Unmarshal([]byte(files[summaryFile]), &summary); err != nil {
+				t.Fatal(err)
+			}
+			rows, err := csv.NewReader(strings.NewReader(files[recordsCSVFile])).ReadAll()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if summary.Records != len(rows)-1 || summary.Requests != 4000 {
+				t.Errorf("summary counts %d records of %d requests, the CSV has %d", summary.Records, summary.Requests, len(rows)-1)
+			}
+			if trimmed := summary.Records < 4000; trimmed != tt.trimmed || summary.Records == 0 {
+				t.Errorf("%d of the 4000 records were exported, want them trimmed: %v", summary.Records, tt.trimmed)
+			}
+			// The records are in the order they were sent, the last ones are left out
+			if rows[1][1] != "0" || rows[2][1] != "1" {
+				t.Errorf("first records are of shards %s and %s, want 0 and 1", rows[1][1], rows[2][1])
+			}
+			if lines, ok := files[worker.RecordsKey]; ok && strings.Count(lines, "\n") != summary.Records {
+				t.Errorf("records.jsonl has %d lines, want %d", strings.Count(lines, "\n"), summary.Records)
+			}
+		})
+	}
+}
+
+func TestExportedCondition(t *testing.T) {
+	tests := []struct {
+		name     string
+		spec     microsimv1alpha1.LoadGeneratorSpec
+		failures []string
+		want     metav1.ConditionStatus
+	}{
+		{name: "nothing written", want: ""},
+		{name: "exported", spec: microsimv1alpha1.LoadGeneratorSpec{Export: &microsimv1alpha1.ExportSpec{}}, want: metav1.ConditionTrue},
+		{
+			name: "spilled samples",
+			spec: microsimv1alpha1.LoadGeneratorSpec{Sampling: &microsimv1alpha1.SamplingSpec{Spill: &microsimv1alpha1.SpillSpec{}}},
+			want: metav1.ConditionTrue,
+		},
+		{
+			name:     "failed",
+			spec:     microsimv1alpha1.LoadGeneratorSpec{Export: &microsimv1alpha1.ExportSpec{}},
+			failures: []string{"failed to export results: disk full"},
+			want:     metav1.ConditionFalse,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := exportedCondition(&microsimv1alpha1.LoadGenerator{Spec: tt.spec}, tt.failures)
+			if tt.want == "" {
+				if got != nil {
+					t.Errorf("exportedCondition() = %+v, want none", got)
+				}
+				return
+			}
+			if got == nil || got.Status != tt.want {
+				t.Fatalf("exportedCondition() = %+v, want %s", got, tt.want)
+			}
+			if tt.want == metav1.ConditionFalse && got.Message != tt.failures[0] {
+				t.Errorf("message = %q, want the failures", got.Message)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package controllers
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	"github.com/MrSupiri/MicroSim/engine"
+)
+
+// latencyBuckets are the upper bounds of the request duration histogram
+var latencyBuckets = []time.Duration{
+	5 * time.Millisecond, 10 * time.Millisecond, 25 * time.Millisecond, 50 * time.Millisecond,
+	100 * time.Millisecond, 250 * time.Millisecond, 500 * time.Millisecond, time.Second,
+	2500 * time.Millisecond, 5 * time.Second, 10 * time.Second, 30 * time.Second,
+}
+
+// finishedRetention is how long the metrics of a finished run are still exported, long enough for
+// a scrape to pick up its final values
+const finishedRetention = 2 * time.Minute
+
+var (
+	requestsDesc = prometheus.NewDesc("microsim_loadgenerator_requests_total",
+		"Requests finished by the workers of a load generator, the warmup left out. Refreshed from the reports of the workers about every 5s",
+		[]string{"namespace", "loadgenerator", "template", "root"}, nil)
+	durationDesc = prometheus.NewDesc("microsim_loadgenerator_request_duration_seconds",
+		"Latency of the requests as seen by the workers, from when they were scheduled to their response",
+		[]string{"namespace", "loadgenerator", "template", "root"}, nil)
+	errorsDesc = prometheus.NewDesc("microsim_loadgenerator_errors_total",
+		"Failed requests by the type of error, one of Timeout, Connection, Status or Other",
+		[]string{"namespace", "loadgenerator", "template", "root", "type"}, nil)
+	inFlightDesc = prometheus.NewDesc("microsim_loadgenerator_requests_in_flight",
+		"Requests the workers of a load generator are waiting on",
+		[]string{"namespace", "loadgenerator"}, nil)
+)
+
+// loadGeneratorMetrics exports the statistics the workers report as client side metrics, a scrape
+// reads the latest reports added up by the controller, which refreshes them every reportInterval
+// while the run is going. The counters start over with every run and are dropped some time after
+// it finished
+type loadGeneratorMetrics struct {
+	mu   sync.Mutex
+	runs map[types.NamespacedName]runMetrics
+}
+
+type runMetrics struct {
+	stats    engine.Snapshot
+	inFlight int
+	// finished is when the run finished, it's zero while the run is going
+	finished time.Time
+}
+
+var loadGeneratorCollector = &loadGeneratorMetrics{runs: map[types.NamespacedName]runMetrics{}}
+
+func init() {
+	metrics.Registry.MustRegister(loadGeneratorCollector)
+}
+
+// set replaces the metrics of a load generator with the statistics of its run
+func (m *loadGeneratorMetrics) set(key types.NamespacedName, stats engine.Snapshot, inFlight int, finished bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	run := runMetrics{stats: stats, inFlight: inFlight}
+	if finished {
+		run.finished = time.Now()
+	}
+	m.runs[key] = run
+}
+
+// forget drops the metrics of a deleted load generator
+func (m *loadGeneratorMetrics) forget(key types.NamespacedName) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.runs, key)
+}
+
+func (m *loadGeneratorMetrics) Describe(ch chan<- *prometheus.Desc) {
+	ch <- requestsDesc
+	ch <- durationDesc
+	ch <- errorsDesc
+	ch <- inFlightDesc
+}
+
+func (m *loadGeneratorMetrics) Collect(ch chan<- prometheus.Metric) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for key, run := range m.runs {
+		if !run.finished.IsZero() && time.Since(run.finished) > finishedRetention {
+			delete(m.runs, key)
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(inFlightDesc, prometheus.GaugeValue, float64(run.inFlight), key.Namespace, key.Name)
+
+		names := make([]string, 0, len(run.stats.Templates))
+		for name := range run.stats.Templates {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			template := run.stats.Templates[name]
+			labels := []string{key.Namespace, key.Name, name, template.Root}
+			ch <- prometheus.MustNewConstMetric(requestsDesc, prometheus.CounterValue, float64(template.Latency.Total), labels...)
+			ch <- prometheus.MustNewConstHistogram(durationDesc, template.Latency.Total,
+				float64(template.Latency.Sum)/float64(time.Second/time.Microsecond), histogramBuckets(template.Latency), labels...)
+			for t, count := range template.Errors {
+				ch <- prometheus.MustNewConstMetric(errorsDesc, prometheus.CounterValue, float64(count), append(labels, t)...)
+			}
+		}
+	}
+}
+
+// histogramBuckets converts a latency histogram to the cumulative counts of latencyBuckets keyed by their bound in seconds
+func histogramBuckets(h engine.Histogram) map[float64]uint64 {
+	buckets := make(map[float64]uint64, len(latencyBuckets))
+	for i, count := range h.CumulativeCounts(latencyBuckets) {
+		buckets[latencyBuckets[i].Seconds()] = count
+	}
+	return buckets
+}
@@ -0,0 +1,81 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"k8s.io/apimachinery/pkg/types"
+
+	microsimv1alpha1 "github.com/MrSupiri/MicroSim/api/v1alpha1"
+	"github.com/MrSupiri/MicroSim/engine"
+)
+
+func TestLoadGeneratorMetrics(t *testing.T) {
+	var stats engine.Stats
+	stats.Record(engine.Result{Template: "browse", Route: microsimv1alpha1.Route{Designation: "front@v1"}, StatusCode: 200, Latency: 20 * time.Millisecond})
+	stats.Record(engine.Result{Template: "browse", Route: microsimv1alpha1.Route{Designation: "front@v1"}, StatusCode: 500, Latency: 2 * time.Second,
+		Err: errors.New("service responded with status 500")})
+	stats.Record(engine.Result{Template: "browse", Route: microsimv1alpha1.Route{Designation: "front@v1"}, Latency: 3 * time.Second, Err: context.DeadlineExceeded})
+
+	m := &loadGeneratorMetrics{runs: map[types.NamespacedName]runMetrics{}}
+	key := types.NamespacedName{Namespace: "default", Name: "browse"}
+	m.set(key, stats.Snapshot(), 4, false)
+
+	want := `
+# HELP microsim_loadgenerator_errors_total Failed requests by the type of error, one of Timeout, Connection, Status or Other
+# TYPE microsim_loadgenerator_errors_total counter
+microsim_loadgenerator_errors_total{loadgenerator="browse",namespace="default",root="front",template="browse",type="Status"} 1
+microsim_loadgenerator_errors_total{loadgenerator="browse",namespace="default",root="front",template="browse",type="Timeout"} 1
+# HELP microsim_loadgenerator_requests_in_flight Requests the workers of a load generator are waiting on
+# TYPE microsim_loadgenerator_requests_in_flight gauge
+microsim_loadgenerator_requests_in_flight{loadgenerator="browse",namespace="default"} 4
+# HELP microsim_loadgenerator_requests_total Requests finished by the workers of a load generator, the warmup left out. Refreshed from the reports of the workers about every 5s
+# TYPE microsim_loadgenerator_requests_total counter
+microsim_loadgenerator_requests_total{loadgenerator="browse",namespace="default",root="front",template="browse"} 3
+`
+	names := []string{"microsim_loadgenerator_errors_total", "microsim_loadgenerator_requests_in_flight", "microsim_loadgenerator_requests_total"}
+	if err := testutil.CollectAndCompare(m, strings.NewReader(want), names...); err != nil {
+		t.Error(err)
+	}
+	if got := testutil.CollectAndCount(m, "microsim_loadgenerator_request_duration_seconds"); got != 1 {
+		t.Errorf("exported %d duration histograms, want 1", got)
+	}
+
+	// A finished run is still exported until a scrape picked up its final values
+	m.set(key, stats.Snapshot(), 0, true)
+	if got := testutil.CollectAndCount(m, "microsim_loadgenerator_requests_total"); got != 1 {
+		t.Errorf("exported %d request counters of a run that just finished, want 1", got)
+	}
+	m.runs[key] = runMetrics{stats: stats.Snapshot(), finished: time.Now().Add(-finishedRetention - time.Second)}
+	if got := testutil.CollectAndCount(m); got != 0 {
+		t.Errorf("exported %d metrics of a run that finished long ago, want none", got)
+	}
+	if _, ok := m.runs[key]; ok {
+		t.Errorf("the metrics of a run that finished long ago were kept")
+	}
+
+	m.set(key, stats.Snapshot(), 0, false)
+	m.forget(key)
+	if got := testutil.CollectAndCount(m); got != 0 {
+		t.Errorf("exported %d metrics of a deleted load generator, want none", got)
+	}
+}
+
+func TestHistogramBuckets(t *testing.T) {
+	var h engine.Histogram
+	h.Record(20 * time.Millisecond)
+	h.Record(2 * time.Second)
+	buckets := histogramBuckets(h)
+	if len(buckets) != len(latencyBuckets) {
+		t.Fatalf("histogramBuckets() has %d buckets, want %d", len(buckets), len(latencyBuckets))
+	}
+	for bound, want := range map[float64]uint64{0.01: 0, 0.025: 1, 1: 1, 2.5: 2, 30: 2} {
+		if got := buckets[bound]; got != want {
+			t.Errorf("bucket le=%v = %d, want %d", bound, got, want)
+		}
+	}
+}
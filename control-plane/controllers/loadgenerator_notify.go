@@ -0,0 +1,196 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	microsimv1alpha1 "github.com/MrSupiri/MicroSim/api/v1alpha1"
+)
+
+const (
+	// notifyTimeout bounds a single attempt to send the summary to a webhook
+	notifyTimeout = 10 * time.Second
+	// notifyBackoff is the wait after the first failed attempt, it doubles with every attempt up to maxNotifyBackoff
+	notifyBackoff    = 5 * time.Second
+	maxNotifyBackoff = 5 * time.Minute
+	// defaultMaxAttempts is used for webhooks created before maxAttempts had a default
+	defaultMaxAttempts = 5
+)
+
+var notifyClient = &http.Client{Timeout: notifyTimeout}
+
+// notification is the body POSTed to the webhooks, the summary of the run with the one line form of the status
+type notification struct {
+	runSummary
+	Summary    string `json:"summary"`
+	ExportedTo string `json:"exportedTo,omitempty"`
+}
+
+// newNotifications returns a pending delivery for every webhook of the spec, whatever the reason the run finished for
+func newNotifications(spec microsimv1alpha1.LoadGeneratorSpec) []microsimv1alpha1.NotificationStatus {
+	if spec.Notify == nil {
+		return nil
+	}
+	var notifications []microsimv1alpha1.NotificationStatus
+	for _, webhook := range spec.Notify.Webhooks {
+		notifications = append(notifications, microsimv1alpha1.NotificationStatus{URL: webhook.URL})
+	}
+	return notifications
+}
+
+// stoppedEarly returns whether a run finished for reason before it sent all its requests or reached its timeout
+func stoppedEarly(reason string) bool {
+	return reason != microsimv1alpha1.ReasonCompleted && reason != microsimv1alpha1.ReasonTimeout
+}
+
+// emitFinishedEvent emits an Event with the result of the finished run when the spec asks for one
+func (r *LoadGeneratorReconciler) emitFinishedEvent(loadGenerator *microsimv1alpha1.LoadGenerator, status *microsimv1alpha1.LoadGeneratorStatus) {
+	if loadGenerator.Spec.Notify == nil || !loadGenerator.Spec.Notify.Event {
+		return
+	}
+	finished := meta.FindStatusCondition(status.Conditions, microsimv1alpha1.ConditionFinished)
+	if finished == nil {
+		return
+	}
+	// Every finished run is notified, a Warning tells the runs that stopped early apart
+	eventType := v1.EventTypeNormal
+	if stoppedEarly(finished.Reason) {
+		eventType = v1.EventTypeWarning
+	}
+	message := finished.Message
+	if passed := meta.FindStatusCondition(status.Conditions, microsimv1alpha1.ConditionPassed); passed != nil {
+		if passed.Status != metav1.ConditionTrue {
+			eventType = v1.EventTypeWarning
+		}
+		message = fmt.Sprintf("%s, %s", message, passed.Message)
+	}
+	if status.Summary != "" {
+		message = fmt.Sprintf("%s: %s", message, status.Summary)
+	}
+	r.Recorder.Event(loadGenerator, eventType, finished.Reason, message)
+}
+
+// deliverNotifications sends the summary of the finished run to one of the webhooks that are due, so a slow
+// webhook holds up a single reconcile rather than one per webhook. The reconcile is requeued right away
+// while more are due, the failed ones are tried again after a backoff until they run out of attempts
+func (r *LoadGeneratorReconciler) deliverNotifications(ctx context.Context, loadGenerator *microsimv1alpha1.LoadGenerator) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+	if loadGenerator.Spec.Notify == nil {
+		return ctrl.Result{Requeue: false}, nil
+	}
+	maxAttempts := map[string]int{}
+	for _, webhook := range loadGenerator.Spec.Notify.Webhooks {
+		maxAttempts[webhook.URL] = webhook.MaxAttempts
+		if webhook.MaxAttempts < 1 {
+			maxAttempts[webhook.URL] = defaultMaxAttempts
+		}
+	}
+
+	var due []microsimv1alpha1.NotificationStatus
+	var requeueAfter time.Duration
+	wait := func(d time.Duration) {
+		if requeueAfter == 0 || d < requeueAfter {
+			requeueAfter = d
+		}
+	}
+	for _, n := range loadGenerator.Status.Notifications {
+		if n.Delivered || n.Attempts >= maxAttempts[n.URL] {
+			continue
+		}
+		if n.LastAttempt != nil {
+			if left := time.Until(n.LastAttempt.Add(backoff(n.Attempts))); left > 0 {
+				wait(left)
+				continue
+			}
+		}
+		due = append(due, n)
+	}
+	if len(due) == 0 {
+		return ctrl.Result{RequeueAfter: requeueAfter}, nil
+	}
+
+	body, err := json.Marshal(notification{
+		runSummary: summarize(loadGenerator, &loadGenerator.Status),
+		Summary:    loadGenerator.Status.Summary,
+		ExportedTo: loadGenerator.Status.ExportedTo,
+	})
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	n := due[0]
+	now := metav1.Now()
+	n.Attempts++
+	n.LastAttempt = &now
+	n.Error = ""
+	if err := postNotification(ctx, n.URL, body); err != nil {
+		n.Error = err.Error()
+		if n.Attempts >= maxAttempts[n.URL] {
+			logger.Error(err, "giving up on notifying webhook", "url", n.URL, "attempts", n.Attempts)
+			if loadGenerator.Spec.Notify.Event {
+				r.Recorder.Eventf(loadGenerator, v1.EventTypeWarning, "NotificationFailed",
+					"Failed to notify %s after %d attempts: %v", n.URL, n.Attempts, err)
+			}
+		} else {
+			logger.Info("failed to notify webhook, retrying", "url", n.URL, "attempts", n.Attempts, "error", err.Error())
+			wait(backoff(n.Attempts))
+		}
+	} else {
+		n.Delivered = true
+		logger.V(1).Info("notified webhook", "url", n.URL)
+	}
+
+	key := types.NamespacedName{Namespace: loadGenerator.Namespace, Name: loadGenerator.Name}
+	if err := r.writeStatus(ctx, key, func(status *microsimv1alpha1.LoadGeneratorStatus) {
+		for i := range status.Notifications {
+			if status.Notifications[i].URL == n.URL {
+				status.Notifications[i] = n
+			}
+		}
+	}); err != nil {
+		return ctrl.Result{}, err
+	}
+	if len(due) > 1 {
+		return ctrl.Result{Requeue: true}, nil
+	}
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
+}
+
+// backoff is the wait after attempts failed attempts
+func backoff(attempts int) time.Duration {
+	wait := notifyBackoff
+	for i := 1; i < attempts && wait < maxNotifyBackoff; i++ {
+		wait *= 2
+	}
+	if wait > maxNotifyBackoff {
+		return maxNotifyBackoff
+	}
+	return wait
+}
+
+func postNotification(ctx context.Context, url string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := notifyClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
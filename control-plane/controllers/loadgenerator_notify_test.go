@@ -0,0 +1,195 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	microsimv1alpha1 "github.com/MrSupiri/MicroSim/api/v1alpha1"
+)
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{attempts: 1, want: 5 * time.Second},
+		{attempts: 2, want: 10 * time.Second},
+		{attempts: 4, want: 40 * time.Second},
+		{attempts: 7, want: 5 * time.Minute},
+		{attempts: 50, want: 5 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := backoff(tt.attempts); got != tt.want {
+			t.Errorf("backoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+// webhookServer answers the POSTs to /ok with 200 and the others with 500, it keeps the paths it was sent
+type webhookServer struct {
+	*httptest.Server
+	mu    sync.Mutex
+	paths []string
+	body  notification
+}
+
+func newWebhookServer(t *testing.T) *webhookServer {
+	s := &webhookServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.paths = append(s.paths, r.URL.Path)
+		if err := json.NewDecoder(r.Body).Decode(&s.body); err != nil {
+			t.Errorf("webhook got an invalid body: %v", err)
+		}
+		if r.URL.Path != "/ok" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	return s
+}
+
+func TestDeliverNotifications(t *testing.T) {
+	server := newWebhookServer(t)
+	defer server.Close()
+	ok, failing := server.URL+"/ok", server.URL+"/failing"
+
+	loadGenerator := finishedLoadGenerator("browse")
+	loadGenerator.Spec.Notify = &microsimv1alpha1.NotifySpec{
+		Event:    true,
+		Webhooks: []microsimv1alpha1.Webhook{{URL: ok}, {URL: failing, MaxAttempts: 2}},
+	}
+	loadGenerator.Status.Summary = "p50=10ms p99=20ms max=30ms errors=0.0% 10.0req/s"
+	loadGenerator.Status.Notifications = newNotifications(loadGenerator.Spec)
+	c := fake.NewClientBuilder().WithScheme(testScheme(t)).WithObjects(loadGenerator).Build()
+	recorder := record.NewFakeRecorder(10)
+	r := &LoadGeneratorReconciler{Client: c, Recorder: recorder}
+	key := types.NamespacedName{Namespace: "default", Name: "browse"}
+	deliver := func() (bool, time.Duration) {
+		t.Helper()
+		var current microsimv1alpha1.LoadGenerator
+		if err := c.Get(context.Background(), key, &current); err != nil {
+			t.Fatal(err)
+		}
+		result, err := r.deliverNotifications(context.Background(), &current)
+		if err != nil {
+			t.Fatalf("deliverNotifications() error = %v", err)
+		}
+		return result.Requeue, result.RequeueAfter
+	}
+	notifications := func() []microsimv1alpha1.NotificationStatus {
+		var current microsimv1alpha1.LoadGenerator
+		if err := c.Get(context.Background(), key, &current); err != nil {
+			t.Fatal(err)
+		}
+		return current.Status.Notifications
+	}
+
+	// A single webhook is notified per reconcile, the next one right after
+	if requeue, _ := deliver(); !requeue || len(server.paths) != 1 {
+		t.Fatalf("first reconcile sent %v and requeue is %v, want only the first webhook and a requeue", server.paths, requeue)
+	}
+	if n := notifications()[0]; !n.Delivered || n.Attempts != 1 {
+		t.Errorf("first webhook = %+v, want delivered on the first attempt", n)
+	}
+	if server.body.Reason != microsimv1alpha1.ReasonCompleted || server.body.Summary != loadGenerator.Status.Summary {
+		t.Errorf("webhook was sent %+v, want the summary of the completed run", server.body)
+	}
+
+	if requeue, after := deliver(); requeue || after != backoff(1) {
+		t.Errorf("after a failure requeue = %v after %v, want a retry after %v", requeue, after, backoff(1))
+	}
+	if n := notifications()[1]; n.Delivered || n.Attempts != 1 || !strings.Contains(n.Error, "status 500") {
+		t.Errorf("second webhook = %+v, want a failed attempt", n)
+	}
+
+	// Nothing is due before the backoff is over
+	if _, after := deliver(); len(server.paths) != 2 || after <= 0 || after > backoff(1) {
+		t.Errorf("during the backoff sent %v and requeued after %v, want nothing sent and a requeue once it's over", server.paths, after)
+	}
+
+	// Once the backoff is over the last attempt gives up
+	var current microsimv1alpha1.LoadGenerator
+	if err := c.Get(context.Background(), key, &current); err != nil {
+		t.Fatal(err)
+	}
+	past := metav1.NewTime(time.Now().Add(-time.Hour))
+	current.Status.Notifications[1].LastAttempt = &past
+	if err := c.Status().Update(context.Background(), &current); err != nil {
+		t.Fatal(err)
+	}
+	if requeue, after := deliver(); requeue || after != 0 {
+		t.Errorf("after giving up requeue = %v after %v, want none", requeue, after)
+	}
+	if n := notifications()[1]; n.Delivered || n.Attempts != 2 {
+		t.Errorf("second webhook = %+v, want 2 failed attempts", n)
+	}
+	select {
+	case event := <-recorder.Events:
+		if !strings.HasPrefix(event, "Warning NotificationFailed") {
+			t.Errorf("event = %s, want a NotificationFailed warning", event)
+		}
+	default:
+		t.Errorf("no event was emitted when giving up on the webhook")
+	}
+	deliver()
+	if len(server.paths) != 3 {
+		t.Errorf("webhooks were sent %v, want nothing after they were delivered or given up on", server.paths)
+	}
+}
+
+func TestEmitFinishedEvent(t *testing.T) {
+	tests := []struct {
+		reason    string
+		passed    metav1.ConditionStatus
+		eventType string
+	}{
+		{reason: microsimv1alpha1.ReasonCompleted, eventType: "Normal"},
+		{reason: microsimv1alpha1.ReasonTimeout, eventType: "Normal"},
+		{reason: microsimv1alpha1.ReasonErrorRateExceeded, eventType: "Warning"},
+		{reason: microsimv1alpha1.ReasonLatencyExceeded, eventType: "Warning"},
+		{reason: microsimv1alpha1.ReasonWorkerFailed, eventType: "Warning"},
+		{reason: microsimv1alpha1.ReasonCompleted, passed: metav1.ConditionFalse, eventType: "Warning"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.reason+string(tt.passed), func(t *testing.T) {
+			loadGenerator := finishedLoadGenerator("browse")
+			loadGenerator.Spec.Notify = &microsimv1alpha1.NotifySpec{Event: true, Webhooks: []microsimv1alpha1.Webhook{{URL: "http://ci/hook"}}}
+			status := loadGenerator.Status.DeepCopy()
+			status.Conditions[0].Reason = tt.reason
+			status.Conditions[0].Message = "The run finished"
+			if tt.passed != "" {
+				status.Conditions = append(status.Conditions, metav1.Condition{
+					Type: microsimv1alpha1.ConditionPassed, Status: tt.passed, Reason: microsimv1alpha1.ReasonAssertionsFailed, Message: "1 of 1 assertions failed",
+				})
+			}
+			recorder := record.NewFakeRecorder(1)
+			r := &LoadGeneratorReconciler{Recorder: recorder}
+
+			r.emitFinishedEvent(loadGenerator, status)
+			select {
+			case event := <-recorder.Events:
+				if want := tt.eventType + " " + tt.reason + " The run finished"; !strings.HasPrefix(event, want) {
+					t.Errorf("event = %s, want %s", event, want)
+				}
+			default:
+				t.Errorf("no event was emitted")
+			}
+			// The webhooks are notified whatever the reason the run finished for
+			if got := newNotifications(loadGenerator.Spec); len(got) != 1 || got[0].URL != "http://ci/hook" || got[0].Delivered {
+				t.Errorf("newNotifications() = %+v, want a pending delivery to the webhook", got)
+			}
+		})
+	}
+}
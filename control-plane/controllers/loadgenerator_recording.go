@@ -0,0 +1,103 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	microsimv1alpha1 "github.com/MrSupiri/MicroSim/api/v1alpha1"
+	"github.com/MrSupiri/MicroSim/engine"
+	"github.com/MrSupiri/MicroSim/worker"
+)
+
+// errNotRecorded is returned for a replay from a load generator that doesn't record its runs
+var errNotRecorded = errors.New("load generator doesn't record its runs")
+
+// recordingLocation returns where the requests of the load generator are recorded, every worker records
+// to a ConfigMap of its own which are merged into a single file for the results store once the run is finished.
+// The recording stays in the ConfigMaps when the controller has no results directory to merge them into
+func (r *LoadGeneratorReconciler) recordingLocation(loadGenerator *microsimv1alpha1.LoadGenerator) string {
+	if loadGenerator.Spec.Record == nil {
+		return ""
+	}
+	if file := r.recordingFile(loadGenerator); file != "" {
+		return file
+	}
+	return fmt.Sprintf("configmap/%s/%s-recording-*", loadGenerator.Namespace, loadGenerator.Name)
+}
+
+// recordingFile returns the file of the results store the recording is merged into, it's empty when it isn't
+func (r *LoadGeneratorReconciler) recordingFile(loadGenerator *microsimv1alpha1.LoadGenerator) string {
+	record := loadGenerator.Spec.Record
+	if record == nil || record.Type != microsimv1alpha1.ResultsStoreRecording || r.ResultsDir == "" {
+		return ""
+	}
+	return filepath.Join(r.ResultsDir, loadGenerator.Namespace, loadGenerator.Name, worker.RecordingKey)
+}
+
+// collectRecording merges the recordings of the workers into the results store, in the order the requests were sent
+func (r *LoadGeneratorReconciler) collectRecording(ctx context.Context, loadGenerator *microsimv1alpha1.LoadGenerator, shards int) error {
+	record := loadGenerator.Spec.Record
+	if record == nil || record.Type != microsimv1alpha1.ResultsStoreRecording {
+		return nil
+	}
+	path := r.recordingFile(loadGenerator)
+	if path == "" {
+		return fmt.Errorf("the controller was started without a results directory, the recording is only kept in the ConfigMaps")
+	}
+
+	var recorded []engine.Recorded
+	for shard := 0; shard < shards; shard++ {
+		var configMap v1.ConfigMap
+		err := r.Get(ctx, types.NamespacedName{Namespace: loadGenerator.Namespace, Name: worker.RecordingName(loadGenerator.Name, shard)}, &configMap)
+		if err != nil {
+			if client.IgnoreNotFound(err) != nil {
+				return err
+			}
+			continue
+		}
+		requests, err := worker.ReadRecording(strings.NewReader(configMap.Data[worker.RecordingKey]))
+		if err != nil {
+			return fmt.Errorf("failed to decode recording of %s: %w", configMap.Name, err)
+		}
+		recorded = append(recorded, requests...)
+	}
+	worker.SortRecording(recorded)
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, request := range recorded {
+		if err := encoder.Encode(request); err != nil {
+			return err
+		}
+	}
+	return writeResultsFile(path, buf.Bytes())
+}
+
+// recordingReady returns whether the run of the load generator a replay is from finished recording, it
+// fails when the load generator doesn't record its runs
+func (r *LoadGeneratorReconciler) recordingReady(ctx context.Context, loadGenerator *microsimv1alpha1.LoadGenerator, from microsimv1alpha1.LoadGeneratorRef) (bool, error) {
+	var source microsimv1alpha1.LoadGenerator
+	if err := r.Get(ctx, from.NamespacedName(loadGenerator.Namespace), &source); err != nil {
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	if source.Spec.Record == nil {
+		return false, fmt.Errorf("%s: %w", source.Name, errNotRecorded)
+	}
+	finished := meta.FindStatusCondition(source.Status.Conditions, microsimv1alpha1.ConditionFinished)
+	return finished != nil && finished.Status == metav1.ConditionTrue && finished.ObservedGeneration == source.Generation, nil
+}
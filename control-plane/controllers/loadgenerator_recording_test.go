@@ -0,0 +1,124 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	microsimv1alpha1 "github.com/MrSupiri/MicroSim/api/v1alpha1"
+	"github.com/MrSupiri/MicroSim/engine"
+	"github.com/MrSupiri/MicroSim/worker"
+)
+
+// recordingConfigMap is the ConfigMap a worker records its requests to
+func recordingConfigMap(t *testing.T, loadGenerator string, shard int, recorded ...engine.Recorded) *v1.ConfigMap {
+	var lines []string
+	for _, request := range recorded {
+		line, err := json.Marshal(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, string(line))
+	}
+	return &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: worker.RecordingName(loadGenerator, shard)},
+		Data:       map[string]string{worker.RecordingKey: strings.Join(lines, "\n") + "\n"},
+	}
+}
+
+func TestRecordingReady(t *testing.T) {
+	recorded := func(finished bool) *microsimv1alpha1.LoadGenerator {
+		source := finishedLoadGenerator("recorded")
+		source.Spec.Record = &microsimv1alpha1.RecordSpec{Type: microsimv1alpha1.ResultsStoreRecording}
+		if !finished {
+			source.Status.Conditions = nil
+		}
+		return source
+	}
+	tests := []struct {
+		name    string
+		objects []client.Object
+		want    bool
+		err     error
+	}{
+		{name: "source missing"},
+		{name: "source not finished", objects: []client.Object{recorded(false)}},
+		{name: "source finished", objects: []client.Object{recorded(true)}, want: true},
+		{name: "source doesn't record", objects: []client.Object{finishedLoadGenerator("recorded")}, err: errNotRecorded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &LoadGeneratorReconciler{Client: fake.NewClientBuilder().WithScheme(testScheme(t)).WithObjects(tt.objects...).Build()}
+			replay := &microsimv1alpha1.LoadGenerator{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "replay"}}
+
+			got, err := r.recordingReady(context.Background(), replay, microsimv1alpha1.LoadGeneratorRef{Name: "recorded"})
+			if !errors.Is(err, tt.err) || (tt.err == nil && err != nil) {
+				t.Fatalf("recordingReady() error = %v, want %v", err, tt.err)
+			}
+			if got != tt.want {
+				t.Errorf("recordingReady() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCollectRecording(t *testing.T) {
+	dir, err := ioutil.TempDir("", "recording")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	loadGenerator := &microsimv1alpha1.LoadGenerator{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "browse"},
+		Spec:       microsimv1alpha1.LoadGeneratorSpec{Record: &microsimv1alpha1.RecordSpec{Type: microsimv1alpha1.ResultsStoreRecording}},
+	}
+	c := fake.NewClientBuilder().WithScheme(testScheme(t)).WithObjects(
+		recordingConfigMap(t, "browse", 0, engine.Recorded{Offset: 0, Sequence: 0}, engine.Recorded{Offset: 2 * time.Second, Sequence: 2}),
+		recordingConfigMap(t, "browse", 1, engine.Recorded{Offset: time.Second, Sequence: 1}),
+	).Build()
+	r := &LoadGeneratorReconciler{Client: c, ResultsDir: dir}
+
+	if got, want := r.recordingLocation(loadGenerator), filepath.Join(dir, "default", "browse", worker.RecordingKey); got != want {
+		t.Errorf("recordingLocation() = %s, want %s", got, want)
+	}
+	if err := r.collectRecording(context.Background(), loadGenerator, 2); err != nil {
+		t.Fatalf("collectRecording() error = %v", err)
+	}
+	file, err := os.Open(r.recordingFile(loadGenerator))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	recorded, err := worker.ReadRecording(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sequences []uint64
+	for _, request := range recorded {
+		sequences = append(sequences, request.Sequence)
+	}
+	if want := []uint64{0, 1, 2}; !reflect.DeepEqual(sequences, want) {
+		t.Errorf("merged recording = %v, want the requests in the order they were sent %v", sequences, want)
+	}
+
+	// Without a results directory the recording stays in the ConfigMaps
+	r.ResultsDir = ""
+	if got := r.recordingLocation(loadGenerator); got != "configmap/default/browse-recording-*" {
+		t.Errorf("recordingLocation() = %s, want the ConfigMaps", got)
+	}
+	if err := r.collectRecording(context.Background(), loadGenerator, 2); err == nil {
+		t.Errorf("collectRecording() without a results directory succeeded")
+	}
+}
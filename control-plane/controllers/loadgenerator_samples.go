@@ -0,0 +1,71 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	microsimv1alpha1 "github.com/MrSupiri/MicroSim/api/v1alpha1"
+	"github.com/MrSupiri/MicroSim/worker"
+)
+
+// spillLocation returns where the samples of the load generator are spilled, it's empty when they aren't.
+// Every worker spills to a ConfigMap of its own, the ones spilling to the results store are collected
+// into a single file once the run is finished
+func (r *LoadGeneratorReconciler) spillLocation(loadGenerator *microsimv1alpha1.LoadGenerator) string {
+	sampling := worker.SamplingOf(loadGenerator.Spec)
+	if sampling.Spill == nil {
+		return ""
+	}
+	switch sampling.Spill.Type {
+	case microsimv1alpha1.ConfigMapSpill:
+		return fmt.Sprintf("configmap/%s/%s-samples-*", loadGenerator.Namespace, loadGenerator.Name)
+	case microsimv1alpha1.ResultsStoreSpill:
+		if r.ResultsDir == "" {
+			return ""
+		}
+		return filepath.Join(r.ResultsDir, loadGenerator.Namespace, loadGenerator.Name, worker.SamplesKey)
+	}
+	return ""
+}
+
+// collectSpilledSamples writes the samples the workers spilled to the results store
+func (r *LoadGeneratorReconciler) collectSpilledSamples(ctx context.Context, loadGenerator *microsimv1alpha1.LoadGenerator, shards int) error {
+	sampling := worker.SamplingOf(loadGenerator.Spec)
+	if sampling.Spill == nil || sampling.Spill.Type != microsimv1alpha1.ResultsStoreSpill {
+		return nil
+	}
+	path := r.spillLocation(loadGenerator)
+	if path == "" {
+		return fmt.Errorf("the controller was started without a results directory")
+	}
+
+	var samples microsimv1alpha1.Samples
+	for shard := 0; shard < shards; shard++ {
+		var configMap v1.ConfigMap
+		err := r.Get(ctx, types.NamespacedName{Namespace: loadGenerator.Namespace, Name: worker.SamplesName(loadGenerator.Name, shard)}, &configMap)
+		if err != nil {
+			if client.IgnoreNotFound(err) != nil {
+				return err
+			}
+			continue
+		}
+		var spilled microsimv1alpha1.Samples
+		if err := json.Unmarshal([]byte(configMap.Data[worker.SamplesKey]), &spilled); err != nil {
+			return fmt.Errorf("failed to decode samples of %s: %w", configMap.Name, err)
+		}
+		samples.Errors = append(samples.Errors, spilled.Errors...)
+		samples.Successes = append(samples.Successes, spilled.Successes...)
+	}
+
+	data, err := json.MarshalIndent(samples, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeResultsFile(path, data)
+}
@@ -0,0 +1,87 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	microsimv1alpha1 "github.com/MrSupiri/MicroSim/api/v1alpha1"
+	"github.com/MrSupiri/MicroSim/worker"
+)
+
+func TestSpillLocation(t *testing.T) {
+	spilling := func(spill microsimv1alpha1.SpillType) *microsimv1alpha1.LoadGenerator {
+		return &microsimv1alpha1.LoadGenerator{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "browse"},
+			Spec:       microsimv1alpha1.LoadGeneratorSpec{Sampling: &microsimv1alpha1.SamplingSpec{Spill: &microsimv1alpha1.SpillSpec{Type: spill}}},
+		}
+	}
+	tests := []struct {
+		name          string
+		resultsDir    string
+		loadGenerator *microsimv1alpha1.LoadGenerator
+		want          string
+	}{
+		{name: "not spilled", loadGenerator: &microsimv1alpha1.LoadGenerator{}, want: ""},
+		{name: "config maps", loadGenerator: spilling(microsimv1alpha1.ConfigMapSpill), want: "configmap/default/browse-samples-*"},
+		{name: "results store", resultsDir: "/results", loadGenerator: spilling(microsimv1alpha1.ResultsStoreSpill), want: "/results/default/browse/samples.json"},
+		{name: "no results store", loadGenerator: spilling(microsimv1alpha1.ResultsStoreSpill), want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &LoadGeneratorReconciler{ResultsDir: tt.resultsDir}
+			if got := r.spillLocation(tt.loadGenerator); got != tt.want {
+				t.Errorf("spillLocation() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCollectSpilledSamples(t *testing.T) {
+	loadGenerator := &microsimv1alpha1.LoadGenerator{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "browse"},
+		Spec: microsimv1alpha1.LoadGeneratorSpec{Sampling: &microsimv1alpha1.SamplingSpec{
+			Spill: &microsimv1alpha1.SpillSpec{Type: microsimv1alpha1.ResultsStoreSpill},
+		}},
+	}
+	spilled := func(shard int, samples microsimv1alpha1.Samples) *v1.ConfigMap {
+		data, err := json.Marshal(samples)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: worker.SamplesName("browse", shard)},
+			Data:       map[string]string{worker.SamplesKey: string(data)},
+		}
+	}
+	dir := t.TempDir()
+	r := &LoadGeneratorReconciler{
+		ResultsDir: dir,
+		// The second of the three shards didn't spill anything
+		Client: fake.NewClientBuilder().WithScheme(testScheme(t)).WithObjects(
+			spilled(0, microsimv1alpha1.Samples{Errors: []microsimv1alpha1.Sample{{Template: "checkout"}}}),
+			spilled(2, microsimv1alpha1.Samples{Successes: []microsimv1alpha1.Sample{{Template: "browse"}}}),
+		).Build(),
+	}
+	if err := r.collectSpilledSamples(context.Background(), loadGenerator, 3); err != nil {
+		t.Fatalf("collectSpilledSamples() error = %v", err)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "default", "browse", worker.SamplesKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var samples microsimv1alpha1.Samples
+	if err := json.Unmarshal(data, &samples); err != nil {
+		t.Fatal(err)
+	}
+	if len(samples.Errors) != 1 || len(samples.Successes) != 1 {
+		t.Errorf("collected %d errors and %d successes, want 1 of each", len(samples.Errors), len(samples.Successes))
+	}
+}
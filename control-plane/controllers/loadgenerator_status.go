@@ -0,0 +1,277 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	microsimv1alpha1 "github.com/MrSupiri/MicroSim/api/v1alpha1"
+	"github.com/MrSupiri/MicroSim/engine"
+	"github.com/MrSupiri/MicroSim/worker"
+)
+
+// runResult is the state of a run added up from the reports of its shards
+type runResult struct {
+	finished  bool
+	condition metav1.Condition
+	stats     engine.Snapshot
+	stage     string
+	samples   microsimv1alpha1.Samples
+	shards    []microsimv1alpha1.ShardStatus
+	// assertions are the results of the assertions of the spec, passed is their verdict
+	assertions []microsimv1alpha1.AssertionResult
+	passed     *metav1.Condition
+	// replicas is the number of workers still running and inFlight the requests they're waiting on
+	replicas int
+	inFlight int
+	// exportedTo is where the results were exported to once the run finished and exported whether
+	// everything was written, it's nil while the run is going
+	exportedTo string
+	exported   *metav1.Condition
+	// warmup is nil unless the spec has a warmup
+	warmup *microsimv1alpha1.WarmupStatus
+	// recording is nil unless the spec records the run
+	recording *microsimv1alpha1.RecordingStatus
+	// notifications are the pending deliveries to the webhooks once the run finished
+	notifications []microsimv1alpha1.NotificationStatus
+}
+
+// apply replaces the results of the run in the status, the values are absolute so applying them
+// again on top of a newer version of the status is safe
+func (r *runResult) apply(status *microsimv1alpha1.LoadGeneratorStatus) {
+	status.DoneRequests = int(r.stats.Latency.Total)
+	status.TotalResponseTime = metav1.Duration{Duration: time.Duration(r.stats.Latency.Sum) * time.Microsecond}
+	status.CurrentStage = r.stage
+	status.Replicas = r.replicas
+	meta.SetStatusCondition(&status.Conditions, r.condition)
+	setSummary(status, r.stats.Summary(time.Now()))
+	samples := r.samples
+	status.Samples = &samples
+	status.Shards = r.shards
+	status.Assertions = r.assertions
+	status.ExportedTo = r.exportedTo
+	status.Warmup = r.warmup
+	status.Recording = r.recording
+	status.Notifications = r.notifications
+	if r.exported != nil {
+		meta.SetStatusCondition(&status.Conditions, *r.exported)
+	} else {
+		meta.RemoveStatusCondition(&status.Conditions, microsimv1alpha1.ConditionExported)
+	}
+	if r.passed != nil {
+		meta.SetStatusCondition(&status.Conditions, *r.passed)
+	} else {
+		meta.RemoveStatusCondition(&status.Conditions, microsimv1alpha1.ConditionPassed)
+	}
+}
+
+// collectReports reads the reports the workers wrote for the current generation, the run is finished
+// once every shard is done or as soon as one of them stopped for any other reason
+func (r *LoadGeneratorReconciler) collectReports(ctx context.Context, loadGenerator *microsimv1alpha1.LoadGenerator, shards int, jobs []*batchv1.Job) (*runResult, error) {
+	logger := log.FromContext(ctx)
+	result := &runResult{
+		condition: metav1.Condition{
+			Type:               microsimv1alpha1.ConditionFinished,
+			Status:             metav1.ConditionFalse,
+			ObservedGeneration: loadGenerator.Generation,
+			Reason:             microsimv1alpha1.ReasonRunning,
+			Message:            "Sending requests",
+		},
+	}
+
+	var reports []worker.Report
+	var recentLatency engine.Histogram
+	var warmup engine.Snapshot
+	if loadGenerator.Spec.Warmup != nil {
+		result.warmup = &microsimv1alpha1.WarmupStatus{}
+	}
+	if loadGenerator.Spec.Record != nil {
+		result.recording = &microsimv1alpha1.RecordingStatus{RecordedTo: r.recordingLocation(loadGenerator)}
+	}
+	completed := 0
+	for shard := 0; shard < shards; shard++ {
+		if jobActive(jobs[shard]) {
+			result.replicas++
+		}
+
+		var configMap v1.ConfigMap
+		err := r.Get(ctx, types.NamespacedName{Namespace: loadGenerator.Namespace, Name: worker.ReportName(loadGenerator.Name, shard)}, &configMap)
+		if client.IgnoreNotFound(err) != nil {
+			return nil, err
+		}
+		report, ok, err := worker.ReadReport(&configMap)
+		if err != nil {
+			logger.Error(err, "skipping report")
+		}
+		if !ok || report.Generation != loadGenerator.Generation {
+			// The worker didn't report yet, it's only given up on once its Job failed or it's past the timeout
+			if jobFailed(jobs[shard]) {
+				result.finish(microsimv1alpha1.ReasonWorkerFailed, fmt.Sprintf("Worker %s failed before reporting", jobs[shard].Name))
+			}
+			result.checkDeadline(loadGenerator.Spec.Timeout, nil, jobs[shard])
+			continue
+		}
+		reports = append(reports, report)
+		result.shards = append(result.shards, microsimv1alpha1.ShardStatus{
+			Shard:         shard,
+			DoneRequests:  int(report.Stats.Latency.Total),
+			Elapsed:       report.Elapsed,
+			Stage:         report.Stage,
+			Resumes:       report.Resumes,
+			WarmupEndedBy: report.WarmupEndedBy,
+			Reason:        report.Reason,
+			LastReport:    report.Updated,
+		})
+
+		result.stats.Merge(report.Stats)
+		if report.RecentLatency != nil {
+			recentLatency.Merge(*report.RecentLatency)
+		}
+		if report.Reason == "" {
+			result.inFlight += report.InFlight
+		}
+		if result.recording != nil {
+			result.recording.Requests += report.Recorded
+			result.recording.Dropped += report.RecordingDropped
+		}
+		if report.Warmup != nil && result.warmup != nil {
+			warmup.Merge(*report.Warmup)
+			if report.Warming {
+				result.warmup.Warming++
+			}
+			if report.WarmupDuration.Duration > result.warmup.Duration.Duration {
+				result.warmup.Duration = report.WarmupDuration
+			}
+		}
+		if result.stage == "" && report.Reason == "" {
+			result.stage = report.Stage
+		}
+		switch report.Reason {
+		case "":
+			if jobFailed(jobs[shard]) {
+				result.finish(microsimv1alpha1.ReasonWorkerFailed, fmt.Sprintf("Worker %s failed before its shard was done", jobs[shard].Name))
+			}
+			result.checkDeadline(loadGenerator.Spec.Timeout, &report, jobs[shard])
+		case microsimv1alpha1.ReasonCompleted:
+			completed++
+		default:
+			result.finish(report.Reason, report.Message)
+		}
+	}
+	if completed == shards {
+		result.finish(microsimv1alpha1.ReasonCompleted, "All the requests were sent")
+	}
+
+	// The abort conditions are checked on the results of every worker together
+	if abort := loadGenerator.Spec.Abort; abort != nil {
+		if reason, message := worker.AbortReason(*abort, result.stats, recentLatency, time.Now()); reason != "" {
+			logger.Info("aborting load generator", "reason", reason, "message", message)
+			result.finish(reason, message)
+		}
+	}
+
+	if result.finished {
+		result.replicas = 0
+		result.inFlight = 0
+	}
+
+	if result.warmup != nil {
+		result.warmup.Requests = int(warmup.Latency.Total)
+		result.warmup.Failed = int(warmup.Failed)
+		result.warmup.Latency = latencyStats(warmup.Latency.Latency())
+	}
+
+	if len(loadGenerator.Spec.Assertions) > 0 {
+		result.assertions = checkAssertions(loadGenerator.Spec.Assertions, result.stats)
+		passed := passedCondition(result.assertions, result.finished, loadGenerator.Generation)
+		result.passed = &passed
+	}
+
+	result.samples = mergeSamples(reports, worker.SamplingOf(loadGenerator.Spec))
+	result.samples.SpilledTo = r.spillLocation(loadGenerator)
+	return result, nil
+}
+
+// checkDeadline finishes the run once a shard that's still running is past the timeout, the shard
+// has what's left of the timeout after its last report or after its Job was created, whichever is
+// later, so a shard restarted or resumed after a pause isn't cut off early
+func (r *runResult) checkDeadline(timeout *metav1.Duration, report *worker.Report, job *batchv1.Job) {
+	if timeout == nil {
+		return
+	}
+	start, left := job.CreationTimestamp.Time, timeout.Duration
+	if report != nil {
+		if report.Updated.After(start) {
+			start = report.Updated.Time
+		}
+		left -= report.Elapsed.Duration
+	}
+	deadline := start.Add(left)
+	if time.Now().After(deadline.Add(workerGracePeriod)) {
+		r.finish(microsimv1alpha1.ReasonTimeout, fmt.Sprintf("Timeout was reached at %v", deadline.Format(time.RFC3339)))
+	}
+}
+
+// finish sets the Finished condition, only the first reason is kept
+func (r *runResult) finish(reason string, message string) {
+	if r.finished {
+		return
+	}
+	r.finished = true
+	r.condition.Status = metav1.ConditionTrue
+	r.condition.Reason = reason
+	r.condition.Message = message
+}
+
+// writeStatus applies update on top of the latest version of the status, the patch fails on a
+// conflicting update in which case it's retried
+func (r *LoadGeneratorReconciler) writeStatus(ctx context.Context, key types.NamespacedName, update func(*microsimv1alpha1.LoadGeneratorStatus)) error {
+	err := retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		var loadGenerator microsimv1alpha1.LoadGenerator
+		if err := r.Get(ctx, key, &loadGenerator); err != nil {
+			return err
+		}
+		patch := client.MergeFromWithOptions(loadGenerator.DeepCopy(), client.MergeFromWithOptimisticLock{})
+		update(&loadGenerator.Status)
+		return r.Status().Patch(ctx, &loadGenerator, patch)
+	})
+	return client.IgnoreNotFound(err)
+}
+
+// mergeSamples keeps the latest errors of all the shards and picks the successes from each shard in turn
+func mergeSamples(reports []worker.Report, sampling microsimv1alpha1.SamplingSpec) microsimv1alpha1.Samples {
+	var samples microsimv1alpha1.Samples
+	for _, report := range reports {
+		samples.Errors = append(samples.Errors, report.Samples.Errors...)
+	}
+	sort.SliceStable(samples.Errors, func(i, j int) bool {
+		return samples.Errors[i].Time.Before(&samples.Errors[j].Time)
+	})
+	if len(samples.Errors) > sampling.Errors {
+		samples.Errors = samples.Errors[len(samples.Errors)-sampling.Errors:]
+	}
+
+	for i := 0; len(samples.Successes) < sampling.Successes; i++ {
+		picked := false
+		for _, report := range reports {
+			if i < len(report.Samples.Successes) && len(samples.Successes) < sampling.Successes {
+				samples.Successes = append(samples.Successes, report.Samples.Successes[i])
+				picked = true
+			}
+		}
+		if !picked {
+			break
+		}
+	}
+	return samples
+}
@@ -0,0 +1,319 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	microsimv1alpha1 "github.com/MrSupiri/MicroSim/api/v1alpha1"
+	"github.com/MrSupiri/MicroSim/engine"
+	"github.com/MrSupiri/MicroSim/worker"
+)
+
+// reportConfigMap is the ConfigMap a worker writes its report to
+func reportConfigMap(t *testing.T, loadGenerator string, report worker.Report) *v1.ConfigMap {
+	data, err := json.Marshal(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: worker.ReportName(loadGenerator, report.Shard)},
+		Data:       map[string]string{worker.ReportKey: string(data)},
+	}
+}
+
+// finishedJob returns a Job with the condition set, an empty condition leaves the Job running
+func finishedJob(condition batchv1.JobConditionType) *batchv1.Job {
+	job := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "worker"}}
+	if condition != "" {
+		job.Status.Conditions = []batchv1.JobCondition{{Type: condition, Status: v1.ConditionTrue}}
+	}
+	return job
+}
+
+func TestCollectReports(t *testing.T) {
+	now := time.Now()
+	stats := func(finished, failed uint64) engine.Snapshot {
+		return engine.Snapshot{
+			Latency:   engine.Histogram{Total: finished},
+			Succeeded: finished - failed,
+			Failed:    failed,
+			Seconds:   map[int64]*engine.Second{now.Unix(): {Finished: finished, Failed: failed}},
+		}
+	}
+	maxErrorRate := 10
+	tests := []struct {
+		name     string
+		spec     microsimv1alpha1.LoadGeneratorSpec
+		reports  []worker.Report
+		jobs     []*batchv1.Job
+		reason   string
+		requests int
+		replicas int
+		inFlight int
+	}{
+		{
+			name: "running",
+			reports: []worker.Report{
+				{Generation: 1, Shard: 0, Stage: "ramp", Stats: stats(10, 0), InFlight: 3},
+				{Generation: 1, Shard: 1, Stats: stats(5, 0), InFlight: 1},
+			},
+			jobs:     []*batchv1.Job{finishedJob(""), finishedJob("")},
+			reason:   microsimv1alpha1.ReasonRunning,
+			requests: 15,
+			replicas: 2,
+			inFlight: 4,
+		},
+		{
+			name: "every shard completed",
+			reports: []worker.Report{
+				{Generation: 1, Shard: 0, Stats: stats(10, 0), Reason: microsimv1alpha1.ReasonCompleted},
+				{Generation: 1, Shard: 1, Stats: stats(10, 0), Reason: microsimv1alpha1.ReasonCompleted},
+			},
+			jobs:     []*batchv1.Job{finishedJob(batchv1.JobComplete), finishedJob(batchv1.JobComplete)},
+			reason:   microsimv1alpha1.ReasonCompleted,
+			requests: 20,
+		},
+		{
+			name: "one shard completed",
+			reports: []worker.Report{
+				{Generation: 1, Shard: 0, Stats: stats(10, 0), Reason: microsimv1alpha1.ReasonCompleted, InFlight: 2},
+				{Generation: 1, Shard: 1, Stats: stats(5, 0), InFlight: 1},
+			},
+			inFlight: 1,
+			jobs:     []*batchv1.Job{finishedJob(batchv1.JobComplete), finishedJob("")},
+			reason:   microsimv1alpha1.ReasonRunning,
+			requests: 15,
+			replicas: 1,
+		},
+		{
+			name: "report of an older generation",
+			reports: []worker.Report{
+				{Generation: 0, Shard: 0, Stats: stats(10, 0), Reason: microsimv1alpha1.ReasonCompleted},
+			},
+			jobs:   []*batchv1.Job{finishedJob("")},
+			reason: microsimv1alpha1.ReasonRunning, replicas: 1,
+		},
+		{
+			name:    "worker failed before reporting",
+			reports: []worker.Report{{Generation: 1, Shard: 0, Stats: stats(10, 0)}},
+			jobs:    []*batchv1.Job{finishedJob(""), finishedJob(batchv1.JobFailed)},
+			reason:  microsimv1alpha1.ReasonWorkerFailed, requests: 10,
+		},
+		{
+			// Each shard is under the limit on its own, together they are over it
+			name: "error rate of every shard together",
+			spec: microsimv1alpha1.LoadGeneratorSpec{Abort: &microsimv1alpha1.AbortSpec{MaxErrorRate: &maxErrorRate}},
+			reports: []worker.Report{
+				{Generation: 1, Shard: 0, Stats: stats(10, 1)},
+				{Generation: 1, Shard: 1, Stats: stats(10, 1)},
+				{Generation: 1, Shard: 2, Stats: stats(10, 2)},
+			},
+			jobs:     []*batchv1.Job{finishedJob(""), finishedJob(""), finishedJob("")},
+			reason:   microsimv1alpha1.ReasonErrorRateExceeded,
+			requests: 30,
+		},
+		{
+			name: "latency of every shard together",
+			spec: microsimv1alpha1.LoadGeneratorSpec{Abort: &microsimv1alpha1.AbortSpec{MaxLatency: &metav1.Duration{Duration: time.Second}, Percentile: "p50"}},
+			reports: []worker.Report{
+				{Generation: 1, Shard: 0, Stats: stats(1, 0), RecentLatency: &engine.Histogram{Counts: []uint64{1}, Total: 1}},
+				{Generation: 1, Shard: 1, Stats: stats(2, 0), RecentLatency: func() *engine.Histogram {
+					var h engine.Histogram
+					h.Record(2 * time.Second)
+					h.Record(3 * time.Second)
+					return &h
+				}()},
+			},
+			jobs:     []*batchv1.Job{finishedJob(""), finishedJob("")},
+			reason:   microsimv1alpha1.ReasonLatencyExceeded,
+			requests: 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loadGenerator := &microsimv1alpha1.LoadGenerator{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "browse", Generation: 1},
+				Spec:       tt.spec,
+			}
+			builder := fake.NewClientBuilder().WithScheme(testScheme(t))
+			for _, report := range tt.reports {
+				builder.WithObjects(reportConfigMap(t, loadGenerator.Name, report))
+			}
+			r := &LoadGeneratorReconciler{Client: builder.Build()}
+
+			result, err := r.collectReports(context.Background(), loadGenerator, len(tt.jobs), tt.jobs)
+			if err != nil {
+				t.Fatalf("collectReports() error = %v", err)
+			}
+			if result.condition.Reason != tt.reason || result.finished != (tt.reason != microsimv1alpha1.ReasonRunning) {
+				t.Errorf("condition = %s (%s), finished %v, want %s", result.condition.Reason, result.condition.Message, result.finished, tt.reason)
+			}
+			if got := int(result.stats.Latency.Total); got != tt.requests {
+				t.Errorf("requests = %d, want %d", got, tt.requests)
+			}
+			if result.replicas != tt.replicas {
+				t.Errorf("replicas = %d, want %d", result.replicas, tt.replicas)
+			}
+			if result.inFlight != tt.inFlight {
+				t.Errorf("in flight = %d, want %d", result.inFlight, tt.inFlight)
+			}
+		})
+	}
+}
+
+func TestCollectReportsWarmup(t *testing.T) {
+	loadGenerator := &microsimv1alpha1.LoadGenerator{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "browse", Generation: 1},
+		Spec:       microsimv1alpha1.LoadGeneratorSpec{Warmup: &microsimv1alpha1.WarmupSpec{Duration: &metav1.Duration{Duration: time.Minute}}},
+	}
+	reports := []worker.Report{
+		{
+			Generation: 1, Shard: 0, Stats: engine.Snapshot{Latency: engine.Histogram{Total: 5}},
+			Warmup:        &engine.Snapshot{Latency: engine.Histogram{Total: 10}, Failed: 1},
+			WarmupEndedBy: microsimv1alpha1.WarmupPassed, WarmupDuration: metav1.Duration{Duration: time.Minute},
+		},
+		{
+			Generation: 1, Shard: 1,
+			Warmup:  &engine.Snapshot{Latency: engine.Histogram{Total: 8}},
+			Warming: true, WarmupDuration: metav1.Duration{Duration: 70 * time.Second},
+		},
+	}
+	builder := fake.NewClientBuilder().WithScheme(testScheme(t))
+	for _, report := range reports {
+		builder.WithObjects(reportConfigMap(t, loadGenerator.Name, report))
+	}
+	r := &LoadGeneratorReconciler{Client: builder.Build()}
+
+	result, err := r.collectReports(context.Background(), loadGenerator, 2, []*batchv1.Job{finishedJob(""), finishedJob("")})
+	if err != nil {
+		t.Fatalf("collectReports() error = %v", err)
+	}
+	warmup := result.warmup
+	if warmup == nil || warmup.Requests != 18 || warmup.Failed != 1 || warmup.Warming != 1 || warmup.Duration.Duration != 70*time.Second {
+		t.Errorf("warmup = %+v, want 18 requests with 1 failed and 1 shard warming for 70s", warmup)
+	}
+	if got := result.stats.Latency.Total; got != 5 {
+		t.Errorf("requests = %d, want the 5 after the warmup", got)
+	}
+}
+
+func TestCheckDeadline(t *testing.T) {
+	now := time.Now()
+	timeout := &metav1.Duration{Duration: 10 * time.Minute}
+	job := func(created time.Time) *batchv1.Job {
+		return &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "worker", CreationTimestamp: metav1.NewTime(created)}}
+	}
+	tests := []struct {
+		name     string
+		timeout  *metav1.Duration
+		report   *worker.Report
+		job      *batchv1.Job
+		timedOut bool
+	}{
+		{name: "no timeout", job: job(now.Add(-time.Hour))},
+		{name: "never reported", timeout: timeout, job: job(now.Add(-5 * time.Minute))},
+		{name: "never reported past the timeout", timeout: timeout, job: job(now.Add(-12 * time.Minute)), timedOut: true},
+		{
+			name:    "running",
+			timeout: timeout,
+			report:  &worker.Report{Elapsed: metav1.Duration{Duration: 5 * time.Minute}, Updated: metav1.NewTime(now)},
+			job:     job(now.Add(-5 * time.Minute)),
+		},
+		{
+			// The shard was paused half way and resumed a minute ago, the start of the run is long gone
+			name:    "resumed",
+			timeout: timeout,
+			report:  &worker.Report{Elapsed: metav1.Duration{Duration: 5 * time.Minute}, Updated: metav1.NewTime(now.Add(-time.Hour))},
+			job:     job(now.Add(-time.Minute)),
+		},
+		{
+			// The worker restarted within its Job and is still to report again
+			name:    "restarted",
+			timeout: timeout,
+			report:  &worker.Report{Elapsed: metav1.Duration{Duration: 9 * time.Minute}, Updated: metav1.NewTime(now.Add(-90 * time.Second))},
+			job:     job(now.Add(-time.Hour)),
+		},
+		{
+			name:     "stopped reporting past the timeout",
+			timeout:  timeout,
+			report:   &worker.Report{Elapsed: metav1.Duration{Duration: 9 * time.Minute}, Updated: metav1.NewTime(now.Add(-3 * time.Minute))},
+			job:      job(now.Add(-time.Hour)),
+			timedOut: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var result runResult
+			result.checkDeadline(tt.timeout, tt.report, tt.job)
+			if result.finished != tt.timedOut || (tt.timedOut && result.condition.Reason != microsimv1alpha1.ReasonTimeout) {
+				t.Errorf("checkDeadline() finished = %v (%s), want %v", result.finished, result.condition.Reason, tt.timedOut)
+			}
+		})
+	}
+}
+
+func TestMergeSamples(t *testing.T) {
+	sample := func(template string, at int64) microsimv1alpha1.Sample {
+		return microsimv1alpha1.Sample{Template: template, Time: metav1.Unix(at, 0)}
+	}
+	reports := []worker.Report{
+		{Samples: microsimv1alpha1.Samples{
+			Errors:    []microsimv1alpha1.Sample{sample("a", 1), sample("c", 3)},
+			Successes: []microsimv1alpha1.Sample{sample("a1", 0), sample("a2", 0), sample("a3", 0)},
+		}},
+		{Samples: microsimv1alpha1.Samples{
+			Errors:    []microsimv1alpha1.Sample{sample("b", 2), sample("d", 4)},
+			Successes: []microsimv1alpha1.Sample{sample("b1", 0)},
+		}},
+	}
+
+	samples := mergeSamples(reports, microsimv1alpha1.SamplingSpec{Errors: 3, Successes: 4})
+	var errors, successes []string
+	for _, s := range samples.Errors {
+		errors = append(errors, s.Template)
+	}
+	for _, s := range samples.Successes {
+		successes = append(successes, s.Template)
+	}
+	if want := []string{"b", "c", "d"}; !reflect.DeepEqual(errors, want) {
+		t.Errorf("errors = %v, want the latest %v", errors, want)
+	}
+	if want := []string{"a1", "b1", "a2", "a3"}; !reflect.DeepEqual(successes, want) {
+		t.Errorf("successes = %v, want %v taken from each shard in turn", successes, want)
+	}
+}
+
+func TestWriteStatus(t *testing.T) {
+	key := types.NamespacedName{Namespace: "default", Name: "browse"}
+	loadGenerator := &microsimv1alpha1.LoadGenerator{
+		ObjectMeta: metav1.ObjectMeta{Namespace: key.Namespace, Name: key.Name},
+		Status:     microsimv1alpha1.LoadGeneratorStatus{DoneRequests: 7},
+	}
+	r := &LoadGeneratorReconciler{Client: fake.NewClientBuilder().WithScheme(testScheme(t)).WithObjects(loadGenerator).Build()}
+
+	if err := r.writeStatus(context.Background(), key, func(status *microsimv1alpha1.LoadGeneratorStatus) {
+		status.CurrentStage = "ramp"
+	}); err != nil {
+		t.Fatalf("writeStatus() error = %v", err)
+	}
+	var got microsimv1alpha1.LoadGenerator
+	if err := r.Get(context.Background(), key, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Status.CurrentStage != "ramp" || got.Status.DoneRequests != 7 {
+		t.Errorf("stage and done requests = %q, %d, want ramp and the other fields untouched", got.Status.CurrentStage, got.Status.DoneRequests)
+	}
+
+	if err := r.writeStatus(context.Background(), types.NamespacedName{Namespace: "default", Name: "gone"}, func(*microsimv1alpha1.LoadGeneratorStatus) {}); err != nil {
+		t.Errorf("writeStatus() of a deleted load generator error = %v, want nil", err)
+	}
+}
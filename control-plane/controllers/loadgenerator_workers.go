@@ -0,0 +1,215 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	microsimv1alpha1 "github.com/MrSupiri/MicroSim/api/v1alpha1"
+	"github.com/MrSupiri/MicroSim/worker"
+)
+
+const (
+	// workerAccount is the name of the service account of the workers and of the ClusterRole it's bound
+	// to, the ClusterRole is installed along with the controller
+	workerAccount = "microsim-worker"
+	// generationLabel holds the generation of the load generator a worker was launched for
+	generationLabel = "microsim.isala.me/generation"
+	// shardLabel holds the shard a worker sends
+	shardLabel = "microsim.isala.me/shard"
+	// workerBackoffLimit is how many times a worker pod is retried before its Job fails
+	workerBackoffLimit int32 = 2
+)
+
+// errStaleWorker is returned while a Job of an older generation is in the way of a worker of the current one
+var errStaleWorker = errors.New("a worker of an older generation is still being deleted")
+
+// workerName is the name of the Job of a shard
+func workerName(loadGenerator string, shard int) string {
+	return fmt.Sprintf("%s-worker-%d", loadGenerator, shard)
+}
+
+// provisionWorkerAccess creates the service account the workers run as in the namespace of the load
+// generator, it's shared by all the load generators in the namespace so it isn't owned by any of them
+func (r *LoadGeneratorReconciler) provisionWorkerAccess(ctx context.Context, loadGenerator *microsimv1alpha1.LoadGenerator) error {
+	logger := log.FromContext(ctx)
+	labels := map[string]string{
+		"app.kubernetes.io/instance":   workerAccount,
+		"app.kubernetes.io/managed-by": "microsim-loadgenerator",
+		"app.kubernetes.io/created-by": "microsim",
+	}
+
+	serviceAccount := v1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      workerAccount,
+			Namespace: loadGenerator.Namespace,
+			Labels:    labels,
+		},
+	}
+	if err := r.Create(ctx, &serviceAccount); IgnoreAlreadyExist(err) != nil {
+		logger.Error(err, fmt.Sprintf("failed to create service account %s", serviceAccount.Name))
+		return err
+	}
+
+	roleBinding := rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      workerAccount,
+			Namespace: loadGenerator.Namespace,
+			Labels:    labels,
+		},
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: rbacv1.GroupName,
+			Kind:     "ClusterRole",
+			Name:     workerAccount,
+		},
+		Subjects: []rbacv1.Subject{{
+			Kind:      rbacv1.ServiceAccountKind,
+			Name:      workerAccount,
+			Namespace: loadGenerator.Namespace,
+		}},
+	}
+	if err := r.Create(ctx, &roleBinding); IgnoreAlreadyExist(err) != nil {
+		logger.Error(err, fmt.Sprintf("failed to create role binding %s", roleBinding.Name))
+		return err
+	}
+	return nil
+}
+
+// provisionWorkers creates a Job for every shard of the current generation that doesn't have one,
+// the Jobs are returned by shard. It fails with errStaleWorker while a Job of the same name is left
+// over from an older generation or being deleted, adopting it would mix up the two runs
+func (r *LoadGeneratorReconciler) provisionWorkers(ctx context.Context, loadGenerator *microsimv1alpha1.LoadGenerator, shards int) ([]*batchv1.Job, error) {
+	logger := log.FromContext(ctx)
+	jobs := make([]*batchv1.Job, shards)
+
+	for shard := 0; shard < shards; shard++ {
+		name := workerName(loadGenerator.Name, shard)
+		var job batchv1.Job
+		err := r.Get(ctx, types.NamespacedName{Namespace: loadGenerator.Namespace, Name: name}, &job)
+		if err == nil {
+			if !job.DeletionTimestamp.IsZero() || job.Labels[generationLabel] != strconv.FormatInt(loadGenerator.Generation, 10) {
+				return nil, fmt.Errorf("%s: %w", name, errStaleWorker)
+			}
+			jobs[shard] = &job
+			continue
+		}
+		if client.IgnoreNotFound(err) != nil {
+			return nil, err
+		}
+
+		job = r.workerJob(loadGenerator, shard, shards)
+		if err := controllerutil.SetControllerReference(loadGenerator, &job, r.Scheme); err != nil {
+			return nil, err
+		}
+		if err := r.Create(ctx, &job); IgnoreAlreadyExist(err) != nil {
+			logger.Error(err, fmt.Sprintf("failed to create job %s", name))
+			return nil, err
+		}
+		logger.V(1).Info("created worker", "name", job.GetName(), "shard", shard)
+		jobs[shard] = &job
+	}
+	return jobs, nil
+}
+
+func (r *LoadGeneratorReconciler) workerJob(loadGenerator *microsimv1alpha1.LoadGenerator, shard int, shards int) batchv1.Job {
+	name := workerName(loadGenerator.Name, shard)
+	labels := worker.Labels(name, loadGenerator)
+	labels[generationLabel] = strconv.FormatInt(loadGenerator.Generation, 10)
+	labels[shardLabel] = strconv.Itoa(shard)
+	backoffLimit := workerBackoffLimit
+
+	return batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: loadGenerator.Namespace,
+			Labels:    labels,
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: &backoffLimit,
+			Template: v1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: labels,
+				},
+				Spec: v1.PodSpec{
+					ServiceAccountName: workerAccount,
+					RestartPolicy:      v1.RestartPolicyNever,
+					Containers: []v1.Container{{
+						Name:            "worker",
+						Image:           r.WorkerImage,
+						ImagePullPolicy: v1.PullIfNotPresent,
+						Command:         []string{"/worker"},
+						Args: []string{
+							"--load-generator", loadGenerator.Name,
+							"--namespace", loadGenerator.Namespace,
+							"--shard", strconv.Itoa(shard),
+							"--shards", strconv.Itoa(shards),
+						},
+					}},
+				},
+			},
+		},
+	}
+}
+
+// deleteWorkers stops every worker of the load generator, their reports are kept
+func (r *LoadGeneratorReconciler) deleteWorkers(ctx context.Context, loadGenerator *microsimv1alpha1.LoadGenerator) error {
+	return r.deleteWorkersIf(ctx, loadGenerator, func(batchv1.Job) bool { return true })
+}
+
+// deleteStaleWorkers stops the workers launched for an older generation of the load generator
+func (r *LoadGeneratorReconciler) deleteStaleWorkers(ctx context.Context, loadGenerator *microsimv1alpha1.LoadGenerator) error {
+	generation := strconv.FormatInt(loadGenerator.Generation, 10)
+	return r.deleteWorkersIf(ctx, loadGenerator, func(job batchv1.Job) bool { return job.Labels[generationLabel] != generation })
+}
+
+func (r *LoadGeneratorReconciler) deleteWorkersIf(ctx context.Context, loadGenerator *microsimv1alpha1.LoadGenerator, stale func(batchv1.Job) bool) error {
+	logger := log.FromContext(ctx)
+
+	var jobList batchv1.JobList
+	if err := r.List(ctx, &jobList, client.InNamespace(loadGenerator.Namespace),
+		client.MatchingLabels{"app.kubernetes.io/part-of": loadGenerator.Name, "app.kubernetes.io/managed-by": "microsim-loadgenerator"}); err != nil {
+		return err
+	}
+	for _, job := range jobList.Items {
+		if !job.DeletionTimestamp.IsZero() || !stale(job) {
+			continue
+		}
+		// Pods of the Job are removed along with it
+		if err := r.Delete(ctx, &job, client.PropagationPolicy(metav1.DeletePropagationBackground)); client.IgnoreNotFound(err) != nil {
+			logger.Error(err, fmt.Sprintf("failed to delete job %s", job.Name))
+			return err
+		}
+		logger.V(1).Info("deleted worker", "name", job.GetName())
+	}
+	return nil
+}
+
+// jobFailed reports whether the Job gave up on its pods
+func jobFailed(job *batchv1.Job) bool {
+	for _, condition := range job.Status.Conditions {
+		if condition.Type == batchv1.JobFailed && condition.Status == v1.ConditionTrue {
+			return true
+		}
+	}
+	return false
+}
+
+// jobActive reports whether the Job is still running its pod
+func jobActive(job *batchv1.Job) bool {
+	for _, condition := range job.Status.Conditions {
+		if (condition.Type == batchv1.JobComplete || condition.Type == batchv1.JobFailed) && condition.Status == v1.ConditionTrue {
+			return false
+		}
+	}
+	return true
+}
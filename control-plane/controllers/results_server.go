@@ -0,0 +1,40 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// ResultsServer serves the results directory read only over HTTP under /results/, so the exported
+// results can be fetched by CI tooling without access to the volume
+type ResultsServer struct {
+	Addr string
+	Dir  string
+}
+
+// Start serves the results until ctx is cancelled, it implements manager.Runnable
+func (s *ResultsServer) Start(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithName("results-server")
+	mux := http.NewServeMux()
+	mux.Handle("/results/", http.StripPrefix("/results/", http.FileServer(http.Dir(s.Dir))))
+	server := &http.Server{Addr: s.Addr, Handler: mux}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			logger.Error(err, "failed to shut down results server")
+		}
+	}()
+
+	logger.Info("serving results", "addr", s.Addr, "dir", s.Dir)
+	if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,352 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	microsimv1alpha1 "github.com/MrSupiri/MicroSim/api/v1alpha1"
+	"github.com/MrSupiri/MicroSim/engine"
+	"github.com/MrSupiri/MicroSim/worker"
+)
+
+// RunComparisonReconciler reconciles a RunComparison object
+type RunComparisonReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+//+kubebuilder:rbac:groups=microsim.isala.me,resources=runcomparisons,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=microsim.isala.me,resources=runcomparisons/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=microsim.isala.me,resources=runcomparisons/finalizers,verbs=update
+//+kubebuilder:rbac:groups=microsim.isala.me,resources=loadgenerators,verbs=get;list;watch
+//+kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch
+
+func (r *RunComparisonReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	logger.Info("Reconciling")
+
+	var comparison microsimv1alpha1.RunComparison
+	if err := r.Get(ctx, req.NamespacedName, &comparison); err != nil {
+		return ctrl.Result{Requeue: false}, client.IgnoreNotFound(err)
+	}
+
+	baseline, baselineStats, baselineMissing, err := r.finishedRun(ctx, comparison.Spec.Baseline.NamespacedName(req.Namespace))
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	candidate, candidateStats, candidateMissing, err := r.finishedRun(ctx, comparison.Spec.Candidate.NamespacedName(req.Namespace))
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	patch := client.MergeFrom(comparison.DeepCopy())
+	status := &comparison.Status
+	if baseline == nil || candidate == nil {
+		var waiting []string
+		if baseline == nil {
+			waiting = append(waiting, "baseline "+comparison.Spec.Baseline.Name)
+		}
+		if candidate == nil {
+			waiting = append(waiting, "candidate "+comparison.Spec.Candidate.Name)
+		}
+		notCompared(status, comparison.Generation, microsimv1alpha1.ReasonWaitingForRuns,
+			fmt.Sprintf("Waiting for the %s to finish", strings.Join(waiting, " and ")))
+		return ctrl.Result{}, r.Status().Patch(ctx, &comparison, patch)
+	}
+	if baselineMissing > 0 || candidateMissing > 0 {
+		var missing []string
+		if baselineMissing > 0 {
+			missing = append(missing, fmt.Sprintf("%d reports of baseline %s", baselineMissing, baseline.Name))
+		}
+		if candidateMissing > 0 {
+			missing = append(missing, fmt.Sprintf("%d reports of candidate %s", candidateMissing, candidate.Name))
+		}
+		// The reports won't come back, a new run of the load generator is compared again
+		logger.Info("reports of a finished run are missing", "missing", missing)
+		notCompared(status, comparison.Generation, microsimv1alpha1.ReasonReportsMissing,
+			fmt.Sprintf("Missing %s for the generation that finished", strings.Join(missing, " and ")))
+		return ctrl.Result{}, r.Status().Patch(ctx, &comparison, patch)
+	}
+
+	// The runs don't change once they're finished, only a new run is compared again
+	compared := meta.FindStatusCondition(status.Conditions, microsimv1alpha1.ConditionCompared)
+	if compared != nil && compared.Status == metav1.ConditionTrue && compared.ObservedGeneration == comparison.Generation &&
+		sameRun(status.Baseline, baseline) && sameRun(status.Candidate, candidate) {
+		return ctrl.Result{Requeue: false}, nil
+	}
+
+	alpha := 1 - float64(confidenceOf(comparison.Spec))/100
+	status.Baseline, status.Candidate = runReference(baseline), runReference(candidate)
+	status.Latency = compareLatency(baselineStats.Latency, candidateStats.Latency, alpha)
+	status.ErrorRate = compareRate(baselineStats.Failed, baselineStats.Latency.Total, candidateStats.Failed, candidateStats.Latency.Total, alpha)
+	status.Services = compareServices(baselineStats.Services, candidateStats.Services, alpha)
+	status.Summary = summarizeComparison(status)
+	meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+		Type:               microsimv1alpha1.ConditionCompared,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: comparison.Generation,
+		Reason:             microsimv1alpha1.ReasonRunsCompared,
+		Message:            fmt.Sprintf("Compared %s with %s", candidate.Name, baseline.Name),
+	})
+	meta.SetStatusCondition(&status.Conditions, regressedCondition(status, comparison.Generation))
+	logger.Info("runs compared", "summary", status.Summary)
+	return ctrl.Result{}, r.Status().Patch(ctx, &comparison, patch)
+}
+
+// finishedRun returns the load generator with the statistics of its run, it's nil while the load generator
+// is missing or still running. missing is the number of workers without a report of the generation that finished
+func (r *RunComparisonReconciler) finishedRun(ctx context.Context, key types.NamespacedName) (*microsimv1alpha1.LoadGenerator, engine.Snapshot, int, error) {
+	var loadGenerator microsimv1alpha1.LoadGenerator
+	if err := r.Get(ctx, key, &loadGenerator); err != nil {
+		return nil, engine.Snapshot{}, 0, client.IgnoreNotFound(err)
+	}
+	finished := meta.FindStatusCondition(loadGenerator.Status.Conditions, microsimv1alpha1.ConditionFinished)
+	if finished == nil || finished.Status != metav1.ConditionTrue || finished.ObservedGeneration != loadGenerator.Generation {
+		return nil, engine.Snapshot{}, 0, nil
+	}
+
+	// The status only holds percentiles, the histograms the tests need are in the reports of the workers
+	var stats engine.Snapshot
+	missing := 0
+	for shard := 0; shard < shardsOf(loadGenerator.Spec); shard++ {
+		var configMap v1.ConfigMap
+		err := r.Get(ctx, types.NamespacedName{Namespace: key.Namespace, Name: worker.ReportName(key.Name, shard)}, &configMap)
+		if client.IgnoreNotFound(err) != nil {
+			return nil, engine.Snapshot{}, 0, err
+		}
+		report, ok, err := worker.ReadReport(&configMap)
+		if err != nil {
+			return nil, engine.Snapshot{}, 0, err
+		}
+		if !ok || report.Generation != loadGenerator.Generation {
+			missing++
+			continue
+		}
+		stats.Merge(report.Stats)
+	}
+	return &loadGenerator, stats, missing, nil
+}
+
+// notCompared sets the Compared condition to false and clears the results of an earlier comparison,
+// they belong to other runs or another generation of the comparison
+func notCompared(status *microsimv1alpha1.RunComparisonStatus, generation int64, reason string, message string) {
+	status.Baseline, status.Candidate = nil, nil
+	status.Latency, status.ErrorRate, status.Services = nil, nil, nil
+	status.Summary = ""
+	meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+		Type:               microsimv1alpha1.ConditionCompared,
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: generation,
+		Reason:             reason,
+		Message:            message,
+	})
+	meta.RemoveStatusCondition(&status.Conditions, microsimv1alpha1.ConditionRegressed)
+}
+
+func confidenceOf(spec microsimv1alpha1.RunComparisonSpec) int {
+	if spec.Confidence <= 0 {
+		return 95
+	}
+	return spec.Confidence
+}
+
+func runReference(loadGenerator *microsimv1alpha1.LoadGenerator) *microsimv1alpha1.RunReference {
+	run := &microsimv1alpha1.RunReference{
+		Name:       loadGenerator.Name,
+		Namespace:  loadGenerator.Namespace,
+		Generation: loadGenerator.Generation,
+		StartTime:  loadGenerator.Status.StartTime,
+		Seed:       loadGenerator.Status.Seed,
+		Requests:   loadGenerator.Status.DoneRequests,
+	}
+	if finished := meta.FindStatusCondition(loadGenerator.Status.Conditions, microsimv1alpha1.ConditionFinished); finished != nil {
+		run.Reason = finished.Reason
+	}
+	return run
+}
+
+// sameRun returns whether the reference points to the current run of the load generator
+func sameRun(run *microsimv1alpha1.RunReference, loadGenerator *microsimv1alpha1.LoadGenerator) bool {
+	return run != nil && run.Name == loadGenerator.Name && run.Namespace == loadGenerator.Namespace &&
+		run.Generation == loadGenerator.Generation && run.StartTime.Equal(loadGenerator.Status.StartTime)
+}
+
+func compareLatency(baseline engine.Histogram, candidate engine.Histogram, alpha float64) *microsimv1alpha1.LatencyComparison {
+	if baseline.Total == 0 || candidate.Total == 0 {
+		return nil
+	}
+	b, c := baseline.Latency(), candidate.Latency()
+	test := engine.MannWhitney(baseline, candidate)
+	return &microsimv1alpha1.LatencyComparison{
+		P50:         durationDelta(b.P50, c.P50),
+		P90:         durationDelta(b.P90, c.P90),
+		P95:         durationDelta(b.P95, c.P95),
+		P99:         durationDelta(b.P99, c.P99),
+		Mean:        durationDelta(b.Mean, c.Mean),
+		PValue:      formatPValue(test.P),
+		Significant: test.Significant(alpha),
+		Slower:      test.Z > 0,
+	}
+}
+
+func durationDelta(baseline time.Duration, candidate time.Duration) microsimv1alpha1.DurationDelta {
+	delta := microsimv1alpha1.DurationDelta{
+		Baseline:  metav1.Duration{Duration: baseline},
+		Candidate: metav1.Duration{Duration: candidate},
+		Change:    "n/a",
+	}
+	if baseline > 0 {
+		delta.Change = fmt.Sprintf("%+.1f%%", float64(candidate-baseline)/float64(baseline)*100)
+	}
+	return delta
+}
+
+func compareRate(baselineFailed uint64, baselineTotal uint64, candidateFailed uint64, candidateTotal uint64, alpha float64) *microsimv1alpha1.RateComparison {
+	if baselineTotal == 0 || candidateTotal == 0 {
+		return nil
+	}
+	b := float64(baselineFailed) / float64(baselineTotal) * 100
+	c := float64(candidateFailed) / float64(candidateTotal) * 100
+	test := engine.TwoProportions(baselineFailed, baselineTotal, candidateFailed, candidateTotal)
+	return &microsimv1alpha1.RateComparison{
+		Baseline:    fmt.Sprintf("%.2f%%", b),
+		Candidate:   fmt.Sprintf("%.2f%%", c),
+		Change:      fmt.Sprintf("%+.2fpp", c-b),
+		PValue:      formatPValue(test.P),
+		Significant: test.Significant(alpha),
+		Higher:      test.Z > 0,
+	}
+}
+
+// compareServices compares the services by designation, the ones called in a single run are listed
+// with their calls alone
+func compareServices(baseline map[string]*engine.ServiceSnapshot, candidate map[string]*engine.ServiceSnapshot, alpha float64) map[string]microsimv1alpha1.ServiceComparison {
+	services := map[string]microsimv1alpha1.ServiceComparison{}
+	for designation, b := range baseline {
+		services[designation] = microsimv1alpha1.ServiceComparison{BaselineCalls: int(b.Calls)}
+	}
+	for designation, c := range candidate {
+		service := services[designation]
+		service.CandidateCalls = int(c.Calls)
+		if b, ok := baseline[designation]; ok {
+			service.ErrorRate = compareRate(b.Failed, b.Calls, c.Failed, c.Calls, alpha)
+			service.Latency = compareLatency(b.Latency, c.Latency, alpha)
+		}
+		services[designation] = service
+	}
+	return services
+}
+
+// regressedCondition is true when the candidate got significantly slower or fails significantly more often
+func regressedCondition(status *microsimv1alpha1.RunComparisonStatus, generation int64) metav1.Condition {
+	condition := metav1.Condition{
+		Type:               microsimv1alpha1.ConditionRegressed,
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: generation,
+		Reason:             microsimv1alpha1.ReasonNoRegression,
+		Message:            "The candidate is not significantly slower and doesn't fail significantly more often",
+	}
+	switch {
+	case status.ErrorRate != nil && status.ErrorRate.Significant && status.ErrorRate.Higher:
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = microsimv1alpha1.ReasonErrorRateRegressed
+		condition.Message = fmt.Sprintf("Error rate went from %s to %s (p=%s)", status.ErrorRate.Baseline, status.ErrorRate.Candidate, status.ErrorRate.PValue)
+	case status.Latency != nil && status.Latency.Significant && status.Latency.Slower:
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = microsimv1alpha1.ReasonLatencyRegressed
+		condition.Message = fmt.Sprintf("Latency got slower, p50 %s p99 %s (p=%s)", status.Latency.P50.Change, status.Latency.P99.Change, status.Latency.PValue)
+	}
+	return condition
+}
+
+// summarizeComparison returns a one line form of the comparison, like
+// "p50 +12.0% p99 +30.5% slower (p=0.0001), errors 1.00% -> 1.20% no significant change (p=0.4512)"
+func summarizeComparison(status *microsimv1alpha1.RunComparisonStatus) string {
+	var parts []string
+	if latency := status.Latency; latency != nil {
+		verdict := "no significant change"
+		if latency.Significant && latency.Slower {
+			verdict = "slower"
+		} else if latency.Significant {
+			verdict = "faster"
+		}
+		parts = append(parts, fmt.Sprintf("p50 %s p99 %s %s (p=%s)", latency.P50.Change, latency.P99.Change, verdict, latency.PValue))
+	}
+	if rate := status.ErrorRate; rate != nil {
+		verdict := "no significant change"
+		if rate.Significant && rate.Higher {
+			verdict = "higher"
+		} else if rate.Significant {
+			verdict = "lower"
+		}
+		parts = append(parts, fmt.Sprintf("errors %s -> %s %s (p=%s)", rate.Baseline, rate.Candidate, verdict, rate.PValue))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func formatPValue(p float64) string {
+	if p < 0.0001 {
+		return "<0.0001"
+	}
+	return fmt.Sprintf("%.4f", p)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *RunComparisonReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&microsimv1alpha1.RunComparison{}).
+		// Runs finishing are compared right away
+		Watches(&source.Kind{Type: &microsimv1alpha1.LoadGenerator{}}, handler.EnqueueRequestsFromMapFunc(r.comparisonsOf)).
+		Complete(r)
+}
+
+// comparisonsOf maps a load generator to the comparisons it's the baseline or the candidate of
+func (r *RunComparisonReconciler) comparisonsOf(loadGenerator client.Object) []reconcile.Request {
+	var comparisonList microsimv1alpha1.RunComparisonList
+	if err := r.List(context.Background(), &comparisonList); err != nil {
+		return nil
+	}
+
+	key := types.NamespacedName{Namespace: loadGenerator.GetNamespace(), Name: loadGenerator.GetName()}
+	var requests []reconcile.Request
+	for _, comparison := range comparisonList.Items {
+		if comparison.Spec.Baseline.NamespacedName(comparison.Namespace) == key || comparison.Spec.Candidate.NamespacedName(comparison.Namespace) == key {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{
+				Namespace: comparison.GetNamespace(),
+				Name:      comparison.GetName(),
+			}})
+		}
+	}
+	return requests
+}
@@ -0,0 +1,218 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	microsimv1alpha1 "github.com/MrSupiri/MicroSim/api/v1alpha1"
+	"github.com/MrSupiri/MicroSim/engine"
+	"github.com/MrSupiri/MicroSim/worker"
+)
+
+// finishedLoadGenerator returns a load generator that finished its first generation
+func finishedLoadGenerator(name string) *microsimv1alpha1.LoadGenerator {
+	start := metav1.NewTime(time.Date(2021, 6, 1, 10, 0, 0, 0, time.UTC))
+	return &microsimv1alpha1.LoadGenerator{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name, Generation: 1},
+		Status: microsimv1alpha1.LoadGeneratorStatus{
+			StartTime: &start,
+			Conditions: []metav1.Condition{{
+				Type: microsimv1alpha1.ConditionFinished, Status: metav1.ConditionTrue, ObservedGeneration: 1, Reason: microsimv1alpha1.ReasonCompleted,
+			}},
+		},
+	}
+}
+
+// runStats are the statistics of 1000 requests of latency, failed of them failing
+func runStats(latency time.Duration, failed uint64) engine.Snapshot {
+	return engine.Snapshot{Latency: histogram(1000, latency), Succeeded: 1000 - failed, Failed: failed}
+}
+
+func TestRunComparisonReconcile(t *testing.T) {
+	tests := []struct {
+		name      string
+		objects   func(t *testing.T) []client.Object
+		reason    string
+		regressed string
+	}{
+		{
+			name: "waiting for the candidate",
+			objects: func(t *testing.T) []client.Object {
+				return []client.Object{finishedLoadGenerator("baseline"), reportConfigMap(t, "baseline", worker.Report{Generation: 1, Stats: runStats(10*time.Millisecond, 0)})}
+			},
+			reason: microsimv1alpha1.ReasonWaitingForRuns,
+		},
+		{
+			name: "report missing",
+			objects: func(t *testing.T) []client.Object {
+				return []client.Object{
+					finishedLoadGenerator("baseline"), reportConfigMap(t, "baseline", worker.Report{Generation: 1, Stats: runStats(10*time.Millisecond, 0)}),
+					finishedLoadGenerator("candidate"),
+				}
+			},
+			reason: microsimv1alpha1.ReasonReportsMissing,
+		},
+		{
+			name: "report of another generation",
+			objects: func(t *testing.T) []client.Object {
+				return []client.Object{
+					finishedLoadGenerator("baseline"), reportConfigMap(t, "baseline", worker.Report{Generation: 1, Stats: runStats(10*time.Millisecond, 0)}),
+					finishedLoadGenerator("candidate"), reportConfigMap(t, "candidate", worker.Report{Generation: 2, Stats: runStats(10*time.Millisecond, 0)}),
+				}
+			},
+			reason: microsimv1alpha1.ReasonReportsMissing,
+		},
+		{
+			name: "slower",
+			objects: func(t *testing.T) []client.Object {
+				return []client.Object{
+					finishedLoadGenerator("baseline"), reportConfigMap(t, "baseline", worker.Report{Generation: 1, Stats: runStats(10*time.Millisecond, 10)}),
+					finishedLoadGenerator("candidate"), reportConfigMap(t, "candidate", worker.Report{Generation: 1, Stats: runStats(20*time.Millisecond, 10)}),
+				}
+			},
+			reason:    microsimv1alpha1.ReasonRunsCompared,
+			regressed: microsimv1alpha1.ReasonLatencyRegressed,
+		},
+		{
+			name: "fails more often",
+			objects: func(t *testing.T) []client.Object {
+				return []client.Object{
+					finishedLoadGenerator("baseline"), reportConfigMap(t, "baseline", worker.Report{Generation: 1, Stats: runStats(10*time.Millisecond, 10)}),
+					finishedLoadGenerator("candidate"), reportConfigMap(t, "candidate", worker.Report{Generation: 1, Stats: runStats(10*time.Millisecond, 100)}),
+				}
+			},
+			reason:    microsimv1alpha1.ReasonRunsCompared,
+			regressed: microsimv1alpha1.ReasonErrorRateRegressed,
+		},
+		{
+			name: "fails less often",
+			objects: func(t *testing.T) []client.Object {
+				return []client.Object{
+					finishedLoadGenerator("baseline"), reportConfigMap(t, "baseline", worker.Report{Generation: 1, Stats: runStats(10*time.Millisecond, 100)}),
+					finishedLoadGenerator("candidate"), reportConfigMap(t, "candidate", worker.Report{Generation: 1, Stats: runStats(10*time.Millisecond, 10)}),
+				}
+			},
+			reason:    microsimv1alpha1.ReasonRunsCompared,
+			regressed: microsimv1alpha1.ReasonNoRegression,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := types.NamespacedName{Namespace: "default", Name: "upgrade"}
+			// The status holds the comparison of an earlier generation
+			comparison := &microsimv1alpha1.RunComparison{
+				ObjectMeta: metav1.ObjectMeta{Namespace: key.Namespace, Name: key.Name, Generation: 2},
+				Spec: microsimv1alpha1.RunComparisonSpec{
+					Baseline:  microsimv1alpha1.LoadGeneratorRef{Name: "baseline"},
+					Candidate: microsimv1alpha1.LoadGeneratorRef{Name: "candidate"},
+				},
+				Status: microsimv1alpha1.RunComparisonStatus{
+					Baseline:  &microsimv1alpha1.RunReference{Name: "old"},
+					ErrorRate: &microsimv1alpha1.RateComparison{Change: "+1.00pp"},
+					Latency:   &microsimv1alpha1.LatencyComparison{Slower: true},
+					Services:  map[string]microsimv1alpha1.ServiceComparison{"old": {}},
+					Summary:   "p50 +100.0% p99 +100.0% slower (p=<0.0001)",
+					Conditions: []metav1.Condition{
+						{Type: microsimv1alpha1.ConditionCompared, Status: metav1.ConditionTrue, ObservedGeneration: 1, Reason: microsimv1alpha1.ReasonRunsCompared},
+						{Type: microsimv1alpha1.ConditionRegressed, Status: metav1.ConditionTrue, ObservedGeneration: 1, Reason: microsimv1alpha1.ReasonLatencyRegressed},
+					},
+				},
+			}
+			c := fake.NewClientBuilder().WithScheme(testScheme(t)).WithObjects(append(tt.objects(t), comparison)...).Build()
+			r := &RunComparisonReconciler{Client: c, Scheme: testScheme(t)}
+
+			if _, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: key}); err != nil {
+				t.Fatalf("Reconcile() error = %v", err)
+			}
+			var got microsimv1alpha1.RunComparison
+			if err := c.Get(context.Background(), key, &got); err != nil {
+				t.Fatal(err)
+			}
+			compared := meta.FindStatusCondition(got.Status.Conditions, microsimv1alpha1.ConditionCompared)
+			if compared == nil || compared.Reason != tt.reason || compared.ObservedGeneration != 2 {
+				t.Fatalf("Compared = %+v, want %s of generation 2", compared, tt.reason)
+			}
+			regressed := meta.FindStatusCondition(got.Status.Conditions, microsimv1alpha1.ConditionRegressed)
+			if tt.regressed == "" {
+				if regressed != nil {
+					t.Errorf("Regressed = %+v, want none", regressed)
+				}
+				if got.Status.Baseline != nil || got.Status.Latency != nil || got.Status.ErrorRate != nil || got.Status.Services != nil || got.Status.Summary != "" {
+					t.Errorf("status = %+v, want the earlier comparison cleared", got.Status)
+				}
+				return
+			}
+			if regressed == nil || regressed.Reason != tt.regressed {
+				t.Errorf("Regressed = %+v, want %s", regressed, tt.regressed)
+			}
+			if got.Status.Baseline == nil || got.Status.Baseline.Name != "baseline" || got.Status.Latency == nil || got.Status.ErrorRate == nil {
+				t.Errorf("status = %+v, want the comparison of baseline and candidate", got.Status)
+			}
+		})
+	}
+}
+
+func TestRegressedCondition(t *testing.T) {
+	tests := []struct {
+		name   string
+		status microsimv1alpha1.RunComparisonStatus
+		want   string
+	}{
+		{name: "nothing compared", want: microsimv1alpha1.ReasonNoRegression},
+		{
+			name:   "error rate significantly higher",
+			status: microsimv1alpha1.RunComparisonStatus{ErrorRate: &microsimv1alpha1.RateComparison{Significant: true, Higher: true}},
+			want:   microsimv1alpha1.ReasonErrorRateRegressed,
+		},
+		{
+			// A change of +0.00pp is still lower when it's rounded away
+			name:   "error rate significantly lower",
+			status: microsimv1alpha1.RunComparisonStatus{ErrorRate: &microsimv1alpha1.RateComparison{Significant: true, Change: "+0.00pp"}},
+			want:   microsimv1alpha1.ReasonNoRegression,
+		},
+		{
+			name:   "error rate higher by chance",
+			status: microsimv1alpha1.RunComparisonStatus{ErrorRate: &microsimv1alpha1.RateComparison{Higher: true}},
+			want:   microsimv1alpha1.ReasonNoRegression,
+		},
+		{
+			name:   "slower",
+			status: microsimv1alpha1.RunComparisonStatus{Latency: &microsimv1alpha1.LatencyComparison{Significant: true, Slower: true}},
+			want:   microsimv1alpha1.ReasonLatencyRegressed,
+		},
+		{
+			name:   "faster",
+			status: microsimv1alpha1.RunComparisonStatus{Latency: &microsimv1alpha1.LatencyComparison{Significant: true}},
+			want:   microsimv1alpha1.ReasonNoRegression,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := regressedCondition(&tt.status, 3)
+			if got.Reason != tt.want || (got.Status == metav1.ConditionTrue) != (tt.want != microsimv1alpha1.ReasonNoRegression) {
+				t.Errorf("regressedCondition() = %s (%s), want %s", got.Status, got.Reason, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompareRate(t *testing.T) {
+	if got := compareRate(0, 0, 1, 10, 0.05); got != nil {
+		t.Errorf("compareRate() without baseline requests = %+v, want nil", got)
+	}
+	got := compareRate(10, 1000, 100, 1000, 0.05)
+	if got.Baseline != "1.00%" || got.Candidate != "10.00%" || got.Change != "+9.00pp" || !got.Significant || !got.Higher {
+		t.Errorf("compareRate() = %+v, want a significantly higher rate going from 1.00%% to 10.00%%", got)
+	}
+	if got := compareRate(100, 1000, 10, 1000, 0.05); !got.Significant || got.Higher {
+		t.Errorf("compareRate() = %+v, want a significantly lower rate", got)
+	}
+}
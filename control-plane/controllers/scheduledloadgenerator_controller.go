@@ -0,0 +1,334 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ref "k8s.io/client-go/tools/reference"
+	"k8s.io/client-go/util/retry"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	microsimv1alpha1 "github.com/MrSupiri/MicroSim/api/v1alpha1"
+)
+
+const (
+	// scheduledTimeAnnotation holds the time a run was scheduled for
+	scheduledTimeAnnotation = "microsim.isala.me/scheduled-at"
+	// runOwnerKey indexes the load generators by the scheduled load generator that created them
+	runOwnerKey = ".metadata.controller"
+	// maxMissedRuns is how many missed runs are counted before giving up on the schedule, it guards
+	// against a schedule that was suspended or unreachable for a long time
+	maxMissedRuns = 100
+)
+
+// ScheduledLoadGeneratorReconciler reconciles a ScheduledLoadGenerator object
+type ScheduledLoadGeneratorReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+//+kubebuilder:rbac:groups=microsim.isala.me,resources=scheduledloadgenerators,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=microsim.isala.me,resources=scheduledloadgenerators/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=microsim.isala.me,resources=scheduledloadgenerators/finalizers,verbs=update
+//+kubebuilder:rbac:groups=microsim.isala.me,resources=loadgenerators,verbs=get;list;watch;create;update;patch;delete
+
+func (r *ScheduledLoadGeneratorReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	logger.Info("Reconciling")
+
+	var scheduled microsimv1alpha1.ScheduledLoadGenerator
+	if err := r.Get(ctx, req.NamespacedName, &scheduled); err != nil {
+		// The runs are owned by the scheduled load generator and removed with it
+		return ctrl.Result{Requeue: false}, client.IgnoreNotFound(err)
+	}
+
+	var runList microsimv1alpha1.LoadGeneratorList
+	if err := r.List(ctx, &runList, client.InNamespace(req.Namespace), client.MatchingFields{runOwnerKey: req.Name}); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// Sort the runs by how they ended
+	var active, successful, failed []*microsimv1alpha1.LoadGenerator
+	var lastScheduleTime *time.Time
+	for i := range runList.Items {
+		run := &runList.Items[i]
+		switch runOutcome(run) {
+		case "":
+			active = append(active, run)
+		case microsimv1alpha1.ReasonCompleted, microsimv1alpha1.ReasonTimeout:
+			// The timeout is how runs without a request count or a profile end
+			successful = append(successful, run)
+		default:
+			failed = append(failed, run)
+		}
+
+		scheduledAt, err := scheduledTime(run)
+		if err != nil {
+			logger.Error(err, "unable to parse schedule time of run", "run", run.Name)
+			continue
+		}
+		if lastScheduleTime == nil || scheduledAt.After(*lastScheduleTime) {
+			lastScheduleTime = &scheduledAt
+		}
+	}
+
+	var activeRefs []v1.ObjectReference
+	for _, run := range active {
+		runRef, err := ref.GetReference(r.Scheme, run)
+		if err != nil {
+			logger.Error(err, "unable to make reference to active run", "run", run.Name)
+			continue
+		}
+		activeRefs = append(activeRefs, *runRef)
+	}
+	update := func(status *microsimv1alpha1.ScheduledLoadGeneratorStatus) {
+		status.Active = activeRefs
+		if lastScheduleTime != nil {
+			status.LastScheduleTime = &metav1.Time{Time: *lastScheduleTime}
+		}
+		for _, run := range successful {
+			finished := meta.FindStatusCondition(run.Status.Conditions, microsimv1alpha1.ConditionFinished)
+			if status.LastSuccessfulTime == nil || finished.LastTransitionTime.After(status.LastSuccessfulTime.Time) {
+				lastSuccessfulTime := finished.LastTransitionTime
+				status.LastSuccessfulTime = &lastSuccessfulTime
+			}
+		}
+	}
+	update(&scheduled.Status)
+	if err := r.writeStatus(ctx, req.NamespacedName, update); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// Remove the runs past the history limits, the oldest go first
+	r.pruneRuns(ctx, successful, scheduled.Spec.SuccessfulRunsHistoryLimit)
+	r.pruneRuns(ctx, failed, scheduled.Spec.FailedRunsHistoryLimit)
+
+	if scheduled.Spec.Suspend {
+		logger.V(1).Info("scheduled load generator is suspended, skipping")
+		return ctrl.Result{Requeue: false}, nil
+	}
+
+	now := time.Now()
+	missedRun, nextRun, err := nextSchedule(&scheduled, now)
+	if err != nil {
+		// Invalid schedule won't get fixed by retrying, wait for the next update
+		logger.Error(err, "unable to figure out the schedule")
+		return ctrl.Result{Requeue: false}, nil
+	}
+	result := ctrl.Result{RequeueAfter: nextRun.Sub(now)}
+	logger = logger.WithValues("now", now, "nextRun", nextRun)
+
+	if missedRun.IsZero() {
+		logger.V(1).Info("no upcoming scheduled runs, sleeping until next")
+		return result, nil
+	}
+
+	logger = logger.WithValues("currentRun", missedRun)
+	if scheduled.Spec.StartingDeadlineSeconds != nil &&
+		missedRun.Add(time.Duration(*scheduled.Spec.StartingDeadlineSeconds)*time.Second).Before(now) {
+		logger.V(1).Info("missed starting deadline for last run, sleeping until next")
+		return result, nil
+	}
+
+	blocked, err := r.applyConcurrencyPolicy(ctx, scheduled.Spec.ConcurrencyPolicy, active)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if blocked {
+		logger.V(1).Info("concurrency policy blocks concurrent runs, skipping", "active", len(active))
+		return result, nil
+	}
+
+	run, err := r.runFor(&scheduled, missedRun)
+	if err != nil {
+		logger.Error(err, "unable to construct run from template")
+		return result, nil
+	}
+	if err := r.Create(ctx, run); IgnoreAlreadyExist(err) != nil {
+		logger.Error(err, fmt.Sprintf("failed to create load generator %s", run.Name))
+		return ctrl.Result{}, err
+	}
+	logger.V(1).Info("created load generator for run", "name", run.Name)
+	return result, nil
+}
+
+// writeStatus applies update on top of the latest version of the status, the patch fails on a
+// conflicting update in which case it's retried
+func (r *ScheduledLoadGeneratorReconciler) writeStatus(ctx context.Context, key types.NamespacedName, update func(*microsimv1alpha1.ScheduledLoadGeneratorStatus)) error {
+	err := retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		var scheduled microsimv1alpha1.ScheduledLoadGenerator
+		if err := r.Get(ctx, key, &scheduled); err != nil {
+			return err
+		}
+		patch := client.MergeFromWithOptions(scheduled.DeepCopy(), client.MergeFromWithOptimisticLock{})
+		update(&scheduled.Status)
+		return r.Status().Patch(ctx, &scheduled, patch)
+	})
+	return client.IgnoreNotFound(err)
+}
+
+// runOutcome returns the reason a run ended with, it's empty while the run is going. The reason of
+// failed assertions takes over the one of the Finished condition
+func runOutcome(run *microsimv1alpha1.LoadGenerator) string {
+	finished := meta.FindStatusCondition(run.Status.Conditions, microsimv1alpha1.ConditionFinished)
+	if finished == nil || finished.Status != metav1.ConditionTrue || finished.ObservedGeneration != run.Generation {
+		return ""
+	}
+	if passed := meta.FindStatusCondition(run.Status.Conditions, microsimv1alpha1.ConditionPassed); passed != nil && passed.Status == metav1.ConditionFalse {
+		return passed.Reason
+	}
+	return finished.Reason
+}
+
+func scheduledTime(run *microsimv1alpha1.LoadGenerator) (time.Time, error) {
+	value, ok := run.Annotations[scheduledTimeAnnotation]
+	if !ok {
+		return time.Time{}, fmt.Errorf("run has no %s annotation", scheduledTimeAnnotation)
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// applyConcurrencyPolicy makes room for a new run next to the active ones, blocked is true when the
+// policy doesn't let it start
+func (r *ScheduledLoadGeneratorReconciler) applyConcurrencyPolicy(ctx context.Context, policy microsimv1alpha1.ConcurrencyPolicy, active []*microsimv1alpha1.LoadGenerator) (bool, error) {
+	logger := log.FromContext(ctx)
+	switch policy {
+	case microsimv1alpha1.ForbidConcurrent, "":
+		return len(active) > 0, nil
+	case microsimv1alpha1.ReplaceConcurrent:
+		for _, run := range active {
+			if err := r.Delete(ctx, run, client.PropagationPolicy(metav1.DeletePropagationBackground)); client.IgnoreNotFound(err) != nil {
+				logger.Error(err, "unable to delete active run", "run", run.Name)
+				return false, err
+			}
+		}
+	}
+	return false, nil
+}
+
+// pruneRuns deletes the oldest runs until at most limit are left, nothing is deleted without a limit
+func (r *ScheduledLoadGeneratorReconciler) pruneRuns(ctx context.Context, runs []*microsimv1alpha1.LoadGenerator, limit *int32) {
+	logger := log.FromContext(ctx)
+	if limit == nil {
+		return
+	}
+
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].CreationTimestamp.Before(&runs[j].CreationTimestamp)
+	})
+	for i := 0; i < len(runs)-int(*limit); i++ {
+		if err := r.Delete(ctx, runs[i], client.PropagationPolicy(metav1.DeletePropagationBackground)); client.IgnoreNotFound(err) != nil {
+			logger.Error(err, "unable to delete old run", "run", runs[i].Name)
+			continue
+		}
+		logger.V(1).Info("deleted old run", "run", runs[i].Name)
+	}
+}
+
+// nextSchedule returns the latest run that was due but not started, zero when there's none, with the next run
+func nextSchedule(scheduled *microsimv1alpha1.ScheduledLoadGenerator, now time.Time) (time.Time, time.Time, error) {
+	schedule, err := cron.ParseStandard(scheduled.Spec.Schedule)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("unparseable schedule %q: %w", scheduled.Spec.Schedule, err)
+	}
+
+	// Start counting from the last run, or from when the scheduled load generator was created
+	earliest := scheduled.CreationTimestamp.Time
+	if scheduled.Status.LastScheduleTime != nil {
+		earliest = scheduled.Status.LastScheduleTime.Time
+	}
+	if scheduled.Spec.StartingDeadlineSeconds != nil {
+		// Runs older than the deadline won't be started anyway
+		deadline := now.Add(-time.Duration(*scheduled.Spec.StartingDeadlineSeconds) * time.Second)
+		if deadline.After(earliest) {
+			earliest = deadline
+		}
+	}
+	if earliest.After(now) {
+		return time.Time{}, schedule.Next(now), nil
+	}
+
+	var lastMissed time.Time
+	missed := 0
+	for t := schedule.Next(earliest); !t.After(now); t = schedule.Next(t) {
+		lastMissed = t
+		missed++
+		if missed > maxMissedRuns {
+			return time.Time{}, time.Time{}, fmt.Errorf("too many missed runs (> %d), set or decrease startingDeadlineSeconds", maxMissedRuns)
+		}
+	}
+	return lastMissed, schedule.Next(now), nil
+}
+
+// runFor creates the load generator of the run scheduled at scheduledAt, the name is unique to the
+// scheduled time so a run is never created twice
+func (r *ScheduledLoadGeneratorReconciler) runFor(scheduled *microsimv1alpha1.ScheduledLoadGenerator, scheduledAt time.Time) (*microsimv1alpha1.LoadGenerator, error) {
+	name := fmt.Sprintf("%s-%d", scheduled.Name, scheduledAt.Unix()/60)
+	run := &microsimv1alpha1.LoadGenerator{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: scheduled.Namespace,
+			Labels: map[string]string{
+				"app.kubernetes.io/instance":   name,
+				"app.kubernetes.io/part-of":    scheduled.Name,
+				"app.kubernetes.io/managed-by": "microsim-scheduledloadgenerator",
+				"app.kubernetes.io/created-by": "microsim",
+			},
+			Annotations: map[string]string{
+				scheduledTimeAnnotation: scheduledAt.Format(time.RFC3339),
+			},
+		},
+		Spec: *scheduled.Spec.Template.DeepCopy(),
+	}
+	if err := controllerutil.SetControllerReference(scheduled, run, r.Scheme); err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ScheduledLoadGeneratorReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &microsimv1alpha1.LoadGenerator{}, runOwnerKey, func(object client.Object) []string {
+		owner := metav1.GetControllerOf(object)
+		if owner == nil || owner.APIVersion != microsimv1alpha1.GroupVersion.String() || owner.Kind != "ScheduledLoadGenerator" {
+			return nil
+		}
+		return []string{owner.Name}
+	}); err != nil {
+		return err
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&microsimv1alpha1.ScheduledLoadGenerator{}).
+		// Runs finishing free up the schedule for the next one under the Forbid policy
+		Owns(&microsimv1alpha1.LoadGenerator{}).
+		Complete(r)
+}
@@ -0,0 +1,214 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	microsimv1alpha1 "github.com/MrSupiri/MicroSim/api/v1alpha1"
+)
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
+
+func TestNextSchedule(t *testing.T) {
+	created := time.Date(2021, 6, 1, 10, 0, 0, 0, time.UTC)
+	at := func(hour, minute int) time.Time {
+		return time.Date(2021, 6, 1, hour, minute, 0, 0, time.UTC)
+	}
+	seconds := func(s int64) *int64 { return &s }
+	tests := []struct {
+		name             string
+		schedule         string
+		lastScheduleTime *time.Time
+		deadline         *int64
+		now              time.Time
+		missed           time.Time
+		next             time.Time
+		err              string
+	}{
+		{name: "nothing due", schedule: "30 * * * *", now: at(10, 20), next: at(10, 30)},
+		{name: "due", schedule: "30 * * * *", now: at(10, 31), missed: at(10, 30), next: at(11, 30)},
+		{name: "latest of several missed", schedule: "0 * * * *", now: at(13, 15), missed: at(13, 0), next: at(14, 0)},
+		{name: "already started", schedule: "0 * * * *", lastScheduleTime: timePtr(at(13, 0)), now: at(13, 15), next: at(14, 0)},
+		{name: "past the starting deadline", schedule: "0 * * * *", deadline: seconds(600), now: at(13, 15), next: at(14, 0)},
+		{name: "within the starting deadline", schedule: "0 * * * *", deadline: seconds(1200), now: at(13, 15), missed: at(13, 0), next: at(14, 0)},
+		{name: "100 missed runs", schedule: "* * * * *", now: created.Add(100 * time.Minute), missed: created.Add(100 * time.Minute), next: created.Add(101 * time.Minute)},
+		{name: "too many missed runs", schedule: "* * * * *", now: created.Add(101 * time.Minute), err: "too many missed runs"},
+		{name: "deadline bounds the missed runs", schedule: "* * * * *", deadline: seconds(60), now: created.Add(time.Hour * 24), missed: created.Add(time.Hour * 24), next: created.Add(time.Hour*24 + time.Minute)},
+		{name: "invalid schedule", schedule: "every minute", now: at(10, 0), err: "unparseable schedule"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scheduled := &microsimv1alpha1.ScheduledLoadGenerator{
+				ObjectMeta: metav1.ObjectMeta{CreationTimestamp: metav1.NewTime(created)},
+				Spec:       microsimv1alpha1.ScheduledLoadGeneratorSpec{Schedule: tt.schedule, StartingDeadlineSeconds: tt.deadline},
+			}
+			if tt.lastScheduleTime != nil {
+				scheduled.Status.LastScheduleTime = &metav1.Time{Time: *tt.lastScheduleTime}
+			}
+			missed, next, err := nextSchedule(scheduled, tt.now)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("nextSchedule() error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("nextSchedule() error = %v", err)
+			}
+			if !missed.Equal(tt.missed) || !next.Equal(tt.next) {
+				t.Errorf("nextSchedule() = %v, %v, want %v, %v", missed, next, tt.missed, tt.next)
+			}
+		})
+	}
+}
+
+func TestRunOutcome(t *testing.T) {
+	condition := func(conditionType string, status metav1.ConditionStatus, reason string, generation int64) metav1.Condition {
+		return metav1.Condition{Type: conditionType, Status: status, Reason: reason, ObservedGeneration: generation}
+	}
+	tests := []struct {
+		name       string
+		conditions []metav1.Condition
+		want       string
+	}{
+		{name: "not started"},
+		{name: "running", conditions: []metav1.Condition{condition(microsimv1alpha1.ConditionFinished, metav1.ConditionFalse, microsimv1alpha1.ReasonRunning, 2)}},
+		{name: "finished an older generation", conditions: []metav1.Condition{condition(microsimv1alpha1.ConditionFinished, metav1.ConditionTrue, microsimv1alpha1.ReasonCompleted, 1)}},
+		{
+			name:       "completed",
+			conditions: []metav1.Condition{condition(microsimv1alpha1.ConditionFinished, metav1.ConditionTrue, microsimv1alpha1.ReasonCompleted, 2)},
+			want:       microsimv1alpha1.ReasonCompleted,
+		},
+		{
+			name: "completed with passing assertions",
+			conditions: []metav1.Condition{
+				condition(microsimv1alpha1.ConditionFinished, metav1.ConditionTrue, microsimv1alpha1.ReasonCompleted, 2),
+				condition(microsimv1alpha1.ConditionPassed, metav1.ConditionTrue, microsimv1alpha1.ReasonAssertionsPassed, 2),
+			},
+			want: microsimv1alpha1.ReasonCompleted,
+		},
+		{
+			name: "completed with failed assertions",
+			conditions: []metav1.Condition{
+				condition(microsimv1alpha1.ConditionFinished, metav1.ConditionTrue, microsimv1alpha1.ReasonCompleted, 2),
+				condition(microsimv1alpha1.ConditionPassed, metav1.ConditionFalse, microsimv1alpha1.ReasonAssertionsFailed, 2),
+			},
+			want: microsimv1alpha1.ReasonAssertionsFailed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			run := &microsimv1alpha1.LoadGenerator{ObjectMeta: metav1.ObjectMeta{Generation: 2}}
+			run.Status.Conditions = tt.conditions
+			if got := runOutcome(run); got != tt.want {
+				t.Errorf("runOutcome() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyConcurrencyPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  microsimv1alpha1.ConcurrencyPolicy
+		active  bool
+		blocked bool
+		deleted bool
+	}{
+		{name: "forbid by default", active: true, blocked: true},
+		{name: "forbid", policy: microsimv1alpha1.ForbidConcurrent, active: true, blocked: true},
+		{name: "forbid without active runs", policy: microsimv1alpha1.ForbidConcurrent},
+		{name: "allow", policy: microsimv1alpha1.AllowConcurrent, active: true},
+		{name: "replace", policy: microsimv1alpha1.ReplaceConcurrent, active: true, deleted: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			run := &microsimv1alpha1.LoadGenerator{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "nightly-1"}}
+			r := &ScheduledLoadGeneratorReconciler{Client: fake.NewClientBuilder().WithScheme(testScheme(t)).WithObjects(run).Build()}
+			var active []*microsimv1alpha1.LoadGenerator
+			if tt.active {
+				active = append(active, run)
+			}
+
+			blocked, err := r.applyConcurrencyPolicy(context.Background(), tt.policy, active)
+			if err != nil {
+				t.Fatalf("applyConcurrencyPolicy() error = %v", err)
+			}
+			if blocked != tt.blocked {
+				t.Errorf("blocked = %v, want %v", blocked, tt.blocked)
+			}
+			err = r.Get(context.Background(), client.ObjectKeyFromObject(run), &microsimv1alpha1.LoadGenerator{})
+			if deleted := apierrors.IsNotFound(err); deleted != tt.deleted {
+				t.Errorf("active run deleted = %v, want %v", deleted, tt.deleted)
+			}
+		})
+	}
+}
+
+func TestRunFor(t *testing.T) {
+	scheduled := &microsimv1alpha1.ScheduledLoadGenerator{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "nightly", UID: "0123456789"},
+		Spec: microsimv1alpha1.ScheduledLoadGeneratorSpec{
+			Template: microsimv1alpha1.LoadGeneratorSpec{Replicas: 2},
+		},
+	}
+	r := &ScheduledLoadGeneratorReconciler{Scheme: testScheme(t)}
+	scheduledAt := time.Date(2021, 6, 1, 10, 30, 0, 0, time.UTC)
+
+	run, err := r.runFor(scheduled, scheduledAt)
+	if err != nil {
+		t.Fatalf("runFor() error = %v", err)
+	}
+	if run.Namespace != "default" || run.Spec.Replicas != 2 {
+		t.Errorf("run = %s/%s with %d replicas, want the template in the namespace of the schedule", run.Namespace, run.Name, run.Spec.Replicas)
+	}
+	if got, err := scheduledTime(run); err != nil || !got.Equal(scheduledAt) {
+		t.Errorf("scheduledTime() = %v, %v, want %v", got, err, scheduledAt)
+	}
+	if owner := metav1.GetControllerOf(run); owner == nil || owner.UID != scheduled.UID {
+		t.Errorf("controller = %+v, want the scheduled load generator", owner)
+	}
+	// The name is the same for the same scheduled time so a run is only created once
+	again, _ := r.runFor(scheduled, scheduledAt)
+	later, _ := r.runFor(scheduled, scheduledAt.Add(time.Minute))
+	if again.Name != run.Name || later.Name == run.Name {
+		t.Errorf("names = %s, %s, %s, want the first two equal and the third different", run.Name, again.Name, later.Name)
+	}
+}
+
+func TestWriteScheduledStatus(t *testing.T) {
+	key := types.NamespacedName{Namespace: "default", Name: "nightly"}
+	scheduled := &microsimv1alpha1.ScheduledLoadGenerator{
+		ObjectMeta: metav1.ObjectMeta{Namespace: key.Namespace, Name: key.Name},
+		Spec:       microsimv1alpha1.ScheduledLoadGeneratorSpec{Schedule: "@hourly"},
+	}
+	r := &ScheduledLoadGeneratorReconciler{Client: fake.NewClientBuilder().WithScheme(testScheme(t)).WithObjects(scheduled).Build()}
+
+	lastScheduleTime := metav1.NewTime(time.Date(2021, 6, 1, 10, 0, 0, 0, time.UTC))
+	if err := r.writeStatus(context.Background(), key, func(status *microsimv1alpha1.ScheduledLoadGeneratorStatus) {
+		status.LastScheduleTime = &lastScheduleTime
+	}); err != nil {
+		t.Fatalf("writeStatus() error = %v", err)
+	}
+	var got microsimv1alpha1.ScheduledLoadGenerator
+	if err := r.Get(context.Background(), key, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Status.LastScheduleTime == nil || !got.Status.LastScheduleTime.Equal(&lastScheduleTime) || got.Spec.Schedule != "@hourly" {
+		t.Errorf("scheduled = %+v, want the last schedule time set and the spec untouched", got)
+	}
+
+	if err := r.writeStatus(context.Background(), types.NamespacedName{Namespace: "default", Name: "gone"}, func(*microsimv1alpha1.ScheduledLoadGeneratorStatus) {}); err != nil {
+		t.Errorf("writeStatus() of a deleted scheduled load generator error = %v, want nil", err)
+	}
+}
@@ -21,29 +21,45 @@ import (
 	"fmt"
 	microsimv1alpha1 "github.com/MrSupiri/MicroSim/api/v1alpha1"
 	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2beta2 "k8s.io/api/autoscaling/v2beta2"
 	v1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/log"
-	"strings"
+	"strconv"
+	"time"
 )
 
 // SimulationReconciler reconciles a Simulation object
 type SimulationReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
+	// ServiceRegistry is the image repository used for services that don't reference a ServiceFramework
+	ServiceRegistry string
 }
 
+// replicaRefreshInterval is how often the replica counts of autoscaled services are refreshed
+const replicaRefreshInterval = 30 * time.Second
+
+// suspendedReplicasAnnotation keeps the replica count of a Deployment while its simulation is suspended
+const suspendedReplicasAnnotation = "microsim.isala.me/suspended-replicas"
+
+// defaultFaults are the faults implemented by every service in the default registry
+var defaultFaults = []string{"latency", "memory-leak"}
+
 //+kubebuilder:rbac:groups=microsim.isala.me,resources=simulations,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=microsim.isala.me,resources=simulations/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=microsim.isala.me,resources=simulations/finalizers,verbs=update
+//+kubebuilder:rbac:groups=microsim.isala.me,resources=serviceframeworks,verbs=get;list;watch
 //+kubebuilder:rbac:groups="",resources=services,verbs=get;watch;list;create;delete
-//+kubebuilder:rbac:groups="apps",resources=deployments,verbs=get;watch;list;create;delete
+//+kubebuilder:rbac:groups="apps",resources=deployments,verbs=get;watch;list;create;update;patch;delete
+//+kubebuilder:rbac:groups="autoscaling",resources=horizontalpodautoscalers,verbs=get;watch;list;create;update;patch;delete
 //+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
@@ -98,37 +114,59 @@ func (r *SimulationReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 		return ctrl.Result{}, nil
 	}
 
-	if len(simulation.Status.Services) == 0 {
-		simulation.Status.Services = map[string]microsimv1alpha1.ServiceStatus{}
-	}
-
 	requeue := false
+	autoscaled := false
+	services := map[string]microsimv1alpha1.ServiceStatus{}
 	for name, service := range simulation.Spec.Services {
-		name = formatServiceName(name, simulation)
-		// Check if the service is already deployed
-		if _, ok := simulation.Status.Services[name]; ok {
-			continue
-		}
-
-		// Create the Deployment and Service if not created before
-		if err := r.ProvisionIfNoExists(ctx, name, service); err != nil {
+		name = simulation.ServiceName(name)
+		// The Deployments are reconciled on every pass so changes to the spec reach them
+		status, err := r.provisionService(ctx, name, service)
+		if err != nil {
 			// TODO: write the error here to CRD events
+			logger.Error(err, "failed to provision service", "service", name)
 			requeue = true
+			// Keep what was provisioned before until the service can be reconciled again
+			if previous, ok := simulation.Status.Services[name]; ok {
+				services[name] = previous
+			}
 			continue
 		}
+
+		if service.Autoscaling != nil {
+			autoscaled = true
+		}
+		if err := r.refreshReplicas(ctx, name, &status); err != nil {
+			logger.Error(err, "failed to get replicas of the service", "service", name)
+		}
 		// Update the status
-		simulation.Status.Services[name] = microsimv1alpha1.ServiceStatus{
-			Endpoint:  fmt.Sprintf("http://%s.%s.svc/", name, simulation.ObjectMeta.Namespace),
-			Language:  service.Language,
-			Framework: service.Framework,
+		services[name] = status
+	}
+	simulation.Status.Services = services
+
+	// Nothing is removed while a service failed to provision, its resources would be deleted with it
+	if !requeue {
+		if err := r.pruneResources(ctx, services); err != nil {
+			logger.Error(err, "failed to remove the resources of removed services")
+			requeue = true
 		}
 	}
 
+	if err := r.setSuspended(ctx, simulation.Spec.Suspend); err != nil {
+		logger.Error(err, "failed to change the suspended state of the simulation", "suspend", simulation.Spec.Suspend)
+		requeue = true
+	} else {
+		simulation.Status.Suspended = simulation.Spec.Suspend
+	}
+
 	// Write the status to etcd
 	if err := r.Status().Update(ctx, &simulation); err != nil {
 		logger.Error(err, "failed to update simulation status")
 		return ctrl.Result{Requeue: true}, err
 	}
+	if autoscaled && !requeue {
+		// Autoscaler changes don't trigger a reconcile, poll them to keep the replica counts fresh
+		return ctrl.Result{RequeueAfter: replicaRefreshInterval}, nil
+	}
 	return ctrl.Result{Requeue: requeue}, nil
 }
 
@@ -140,56 +178,359 @@ func (r *SimulationReconciler) SetupWithManager(mgr ctrl.Manager) error {
 		Complete(r)
 }
 
-func (r *SimulationReconciler) ProvisionIfNoExists(ctx context.Context, name string, service microsimv1alpha1.ServiceSpec) error {
-	logger := log.FromContext(ctx)
+// resolveFramework returns the ServiceFramework referenced by the service, services without a
+// reference get one built from the default registry using their language and framework
+func (r *SimulationReconciler) resolveFramework(ctx context.Context, service microsimv1alpha1.ServiceSpec) (microsimv1alpha1.ServiceFramework, error) {
+	var framework microsimv1alpha1.ServiceFramework
+	if service.FrameworkRef != "" {
+		if err := r.Get(ctx, types.NamespacedName{Name: service.FrameworkRef}, &framework); err != nil {
+			return framework, err
+		}
+		if framework.Spec.Port == 0 {
+			framework.Spec.Port = 8080
+		}
+		return framework, nil
+	}
+
+	if service.Language == "" || service.Framework == "" {
+		return framework, fmt.Errorf("service must either set frameworkRef or both language and framework")
+	}
+	framework.ObjectMeta.Name = service.Framework
+	framework.Spec = microsimv1alpha1.ServiceFrameworkSpec{
+		Language:        service.Language,
+		Image:           fmt.Sprintf("%s:%s-%s", r.ServiceRegistry, service.Language, service.Framework),
+		Port:            8080,
+		SupportedFaults: defaultFaults,
+	}
+	return framework, nil
+}
+
+// provisionService deploys the service, or each of its versions behind a shared Service when
+// versions are declared, and returns the status of the service
+func (r *SimulationReconciler) provisionService(ctx context.Context, name string, service microsimv1alpha1.ServiceSpec) (microsimv1alpha1.ServiceStatus, error) {
 	simulation := ctx.Value("simulation").(microsimv1alpha1.Simulation)
 
-	labels := map[string]string{
-		"app.kubernetes.io/instance":   name,
-		"app.kubernetes.io/part-of":    simulation.ObjectMeta.Name,
-		"app.kubernetes.io/managed-by": "microsim-simulation",
-		"app.kubernetes.io/created-by": "microsim",
+	framework, err := r.resolveFramework(ctx, service)
+	if err != nil {
+		return microsimv1alpha1.ServiceStatus{}, err
+	}
+	status := microsimv1alpha1.ServiceStatus{
+		Endpoint:        serviceEndpoint(name, simulation),
+		Language:        framework.Spec.Language,
+		Framework:       framework.Name,
+		Image:           framework.Spec.Image,
+		SupportedFaults: framework.Spec.SupportedFaults,
+	}
+
+	labels := serviceLabels(name, simulation)
+	if len(service.Versions) == 0 {
+		if err := r.provisionDeployment(ctx, name, labels, framework, service); err != nil {
+			return status, err
+		}
+		return status, nil
+	}
+
+	// The shared Service selects the pods of every version
+	if err := r.provisionClusterIP(ctx, name, labels); err != nil {
+		return status, err
 	}
 
+	status.Versions = map[string]microsimv1alpha1.ServiceVersionStatus{}
+	for _, version := range service.Versions {
+		// A version inherits the framework of the service unless it picks its own
+		versionSpec := microsimv1alpha1.ServiceSpec{
+			FrameworkRef: service.FrameworkRef,
+			Language:     service.Language,
+			Framework:    service.Framework,
+		}
+		if version.FrameworkRef != "" || version.Framework != "" {
+			versionSpec = microsimv1alpha1.ServiceSpec{
+				FrameworkRef: version.FrameworkRef,
+				Language:     firstNonEmpty(version.Language, service.Language),
+				Framework:    version.Framework,
+			}
+		}
+		versionFramework, err := r.resolveFramework(ctx, versionSpec)
+		if err != nil {
+			return status, err
+		}
+		if version.Image != "" {
+			versionFramework.Spec.Image = version.Image
+		}
+
+		versionName := fmt.Sprintf("%s-%s", name, version.Name)
+		versionLabels := serviceLabels(name, simulation)
+		versionLabels["app.kubernetes.io/version"] = version.Name
+		if err := r.provisionDeployment(ctx, versionName, versionLabels, versionFramework, service); err != nil {
+			return status, err
+		}
+
+		status.Versions[version.Name] = microsimv1alpha1.ServiceVersionStatus{
+			Endpoint:  serviceEndpoint(versionName, simulation),
+			Weight:    version.Weight,
+			Language:  versionFramework.Spec.Language,
+			Framework: versionFramework.Name,
+			Image:     versionFramework.Spec.Image,
+			Faults:    version.Faults,
+		}
+	}
+	return status, nil
+}
+
+// provisionDeployment creates or updates a Deployment and creates a Service with the given name which select
+// the pods by labels, along with a HorizontalPodAutoscaler while the service is autoscaled. The replica count
+// and annotations of an existing Deployment are left as they are
+func (r *SimulationReconciler) provisionDeployment(ctx context.Context, name string, labels map[string]string, framework microsimv1alpha1.ServiceFramework, service microsimv1alpha1.ServiceSpec) error {
+	logger := log.FromContext(ctx)
+	simulation := ctx.Value("simulation").(microsimv1alpha1.Simulation)
+
 	deployment := appsv1.Deployment{
-		TypeMeta: metav1.TypeMeta{
-			APIVersion: "apps/appsv1",
-			Kind:       "Deployment",
-		},
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      name,
 			Namespace: simulation.ObjectMeta.Namespace,
-			Labels:    labels,
 		},
-		Spec: appsv1.DeploymentSpec{
-			Selector: &metav1.LabelSelector{
+	}
+	result, err := controllerutil.CreateOrUpdate(ctx, r.Client, &deployment, func() error {
+		if deployment.Labels == nil {
+			deployment.Labels = map[string]string{}
+		}
+		for key, value := range labels {
+			deployment.Labels[key] = value
+		}
+		// The selector of a Deployment can't be changed once it's created
+		if deployment.ResourceVersion == "" {
+			deployment.Spec.Selector = &metav1.LabelSelector{
 				MatchLabels: labels,
+			}
+			// A Deployment added while the simulation is suspended starts out suspended
+			if simulation.Spec.Suspend {
+				deployment.Annotations = map[string]string{suspendedReplicasAnnotation: "1"}
+				deployment.Spec.Replicas = new(int32)
+			}
+		}
+		deployment.Spec.Template.ObjectMeta.Labels = labels
+
+		if len(deployment.Spec.Template.Spec.Containers) == 0 {
+			deployment.Spec.Template.Spec.Containers = []v1.Container{{}}
+		}
+		// Fields the API server defaults are kept so an unchanged spec doesn't update the Deployment
+		container := &deployment.Spec.Template.Spec.Containers[0]
+		container.Name = "service"
+		container.Image = framework.Spec.Image
+		container.ImagePullPolicy = v1.PullIfNotPresent
+		container.Env = append([]v1.EnvVar{
+			{
+				Name:  "SERVICE_NAME",
+				Value: name,
 			},
-			Template: v1.PodTemplateSpec{
-				ObjectMeta: metav1.ObjectMeta{
-					Labels: labels,
-				},
-				Spec: v1.PodSpec{
-					Containers: []v1.Container{{
-						Name:            "service",
-						Image:           fmt.Sprintf("ghcr.io/mrsupiri/microsim/service:%s-%s", service.Language, service.Framework),
-						ImagePullPolicy: v1.PullIfNotPresent,
-						Env: []v1.EnvVar{
-							{
-								Name:  "SERVICE_NAME",
-								Value: name,
-							},
-						},
-						Ports: []v1.ContainerPort{{
-							Name:          "http",
-							ContainerPort: 8080,
-							Protocol:      v1.ProtocolTCP,
-						}},
-					}},
+		}, framework.Spec.Env...)
+		container.Ports = []v1.ContainerPort{{
+			Name:          "http",
+			ContainerPort: framework.Spec.Port,
+			Protocol:      v1.ProtocolTCP,
+		}}
+		container.Resources = service.Resources
+		container.ReadinessProbe = framework.Spec.ReadinessProbe
+		container.LivenessProbe = framework.Spec.LivenessProbe
+		return nil
+	})
+	if err != nil {
+		logger.Error(err, fmt.Sprintf("failed to provision deployment %s", deployment.ObjectMeta.Name))
+		return err
+	}
+	if result != controllerutil.OperationResultNone {
+		logger.V(1).Info(fmt.Sprintf("%s deployment", result), "name", deployment.GetName(), "uuid", deployment.GetUID())
+	}
+
+	if service.Autoscaling != nil {
+		if err := r.provisionAutoscaler(ctx, name, labels, *service.Autoscaling); err != nil {
+			return err
+		}
+	} else if err := r.removeAutoscaler(ctx, name); err != nil {
+		return err
+	}
+
+	return r.provisionClusterIP(ctx, name, labels)
+}
+
+// provisionAutoscaler creates or updates the HorizontalPodAutoscaler of the Deployment with the given name, it's
+// owned by the simulation so the garbage collector removes it with the simulation
+func (r *SimulationReconciler) provisionAutoscaler(ctx context.Context, name string, labels map[string]string, autoscaling microsimv1alpha1.AutoscalingSpec) error {
+	logger := log.FromContext(ctx)
+	simulation := ctx.Value("simulation").(microsimv1alpha1.Simulation)
+
+	metrics := append([]autoscalingv2beta2.MetricSpec{}, autoscaling.Metrics...)
+	// The targets are added in a fixed order so an unchanged spec doesn't update the autoscaler
+	for _, target := range []struct {
+		name        v1.ResourceName
+		utilization *int32
+	}{
+		{name: v1.ResourceCPU, utilization: autoscaling.TargetCPUUtilization},
+		{name: v1.ResourceMemory, utilization: autoscaling.TargetMemoryUtilization},
+	} {
+		if target.utilization == nil {
+			continue
+		}
+		metrics = append(metrics, autoscalingv2beta2.MetricSpec{
+			Type: autoscalingv2beta2.ResourceMetricSourceType,
+			Resource: &autoscalingv2beta2.ResourceMetricSource{
+				Name: target.name,
+				Target: autoscalingv2beta2.MetricTarget{
+					Type:               autoscalingv2beta2.UtilizationMetricType,
+					AverageUtilization: target.utilization,
 				},
 			},
+		})
+	}
+
+	autoscaler := autoscalingv2beta2.HorizontalPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: simulation.ObjectMeta.Namespace,
 		},
 	}
+	result, err := controllerutil.CreateOrUpdate(ctx, r.Client, &autoscaler, func() error {
+		autoscaler.Labels = labels
+		autoscaler.Spec = autoscalingv2beta2.HorizontalPodAutoscalerSpec{
+			ScaleTargetRef: autoscalingv2beta2.CrossVersionObjectReference{
+				APIVersion: "apps/v1",
+				Kind:       "Deployment",
+				Name:       name,
+			},
+			MinReplicas: autoscaling.MinReplicas,
+			MaxReplicas: autoscaling.MaxReplicas,
+			Metrics:     metrics,
+			Behavior:    autoscaling.Behavior,
+		}
+		return controllerutil.SetControllerReference(&simulation, &autoscaler, r.Scheme)
+	})
+	if err != nil {
+		logger.Error(err, fmt.Sprintf("failed to provision horizontal pod autoscaler %s", autoscaler.ObjectMeta.Name))
+		return err
+	}
+	if result != controllerutil.OperationResultNone {
+		logger.V(1).Info(fmt.Sprintf("%s horizontal pod autoscaler", result), "name", autoscaler.GetName(), "uuid", autoscaler.GetUID())
+	}
+	return nil
+}
+
+// removeAutoscaler deletes the HorizontalPodAutoscaler of the Deployment with the given name, the Deployment
+// keeps the replica count the autoscaler last set
+func (r *SimulationReconciler) removeAutoscaler(ctx context.Context, name string) error {
+	logger := log.FromContext(ctx)
+	simulation := ctx.Value("simulation").(microsimv1alpha1.Simulation)
+
+	var autoscaler autoscalingv2beta2.HorizontalPodAutoscaler
+	key := types.NamespacedName{Namespace: simulation.ObjectMeta.Namespace, Name: name}
+	if err := r.Get(ctx, key, &autoscaler); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	if err := r.Delete(ctx, &autoscaler); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	logger.V(1).Info("removed horizontal pod autoscaler", "name", name)
+	return nil
+}
+
+// setSuspended scales the Deployments of the simulation to zero, keeping their replica counts in an
+// annotation, or restores the replica counts from the annotation when suspend is false
+func (r *SimulationReconciler) setSuspended(ctx context.Context, suspend bool) error {
+	logger := log.FromContext(ctx)
+	simulation := ctx.Value("simulation").(microsimv1alpha1.Simulation)
+
+	var deploymentList appsv1.DeploymentList
+	if err := r.List(ctx, &deploymentList, client.InNamespace(simulation.ObjectMeta.Namespace),
+		client.MatchingLabels{"app.kubernetes.io/part-of": simulation.ObjectMeta.Name}); err != nil {
+		return err
+	}
+
+	for _, deployment := range deploymentList.Items {
+		suspendedReplicas, suspended := deployment.Annotations[suspendedReplicasAnnotation]
+		if suspended == suspend {
+			continue
+		}
+
+		if suspend {
+			replicas := int32(1)
+			if deployment.Spec.Replicas != nil {
+				replicas = *deployment.Spec.Replicas
+			}
+			if deployment.Annotations == nil {
+				deployment.Annotations = map[string]string{}
+			}
+			deployment.Annotations[suspendedReplicasAnnotation] = strconv.Itoa(int(replicas))
+			deployment.Spec.Replicas = new(int32)
+		} else {
+			replicas, err := strconv.Atoi(suspendedReplicas)
+			if err != nil {
+				return fmt.Errorf("invalid %s annotation on deployment %s: %w", suspendedReplicasAnnotation, deployment.GetName(), err)
+			}
+			restored := int32(replicas)
+			deployment.Spec.Replicas = &restored
+			delete(deployment.Annotations, suspendedReplicasAnnotation)
+		}
+
+		if err := r.Update(ctx, &deployment); err != nil {
+			return err
+		}
+		logger.V(1).Info("changed suspended state of deployment", "name", deployment.GetName(), "suspend", suspend, "replicas", *deployment.Spec.Replicas)
+	}
+	return nil
+}
+
+// replicas returns the running and desired number of pods of the Deployment with the given name,
+// the desired count comes from the autoscaler when there is one
+func (r *SimulationReconciler) replicas(ctx context.Context, name string) (int32, int32, error) {
+	simulation := ctx.Value("simulation").(microsimv1alpha1.Simulation)
+	key := types.NamespacedName{Namespace: simulation.ObjectMeta.Namespace, Name: name}
+
+	var deployment appsv1.Deployment
+	if err := r.Get(ctx, key, &deployment); err != nil {
+		// The cache might not have seen a Deployment that was just created
+		return 0, 0, client.IgnoreNotFound(err)
+	}
+	desired := int32(1)
+	if deployment.Spec.Replicas != nil {
+		desired = *deployment.Spec.Replicas
+	}
+
+	var autoscaler autoscalingv2beta2.HorizontalPodAutoscaler
+	if err := r.Get(ctx, key, &autoscaler); client.IgnoreNotFound(err) != nil {
+		return 0, 0, err
+	} else if err == nil {
+		desired = autoscaler.Status.DesiredReplicas
+	}
+	return deployment.Status.ReadyReplicas, desired, nil
+}
+
+// refreshReplicas updates the replica counts of the service and its versions
+func (r *SimulationReconciler) refreshReplicas(ctx context.Context, name string, status *microsimv1alpha1.ServiceStatus) error {
+	if len(status.Versions) == 0 {
+		replicas, desired, err := r.replicas(ctx, name)
+		if err != nil {
+			return err
+		}
+		status.Replicas, status.DesiredReplicas = replicas, desired
+		return nil
+	}
+
+	status.Replicas, status.DesiredReplicas = 0, 0
+	for versionName, version := range status.Versions {
+		replicas, desired, err := r.replicas(ctx, fmt.Sprintf("%s-%s", name, versionName))
+		if err != nil {
+			return err
+		}
+		version.Replicas, version.DesiredReplicas = replicas, desired
+		status.Versions[versionName] = version
+		status.Replicas += replicas
+		status.DesiredReplicas += desired
+	}
+	return nil
+}
+
+func (r *SimulationReconciler) provisionClusterIP(ctx context.Context, name string, labels map[string]string) error {
+	logger := log.FromContext(ctx)
+	simulation := ctx.Value("simulation").(microsimv1alpha1.Simulation)
 
 	clusterIP := v1.Service{
 		TypeMeta: metav1.TypeMeta{
@@ -213,13 +554,6 @@ func (r *SimulationReconciler) ProvisionIfNoExists(ctx context.Context, name str
 		},
 	}
 
-	if err := r.Create(ctx, &deployment); IgnoreAlreadyExist(err) != nil {
-		logger.Error(err, fmt.Sprintf("failed to create deployment %s", deployment.ObjectMeta.Name))
-		return err
-	} else {
-		logger.V(1).Info("created deployment", "name", deployment.GetName(), "uuid", deployment.GetUID())
-	}
-
 	if err := r.Create(ctx, &clusterIP); IgnoreAlreadyExist(err) != nil {
 		logger.Error(err, fmt.Sprintf("failed to create service %s", clusterIP.ObjectMeta.Name))
 		return err
@@ -263,6 +597,73 @@ func (r *SimulationReconciler) CleanUpResources(ctx context.Context, name string
 	return err
 }
 
+// pruneResources deletes the Deployments, autoscalers and Services of the simulation that don't belong to any of the
+// provisioned services, they're left behind when a service or one of its versions is removed from the spec
+func (r *SimulationReconciler) pruneResources(ctx context.Context, services map[string]microsimv1alpha1.ServiceStatus) error {
+	logger := log.FromContext(ctx)
+	simulation := ctx.Value("simulation").(microsimv1alpha1.Simulation)
+
+	deployments, clusterIPs := map[string]bool{}, map[string]bool{}
+	for name, status := range services {
+		clusterIPs[name] = true
+		if len(status.Versions) == 0 {
+			deployments[name] = true
+		}
+		for versionName := range status.Versions {
+			versionName = fmt.Sprintf("%s-%s", name, versionName)
+			deployments[versionName] = true
+			clusterIPs[versionName] = true
+		}
+	}
+
+	listOptions := []client.ListOption{
+		client.InNamespace(simulation.ObjectMeta.Namespace),
+		client.MatchingLabels{"app.kubernetes.io/part-of": simulation.ObjectMeta.Name},
+	}
+	var deploymentList appsv1.DeploymentList
+	if err := r.List(ctx, &deploymentList, listOptions...); err != nil {
+		return err
+	}
+	for _, resource := range deploymentList.Items {
+		if deployments[resource.GetName()] {
+			continue
+		}
+		if err := r.Delete(ctx, &resource); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+		logger.V(1).Info("removed deployment", "name", resource.GetName(), "uuid", resource.GetUID())
+	}
+
+	var autoscalerList autoscalingv2beta2.HorizontalPodAutoscalerList
+	if err := r.List(ctx, &autoscalerList, listOptions...); err != nil {
+		return err
+	}
+	for _, resource := range autoscalerList.Items {
+		if deployments[resource.GetName()] {
+			continue
+		}
+		if err := r.Delete(ctx, &resource); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+		logger.V(1).Info("removed horizontal pod autoscaler", "name", resource.GetName(), "uuid", resource.GetUID())
+	}
+
+	var serviceList v1.ServiceList
+	if err := r.List(ctx, &serviceList, listOptions...); err != nil {
+		return err
+	}
+	for _, resource := range serviceList.Items {
+		if clusterIPs[resource.GetName()] {
+			continue
+		}
+		if err := r.Delete(ctx, &resource); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+		logger.V(1).Info("removed service", "name", resource.GetName(), "uuid", resource.GetUID())
+	}
+	return nil
+}
+
 func IgnoreAlreadyExist(err error) error {
 	if apierrors.IsAlreadyExists(err) {
 		return nil
@@ -270,8 +671,17 @@ func IgnoreAlreadyExist(err error) error {
 	return err
 }
 
-func formatServiceName(name string, simulation microsimv1alpha1.Simulation) string {
-	return fmt.Sprintf("%s-%s", strings.Replace(name, "_", "-", -1), simulation.ObjectMeta.UID[:8])
+func serviceLabels(name string, simulation microsimv1alpha1.Simulation) map[string]string {
+	return map[string]string{
+		"app.kubernetes.io/instance":   name,
+		"app.kubernetes.io/part-of":    simulation.ObjectMeta.Name,
+		"app.kubernetes.io/managed-by": "microsim-simulation",
+		"app.kubernetes.io/created-by": "microsim",
+	}
+}
+
+func serviceEndpoint(name string, simulation microsimv1alpha1.Simulation) string {
+	return fmt.Sprintf("http://%s.%s.svc/", name, simulation.ObjectMeta.Namespace)
 }
 
 func containsString(slice []string, s string) bool {
@@ -282,3 +692,12 @@ func containsString(slice []string, s string) bool {
 	}
 	return false
 }
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
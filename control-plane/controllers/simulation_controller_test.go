@@ -0,0 +1,446 @@
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2beta2 "k8s.io/api/autoscaling/v2beta2"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	microsimv1alpha1 "github.com/MrSupiri/MicroSim/api/v1alpha1"
+)
+
+func testScheme(t *testing.T) *runtime.Scheme {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := microsimv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	return scheme
+}
+
+func TestResolveFramework(t *testing.T) {
+	r := &SimulationReconciler{
+		Client: fake.NewClientBuilder().WithScheme(testScheme(t)).WithObjects(
+			&microsimv1alpha1.ServiceFramework{
+				ObjectMeta: metav1.ObjectMeta{Name: "express"},
+				Spec:       microsimv1alpha1.ServiceFrameworkSpec{Language: "javascript", Image: "example.com/express:1", Port: 3000, SupportedFaults: []string{"latency"}},
+			},
+			&microsimv1alpha1.ServiceFramework{
+				ObjectMeta: metav1.ObjectMeta{Name: "flask"},
+				Spec:       microsimv1alpha1.ServiceFrameworkSpec{Language: "python", Image: "example.com/flask:1"},
+			},
+		).Build(),
+		ServiceRegistry: "example.com/service",
+	}
+	tests := []struct {
+		name    string
+		service microsimv1alpha1.ServiceSpec
+		want    microsimv1alpha1.ServiceFrameworkSpec
+		wantErr bool
+	}{
+		{
+			name:    "reference",
+			service: microsimv1alpha1.ServiceSpec{FrameworkRef: "express"},
+			want:    microsimv1alpha1.ServiceFrameworkSpec{Language: "javascript", Image: "example.com/express:1", Port: 3000, SupportedFaults: []string{"latency"}},
+		},
+		{
+			name:    "reference without a port",
+			service: microsimv1alpha1.ServiceSpec{FrameworkRef: "flask"},
+			want:    microsimv1alpha1.ServiceFrameworkSpec{Language: "python", Image: "example.com/flask:1", Port: 8080},
+		},
+		{
+			name:    "reference over language and framework",
+			service: microsimv1alpha1.ServiceSpec{FrameworkRef: "flask", Language: "go", Framework: "gin"},
+			want:    microsimv1alpha1.ServiceFrameworkSpec{Language: "python", Image: "example.com/flask:1", Port: 8080},
+		},
+		{
+			name:    "default registry",
+			service: microsimv1alpha1.ServiceSpec{Language: "go", Framework: "gin"},
+			want:    microsimv1alpha1.ServiceFrameworkSpec{Language: "go", Image: "example.com/service:go-gin", Port: 8080, SupportedFaults: defaultFaults},
+		},
+		{
+			name:    "missing reference",
+			service: microsimv1alpha1.ServiceSpec{FrameworkRef: "spring"},
+			wantErr: true,
+		},
+		{
+			name:    "language without framework",
+			service: microsimv1alpha1.ServiceSpec{Language: "go"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			framework, err := r.resolveFramework(context.Background(), tt.service)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolveFramework() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(framework.Spec, tt.want) {
+				t.Errorf("resolveFramework() = %+v, want %+v", framework.Spec, tt.want)
+			}
+		})
+	}
+}
+
+// reconcileSimulation reconciles the simulation until it's no longer requeued and returns it
+func reconcileSimulation(t *testing.T, r *SimulationReconciler, key types.NamespacedName) microsimv1alpha1.Simulation {
+	for i := 0; i < 5; i++ {
+		result, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+		if err != nil {
+			t.Fatalf("Reconcile() error = %v", err)
+		}
+		if !result.Requeue {
+			break
+		}
+	}
+	var simulation microsimv1alpha1.Simulation
+	if err := r.Get(context.Background(), key, &simulation); err != nil {
+		t.Fatal(err)
+	}
+	return simulation
+}
+
+// names returns the sorted names of the Deployments and Services of the namespace
+func names(t *testing.T, c client.Client) ([]string, []string) {
+	var deployments appsv1.DeploymentList
+	var services v1.ServiceList
+	if err := c.List(context.Background(), &deployments); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.List(context.Background(), &services); err != nil {
+		t.Fatal(err)
+	}
+	var deploymentNames, serviceNames []string
+	for _, deployment := range deployments.Items {
+		deploymentNames = append(deploymentNames, deployment.Name)
+	}
+	for _, service := range services.Items {
+		serviceNames = append(serviceNames, service.Name)
+	}
+	sort.Strings(deploymentNames)
+	sort.Strings(serviceNames)
+	return deploymentNames, serviceNames
+}
+
+func TestReconcileServiceVersions(t *testing.T) {
+	key := types.NamespacedName{Namespace: "default", Name: "shop"}
+	simulation := &microsimv1alpha1.Simulation{
+		ObjectMeta: metav1.ObjectMeta{Namespace: key.Namespace, Name: key.Name, UID: "0123456789"},
+		Spec: microsimv1alpha1.SimulationSpec{Services: map[string]microsimv1alpha1.ServiceSpec{
+			"cart": {Language: "go", Framework: "gin"},
+		}},
+	}
+	r := &SimulationReconciler{
+		Client:          fake.NewClientBuilder().WithScheme(testScheme(t)).WithObjects(simulation).Build(),
+		ServiceRegistry: "example.com/service",
+	}
+
+	steps := []struct {
+		name        string
+		versions    []microsimv1alpha1.ServiceVersion
+		deployments []string
+		services    []string
+		images      map[string]string
+	}{
+		{
+			name:        "without versions",
+			deployments: []string{"cart-01234567"},
+			services:    []string{"cart-01234567"},
+			images:      map[string]string{"cart-01234567": "example.com/service:go-gin"},
+		},
+		{
+			name:        "versions added",
+			versions:    []microsimv1alpha1.ServiceVersion{{Name: "v1", Weight: 1}, {Name: "v2", Weight: 1, Image: "example.com/cart:2"}},
+			deployments: []string{"cart-01234567-v1", "cart-01234567-v2"},
+			services:    []string{"cart-01234567", "cart-01234567-v1", "cart-01234567-v2"},
+			images:      map[string]string{"cart-01234567-v1": "example.com/service:go-gin", "cart-01234567-v2": "example.com/cart:2"},
+		},
+		{
+			name:        "version image changed",
+			versions:    []microsimv1alpha1.ServiceVersion{{Name: "v1", Weight: 1}, {Name: "v2", Weight: 1, Image: "example.com/cart:3"}},
+			deployments: []string{"cart-01234567-v1", "cart-01234567-v2"},
+			services:    []string{"cart-01234567", "cart-01234567-v1", "cart-01234567-v2"},
+			images:      map[string]string{"cart-01234567-v2": "example.com/cart:3"},
+		},
+		{
+			name:        "version removed",
+			versions:    []microsimv1alpha1.ServiceVersion{{Name: "v2", Weight: 1, Image: "example.com/cart:3"}},
+			deployments: []string{"cart-01234567-v2"},
+			services:    []string{"cart-01234567", "cart-01234567-v2"},
+		},
+	}
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			var current microsimv1alpha1.Simulation
+			if err := r.Get(context.Background(), key, &current); err != nil {
+				t.Fatal(err)
+			}
+			cart := current.Spec.Services["cart"]
+			cart.Versions = step.versions
+			current.Spec.Services["cart"] = cart
+			if err := r.Update(context.Background(), &current); err != nil {
+				t.Fatal(err)
+			}
+
+			got := reconcileSimulation(t, r, key)
+			deployments, services := names(t, r.Client)
+			if !reflect.DeepEqual(deployments, step.deployments) || !reflect.DeepEqual(services, step.services) {
+				t.Errorf("got deployments %v and services %v, want %v and %v", deployments, services, step.deployments, step.services)
+			}
+			for name, image := range step.images {
+				var deployment appsv1.Deployment
+				if err := r.Get(context.Background(), types.NamespacedName{Namespace: key.Namespace, Name: name}, &deployment); err != nil {
+					t.Fatal(err)
+				}
+				if got := deployment.Spec.Template.Spec.Containers[0].Image; got != image {
+					t.Errorf("deployment %s runs %s, want %s", name, got, image)
+				}
+			}
+			if versions := got.Status.Services["cart-01234567"].Versions; len(versions) != len(step.versions) {
+				t.Errorf("status has %d versions, want %d", len(versions), len(step.versions))
+			}
+		})
+	}
+}
+
+func TestReconcileAutoscaling(t *testing.T) {
+	key := types.NamespacedName{Namespace: "default", Name: "shop"}
+	simulation := &microsimv1alpha1.Simulation{
+		ObjectMeta: metav1.ObjectMeta{Namespace: key.Namespace, Name: key.Name, UID: "0123456789"},
+		Spec: microsimv1alpha1.SimulationSpec{Services: map[string]microsimv1alpha1.ServiceSpec{
+			"cart": {Language: "go", Framework: "gin"},
+		}},
+	}
+	r := &SimulationReconciler{
+		Client:          fake.NewClientBuilder().WithScheme(testScheme(t)).WithObjects(simulation).Build(),
+		Scheme:          testScheme(t),
+		ServiceRegistry: "example.com/service",
+	}
+	cpu := int32(80)
+
+	steps := []struct {
+		name        string
+		autoscaling *microsimv1alpha1.AutoscalingSpec
+		versions    []microsimv1alpha1.ServiceVersion
+		want        map[string]int32
+	}{
+		{
+			name:        "autoscaled",
+			autoscaling: &microsimv1alpha1.AutoscalingSpec{MaxReplicas: 3, TargetCPUUtilization: &cpu},
+			want:        map[string]int32{"cart-01234567": 3},
+		},
+		{
+			name:        "max replicas changed",
+			autoscaling: &microsimv1alpha1.AutoscalingSpec{MaxReplicas: 5, TargetCPUUtilization: &cpu},
+			want:        map[string]int32{"cart-01234567": 5},
+		},
+		{
+			name:        "versions autoscaled separately",
+			autoscaling: &microsimv1alpha1.AutoscalingSpec{MaxReplicas: 5, TargetCPUUtilization: &cpu},
+			versions:    []microsimv1alpha1.ServiceVersion{{Name: "v1", Weight: 1}, {Name: "v2", Weight: 1}},
+			want:        map[string]int32{"cart-01234567-v1": 5, "cart-01234567-v2": 5},
+		},
+		{
+			name:     "autoscaling removed",
+			versions: []microsimv1alpha1.ServiceVersion{{Name: "v1", Weight: 1}, {Name: "v2", Weight: 1}},
+			want:     map[string]int32{},
+		},
+	}
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			var current microsimv1alpha1.Simulation
+			if err := r.Get(context.Background(), key, &current); err != nil {
+				t.Fatal(err)
+			}
+			cart := current.Spec.Services["cart"]
+			cart.Autoscaling = step.autoscaling
+			cart.Versions = step.versions
+			current.Spec.Services["cart"] = cart
+			if err := r.Update(context.Background(), &current); err != nil {
+				t.Fatal(err)
+			}
+			reconcileSimulation(t, r, key)
+
+			var autoscalers autoscalingv2beta2.HorizontalPodAutoscalerList
+			if err := r.List(context.Background(), &autoscalers); err != nil {
+				t.Fatal(err)
+			}
+			got := map[string]int32{}
+			for _, autoscaler := range autoscalers.Items {
+				got[autoscaler.Name] = autoscaler.Spec.MaxReplicas
+				if metrics := autoscaler.Spec.Metrics; len(metrics) != 1 || *metrics[0].Resource.Target.AverageUtilization != cpu {
+					t.Errorf("autoscaler %s has metrics %+v, want a CPU target of %d", autoscaler.Name, metrics, cpu)
+				}
+			}
+			if !reflect.DeepEqual(got, step.want) {
+				t.Errorf("got autoscalers %v, want %v", got, step.want)
+			}
+		})
+	}
+}
+
+func TestRefreshReplicas(t *testing.T) {
+	simulation := microsimv1alpha1.Simulation{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "shop"}}
+	deployment := func(name string, replicas, ready int32) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+			Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+			Status:     appsv1.DeploymentStatus{ReadyReplicas: ready},
+		}
+	}
+	r := &SimulationReconciler{Client: fake.NewClientBuilder().WithScheme(testScheme(t)).WithObjects(
+		deployment("cart", 2, 1),
+		deployment("payment-v1", 1, 1),
+		deployment("payment-v2", 3, 2),
+		&autoscalingv2beta2.HorizontalPodAutoscaler{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "payment-v2"},
+			Status:     autoscalingv2beta2.HorizontalPodAutoscalerStatus{DesiredReplicas: 4},
+		},
+	).Build()}
+	ctx := context.WithValue(context.Background(), "simulation", simulation)
+
+	tests := []struct {
+		name    string
+		status  microsimv1alpha1.ServiceStatus
+		ready   int32
+		desired int32
+	}{
+		{name: "deployment", status: microsimv1alpha1.ServiceStatus{}, ready: 1, desired: 2},
+		{
+			name: "versions are summed, the autoscaler decides",
+			status: microsimv1alpha1.ServiceStatus{Versions: map[string]microsimv1alpha1.ServiceVersionStatus{
+				"v1": {}, "v2": {},
+			}},
+			ready:   3,
+			desired: 5,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name := "cart"
+			if len(tt.status.Versions) > 0 {
+				name = "payment"
+			}
+			if err := r.refreshReplicas(ctx, name, &tt.status); err != nil {
+				t.Fatal(err)
+			}
+			if tt.status.Replicas != tt.ready || tt.status.DesiredReplicas != tt.desired {
+				t.Errorf("refreshReplicas() = %d of %d, want %d of %d", tt.status.Replicas, tt.status.DesiredReplicas, tt.ready, tt.desired)
+			}
+		})
+	}
+}
+
+func TestSetSuspended(t *testing.T) {
+	simulation := microsimv1alpha1.Simulation{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "shop"}}
+	deployment := func(name string, replicas *int32, annotations map[string]string) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:   "default",
+				Name:        name,
+				Labels:      map[string]string{"app.kubernetes.io/part-of": "shop"},
+				Annotations: annotations,
+			},
+			Spec: appsv1.DeploymentSpec{Replicas: replicas},
+		}
+	}
+	three := int32(3)
+
+	tests := []struct {
+		name       string
+		deployment *appsv1.Deployment
+		suspend    bool
+		replicas   int32
+		annotation string
+		annotated  bool
+		wantErr    bool
+	}{
+		{name: "suspend", deployment: deployment("cart", &three, nil), suspend: true, replicas: 0, annotation: "3", annotated: true},
+		{name: "suspend without replicas", deployment: deployment("cart", nil, nil), suspend: true, replicas: 0, annotation: "1", annotated: true},
+		{name: "already suspended", deployment: deployment("cart", new(int32), map[string]string{suspendedReplicasAnnotation: "3"}), suspend: true, replicas: 0, annotation: "3", annotated: true},
+		{name: "resume", deployment: deployment("cart", new(int32), map[string]string{suspendedReplicasAnnotation: "3"}), suspend: false, replicas: 3},
+		{name: "not suspended", deployment: deployment("cart", &three, nil), suspend: false, replicas: 3},
+		{name: "invalid annotation", deployment: deployment("cart", new(int32), map[string]string{suspendedReplicasAnnotation: "three"}), suspend: false, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &SimulationReconciler{Client: fake.NewClientBuilder().WithScheme(testScheme(t)).WithObjects(tt.deployment).Build()}
+			ctx := context.WithValue(context.Background(), "simulation", simulation)
+			err := r.setSuspended(ctx, tt.suspend)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("setSuspended() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			var got appsv1.Deployment
+			if err := r.Get(ctx, types.NamespacedName{Namespace: "default", Name: "cart"}, &got); err != nil {
+				t.Fatal(err)
+			}
+			annotation, annotated := got.Annotations[suspendedReplicasAnnotation]
+			if *got.Spec.Replicas != tt.replicas || annotated != tt.annotated || annotation != tt.annotation {
+				t.Errorf("setSuspended() left %d replicas and annotation %q (%v), want %d and %q (%v)",
+					*got.Spec.Replicas, annotation, annotated, tt.replicas, tt.annotation, tt.annotated)
+			}
+		})
+	}
+}
+
+func TestReconcileSuspended(t *testing.T) {
+	key := types.NamespacedName{Namespace: "default", Name: "shop"}
+	simulation := &microsimv1alpha1.Simulation{
+		ObjectMeta: metav1.ObjectMeta{Namespace: key.Namespace, Name: key.Name, UID: "0123456789"},
+		Spec: microsimv1alpha1.SimulationSpec{
+			Services: map[string]microsimv1alpha1.ServiceSpec{"cart": {Language: "go", Framework: "gin"}},
+			Suspend:  true,
+		},
+	}
+	r := &SimulationReconciler{
+		Client:          fake.NewClientBuilder().WithScheme(testScheme(t)).WithObjects(simulation).Build(),
+		ServiceRegistry: "example.com/service",
+	}
+	replicas := func(name string) (int32, string) {
+		var deployment appsv1.Deployment
+		if err := r.Get(context.Background(), types.NamespacedName{Namespace: key.Namespace, Name: name}, &deployment); err != nil {
+			t.Fatal(err)
+		}
+		return *deployment.Spec.Replicas, deployment.Annotations[suspendedReplicasAnnotation]
+	}
+
+	if got := reconcileSimulation(t, r, key); !got.Status.Suspended {
+		t.Errorf("status isn't suspended")
+	}
+	if got, annotation := replicas("cart-01234567"); got != 0 || annotation != "1" {
+		t.Errorf("deployment created while suspended has %d replicas and annotation %q, want 0 and 1", got, annotation)
+	}
+
+	// Scaled up while suspended, a reconcile must not reset the replica count
+	var current microsimv1alpha1.Simulation
+	if err := r.Get(context.Background(), key, &current); err != nil {
+		t.Fatal(err)
+	}
+	current.Spec.Suspend = false
+	if err := r.Update(context.Background(), &current); err != nil {
+		t.Fatal(err)
+	}
+	if got := reconcileSimulation(t, r, key); got.Status.Suspended {
+		t.Errorf("status is still suspended")
+	}
+	if got, annotation := replicas("cart-01234567"); got != 1 || annotation != "" {
+		t.Errorf("resumed deployment has %d replicas and annotation %q, want 1 and none", got, annotation)
+	}
+}
@@ -0,0 +1,100 @@
+package engine
+
+import (
+	"encoding/json"
+	"time"
+
+	microsimv1alpha1 "github.com/MrSupiri/MicroSim/api/v1alpha1"
+)
+
+// Call is the outcome of a single service call within a request tree
+type Call struct {
+	// Designation is the service as written in the route template, with the version that was
+	// picked when the service has versions, like service_1@v2
+	Designation string
+	// Skipped is set when the call was left out by the probability of the hop, it's not an error
+	Skipped bool
+	Errors  []string
+	// Duration is only set when the service reports it
+	Duration *time.Duration
+}
+
+// hop is the designation a route was sent to with the hops it was forwarded to or skipped, in the same
+// order as the routes of the template
+type hop struct {
+	designation string
+	routes      []hop
+	// skipped is set when the route was left out by its probability, it wasn't sent
+	skipped bool
+}
+
+// Decision is whether a hop of the request tree was called, hops under a skipped one aren't listed
+type Decision struct {
+	// Path is the designations from the first hop down to this one joined by /, like service_1/service_2@v2
+	Path   string
+	Called bool
+}
+
+// parseCalls walks the response tree along the hops that were sent, a request that failed before
+// the services could respond is reported as a failed call to the first hop
+func parseCalls(h hop, result Result) []Call {
+	var response microsimv1alpha1.Response
+	if len(result.Response) == 0 || json.Unmarshal(result.Response, &response) != nil {
+		call := Call{Designation: h.designation}
+		if result.Err != nil {
+			call.Errors = []string{result.Err.Error()}
+		} else {
+			call.Errors = []string{"invalid response"}
+		}
+		return []Call{call}
+	}
+	return appendCalls(nil, h, &response)
+}
+
+func appendCalls(calls []Call, h hop, response *microsimv1alpha1.Response) []Call {
+	call := Call{Designation: h.designation}
+	if response == nil {
+		// The parent reports why the call failed
+		call.Errors = []string{"no response"}
+		return append(calls, call)
+	}
+
+	call.Errors = response.Errors
+	if response.Duration != nil {
+		d := time.Duration(*response.Duration * float64(time.Millisecond))
+		call.Duration = &d
+	}
+	calls = append(calls, call)
+	// Skipped routes weren't sent so they have no response
+	i := 0
+	for _, child := range h.routes {
+		if child.skipped {
+			calls = append(calls, Call{Designation: child.designation, Skipped: true})
+			continue
+		}
+		var childResponse *microsimv1alpha1.Response
+		if i < len(response.Response) {
+			childResponse = response.Response[i]
+		}
+		i++
+		calls = appendCalls(calls, child, childResponse)
+	}
+	return calls
+}
+
+// decisions lists the hops of the request tree that were called and the ones that were skipped
+func decisions(h hop) []Decision {
+	return appendDecisions(nil, h, "")
+}
+
+func appendDecisions(list []Decision, h hop, parent string) []Decision {
+	path := h.designation
+	if parent != "" {
+		path = parent + "/" + path
+	}
+	list = append(list, Decision{Path: path, Called: !h.skipped})
+	for _, child := range h.routes {
+		list = appendDecisions(list, child, path)
+	}
+	return list
+}
@@ -0,0 +1,60 @@
+package engine
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseCalls(t *testing.T) {
+	sent := hop{designation: "front@v1", routes: []hop{
+		{designation: "cart", routes: []hop{{designation: "payment"}}},
+		{designation: "search"},
+	}}
+	tests := []struct {
+		name   string
+		result Result
+		want   []Call
+	}{
+		{
+			name: "full tree",
+			result: Result{Response: []byte(`{"service": "front", "errors": [], "duration": 12.5, "response": [
+				{"service": "cart", "errors": ["card declined"], "response": [{"service": "payment", "errors": ["card declined"]}]},
+				{"service": "search", "errors": []}
+			]}`)},
+			want: []Call{
+				{Designation: "front@v1", Errors: []string{}, Duration: durationPtr(12500 * time.Microsecond)},
+				{Designation: "cart", Errors: []string{"card declined"}},
+				{Designation: "payment", Errors: []string{"card declined"}},
+				{Designation: "search", Errors: []string{}},
+			},
+		},
+		{
+			name:   "missing responses",
+			result: Result{Response: []byte(`{"service": "front", "errors": ["cart is down"], "response": [null]}`)},
+			want: []Call{
+				{Designation: "front@v1", Errors: []string{"cart is down"}},
+				{Designation: "cart", Errors: []string{"no response"}},
+				{Designation: "search", Errors: []string{"no response"}},
+			},
+		},
+		{
+			name:   "request failed",
+			result: Result{Err: errors.New("connection refused")},
+			want:   []Call{{Designation: "front@v1", Errors: []string{"connection refused"}}},
+		},
+		{
+			name:   "invalid response",
+			result: Result{StatusCode: 200, Response: []byte("ok")},
+			want:   []Call{{Designation: "front@v1", Errors: []string{"invalid response"}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseCalls(sent, tt.result); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseCalls() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
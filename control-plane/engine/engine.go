@@ -0,0 +1,461 @@
+// Package engine generates load against a target, either at a scheduled rate (open model)
+// or with a fixed number of users sending requests back to back (closed model).
+package engine
+
+import (
+	"context"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	microsimv1alpha1 "github.com/MrSupiri/MicroSim/api/v1alpha1"
+)
+
+type Model string
+
+const (
+	// OpenModel starts requests on a fixed schedule no matter how long earlier requests take
+	OpenModel Model = "Open"
+	// ClosedModel runs a fixed number of users, each sending its next request once the previous one is done
+	ClosedModel Model = "Closed"
+)
+
+// scheduleStep is the resolution a changing rate is followed at, and how often the
+// number of users is adjusted to a changing profile
+const scheduleStep = 10 * time.Millisecond
+
+// Result is the outcome of a single request
+type Result struct {
+	// Template is the name of the route template that was sent
+	Template string
+	// Intended is when the request was scheduled to start, in the open model it's earlier
+	// than Sent when the engine fell behind
+	Intended time.Time
+	Sent     time.Time
+	// Latency is measured from Intended, so time spent waiting for a slow system to catch
+	// up is not left out of the results (coordinated omission)
+	Latency    time.Duration
+	StatusCode int
+	Err        error
+	Request    []byte
+	Response   []byte
+	// Calls are the service calls parsed from the response tree
+	Calls []Call
+	// Sequence is the number of the request in the run, with the seed it picks the routes of the request
+	Sequence uint64
+	// Decisions are the hops of the request tree that were called or skipped
+	Decisions []Decision
+	// Warmup is set on the requests that finished during the warmup, they aren't in the statistics of the run
+	Warmup bool
+	// Scenario and Step are set on the requests sent by a journey, Sequence is then the number of the journey
+	Scenario string
+	Step     int
+	// Route is the request tree that was sent after sampling, with the names of the services and the versions
+	// that were picked. Header holds the headers added to the request, sending both again replays the request
+	Route  microsimv1alpha1.Route
+	Header map[string]string
+	// Offset is when the request was scheduled to start from the start of the run
+	Offset time.Duration
+}
+
+// Sender sends a single request, timing fields of the result are filled by the engine
+type Sender func(ctx context.Context) Result
+
+// Journey is a sequence of requests sent by a virtual user one after another. Next sends the next request
+// and returns how long the user thinks before the one after it, ok is false once there are none left
+type Journey interface {
+	Next(ctx context.Context) (result Result, think time.Duration, ok bool)
+}
+
+type Config struct {
+	Model Model
+	// Rate is the number of requests started per second in the open model
+	Rate float64
+	// Concurrency is the number of users in the closed model
+	Concurrency int
+	// Profile changes the rate or the number of users over time, the run ends with the profile
+	Profile *Profile
+	// Limit is the number of requests to send before stopping, zero means no limit
+	Limit int
+	// MaxInFlight bounds the requests running at once in the open model, once it's reached new
+	// requests wait for a free slot while their latency keeps counting from the schedule
+	MaxInFlight int
+	// Shard and Shards split the load across engines, every engine sends its share of the rate or the
+	// users. Limit isn't split, it's the limit of this engine
+	Shard  int
+	Shards int
+	// Offset is how long the run was going before this engine started, a resumed run picks up the
+	// profile from there
+	Offset time.Duration
+	// ReplaySpeed scales the offsets of the replayed requests, 2 sends them twice as fast
+	ReplaySpeed float64
+}
+
+// share returns the part of level sent by the shard, users are whole so they are spread
+// over the shards such that the shares add up to the level
+func (c *Config) share(level float64) float64 {
+	if c.Shards <= 1 {
+		return level
+	}
+	if c.Model == ClosedModel {
+		users := int(math.Round(level))
+		return float64(users*(c.Shard+1)/c.Shards - users*c.Shard/c.Shards)
+	}
+	return level / float64(c.Shards)
+}
+
+type Engine struct {
+	Config
+	Send Sender
+	// NewJourney starts a journey in place of every request when it's set, the rate, the users and the
+	// limit then count journeys rather than requests
+	NewJourney func() Journey
+	// Replay is sent with SendRecorded when it's set, the requests go out at their offsets rather than
+	// following the load model
+	Replay       []Recorded
+	SendRecorded func(ctx context.Context, recorded Recorded) Result
+	// OnResult is called for every finished request, it can be called concurrently
+	OnResult func(Result)
+
+	stage int64
+	stats Stats
+	// warming is 1 while the results are recorded to warmup rather than stats
+	warming int32
+	warmup  Stats
+	// start is when the run would have started had it never stopped, in unix nanoseconds
+	start int64
+	// inFlight is the number of requests waiting for their response
+	inFlight int64
+}
+
+// Run sends requests until the context is cancelled, the limit is reached or the profile
+// is over and returns after all the requests in flight are finished
+func (e *Engine) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	atomic.StoreInt64(&e.start, time.Now().Add(-e.Offset).UnixNano())
+
+	if e.SendRecorded != nil {
+		e.runReplay(ctx, &wg)
+		return
+	}
+	if e.Model == ClosedModel {
+		e.runClosed(ctx, &wg)
+		return
+	}
+	e.runOpen(ctx, &wg)
+}
+
+// Restore continues the statistics of a resumed run from snapshot, it has to be called before Run
+func (e *Engine) Restore(snapshot Snapshot) {
+	e.stats.Restore(snapshot)
+}
+
+// InFlight returns the number of requests waiting for their response
+func (e *Engine) InFlight() int {
+	return int(atomic.LoadInt64(&e.inFlight))
+}
+
+// BeginWarmup records the results to the warmup statistics until EndWarmup is called, it has to be called before Run
+func (e *Engine) BeginWarmup() {
+	atomic.StoreInt32(&e.warming, 1)
+}
+
+// EndWarmup starts recording the results to the statistics of the run, it returns false if the warmup was over already
+func (e *Engine) EndWarmup() bool {
+	return atomic.CompareAndSwapInt32(&e.warming, 1, 0)
+}
+
+// Warming returns whether the engine is in its warmup
+func (e *Engine) Warming() bool {
+	return atomic.LoadInt32(&e.warming) == 1
+}
+
+// WarmupSnapshot returns a copy of the statistics of the results of the warmup
+func (e *Engine) WarmupSnapshot() Snapshot {
+	return e.warmup.Snapshot()
+}
+
+// RestoreWarmup continues the warmup statistics of a resumed run from snapshot, it has to be called before Run
+func (e *Engine) RestoreWarmup(snapshot Snapshot) {
+	e.warmup.Restore(snapshot)
+}
+
+// Elapsed returns how long the run has been going including the offset
+func (e *Engine) Elapsed() time.Duration {
+	start := atomic.LoadInt64(&e.start)
+	if start == 0 {
+		return e.Offset
+	}
+	return time.Since(time.Unix(0, start))
+}
+
+// Stage returns the index of the profile stage the engine is in
+func (e *Engine) Stage() int {
+	return int(atomic.LoadInt64(&e.stage))
+}
+
+// Summary returns the statistics of the results so far
+func (e *Engine) Summary() Summary {
+	return e.stats.Summary()
+}
+
+// Snapshot returns a copy of the statistics of the results so far
+func (e *Engine) Snapshot() Snapshot {
+	return e.stats.Snapshot()
+}
+
+// Window returns the number of requests that finished and failed within the last window
+func (e *Engine) Window(window time.Duration) (finished uint64, failed uint64) {
+	return e.stats.Window(window)
+}
+
+// RecentLatency returns the latencies of the requests that finished within the last window
+func (e *Engine) RecentLatency(window time.Duration) Histogram {
+	return e.stats.RecentLatency(window)
+}
+
+// StageName returns the name of the profile stage the engine is in, it's empty without a profile or once it's over
+func (e *Engine) StageName() string {
+	if stage := e.Stage(); e.Profile != nil && stage < len(e.Profile.Stages) {
+		return e.Profile.Stages[stage].Name
+	}
+	return ""
+}
+
+// level returns the rate or the number of users after elapsed time, ok is false once the profile is over
+func (e *Engine) level(elapsed time.Duration) (float64, bool) {
+	if e.Profile == nil {
+		if e.Model == ClosedModel {
+			return e.share(float64(e.Concurrency)), true
+		}
+		return e.share(e.Rate), true
+	}
+	level, stage, ok := e.Profile.At(elapsed)
+	atomic.StoreInt64(&e.stage, int64(stage))
+	return e.share(level), ok
+}
+
+func (e *Engine) runOpen(ctx context.Context, wg *sync.WaitGroup) {
+	if e.Profile == nil && e.Rate <= 0 {
+		return
+	}
+
+	var slots chan struct{}
+	if e.MaxInFlight > 0 {
+		slots = make(chan struct{}, e.MaxInFlight)
+	}
+
+	start := time.Unix(0, atomic.LoadInt64(&e.start))
+	elapsed, credit := e.Offset, 1.0
+	for sent := 0; e.Limit == 0 || sent < e.Limit; sent++ {
+		var ok bool
+		if elapsed, credit, ok = e.nextArrival(elapsed, credit); !ok {
+			return
+		}
+		// Schedule from the start rather than now, so a late request doesn't shift the rest
+		intended := start.Add(elapsed)
+		if !sleepUntil(ctx, intended) {
+			return
+		}
+		if slots != nil {
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		wg.Add(1)
+		go func(intended time.Time) {
+			defer wg.Done()
+			e.send(ctx, intended)
+			if slots != nil {
+				<-slots
+			}
+		}(intended)
+	}
+}
+
+// nextArrival moves elapsed forward until the rate adds up to a whole request, credit is the
+// part of a request accumulated so far
+func (e *Engine) nextArrival(elapsed time.Duration, credit float64) (time.Duration, float64, bool) {
+	for {
+		rate, ok := e.level(elapsed)
+		if !ok {
+			return elapsed, credit, false
+		}
+		if credit >= 1 {
+			return elapsed, credit - 1, true
+		}
+		if rate > 0 {
+			if wait := (1 - credit) / rate; wait <= scheduleStep.Seconds() {
+				return elapsed + time.Duration(wait*float64(time.Second)), 0, true
+			}
+		}
+		elapsed += scheduleStep
+		credit += rate * scheduleStep.Seconds()
+	}
+}
+
+func (e *Engine) runClosed(ctx context.Context, wg *sync.WaitGroup) {
+	// Tickets are shared across the users so the limit counts every request
+	tickets := make(chan struct{})
+	exhausted := make(chan struct{})
+	over := make(chan struct{})
+	go func() {
+		defer close(exhausted)
+		defer close(tickets)
+		for sent := 0; e.Limit == 0 || sent < e.Limit; sent++ {
+			select {
+			case tickets <- struct{}{}:
+			case <-ctx.Done():
+				return
+			case <-over:
+				return
+			}
+		}
+	}()
+
+	var active int64
+	spawned := 0
+	setUsers := func(level float64) {
+		users := int(math.Round(level))
+		atomic.StoreInt64(&active, int64(users))
+		for ; spawned < users; spawned++ {
+			wg.Add(1)
+			go e.user(ctx, wg, int64(spawned), &active, tickets, exhausted)
+		}
+	}
+
+	if e.Profile == nil {
+		users, _ := e.level(0)
+		setUsers(users)
+		return
+	}
+
+	ticker := time.NewTicker(scheduleStep)
+	defer ticker.Stop()
+	start := time.Unix(0, atomic.LoadInt64(&e.start))
+	for {
+		level, ok := e.level(time.Since(start))
+		if !ok {
+			close(over)
+			return
+		}
+		setUsers(level)
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		case <-exhausted:
+			return
+		}
+	}
+}
+
+// user sends requests back to back while its id is below the number of active users
+func (e *Engine) user(ctx context.Context, wg *sync.WaitGroup, id int64, active *int64, tickets <-chan struct{}, exhausted <-chan struct{}) {
+	defer wg.Done()
+	for {
+		if id >= atomic.LoadInt64(active) {
+			select {
+			case <-time.After(scheduleStep):
+				continue
+			case <-exhausted:
+				return
+			}
+		}
+		if _, ok := <-tickets; !ok {
+			return
+		}
+		e.send(ctx, time.Now())
+	}
+}
+
+func (e *Engine) send(ctx context.Context, intended time.Time) {
+	if e.NewJourney != nil {
+		e.journey(ctx, intended)
+		return
+	}
+	e.sendWith(ctx, intended, e.Send)
+}
+
+// sendWith sends a single request with send
+func (e *Engine) sendWith(ctx context.Context, intended time.Time, send Sender) {
+	sent := time.Now()
+	atomic.AddInt64(&e.inFlight, 1)
+	result := send(ctx)
+	atomic.AddInt64(&e.inFlight, -1)
+	if ctx.Err() != nil {
+		// The run was stopped while the request was in flight, it didn't fail on its own
+		return
+	}
+	e.record(result, intended, sent)
+}
+
+// journey sends the requests of a journey, the latency of a request is counted from the end of the think
+// time before it. Journeys cut short by the end of the run aren't counted
+func (e *Engine) journey(ctx context.Context, intended time.Time) {
+	journey := e.NewJourney()
+	start := intended
+	scenario, failed := "", false
+	for {
+		sent := time.Now()
+		atomic.AddInt64(&e.inFlight, 1)
+		result, think, ok := journey.Next(ctx)
+		atomic.AddInt64(&e.inFlight, -1)
+		if !ok {
+			break
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		e.record(result, intended, sent)
+		scenario = result.Scenario
+		failed = failed || result.Err != nil
+		if think > 0 && !sleepUntil(ctx, time.Now().Add(think)) {
+			return
+		}
+		intended = time.Now()
+	}
+	if e.Warming() {
+		e.warmup.RecordJourney(scenario, time.Since(start), failed)
+	} else {
+		e.stats.RecordJourney(scenario, time.Since(start), failed)
+	}
+}
+
+func (e *Engine) record(result Result, intended time.Time, sent time.Time) {
+	result.Intended = intended
+	result.Sent = sent
+	result.Offset = intended.Sub(time.Unix(0, atomic.LoadInt64(&e.start)))
+	result.Latency = time.Since(intended)
+	if e.Warming() {
+		result.Warmup = true
+		e.warmup.Record(result)
+	} else {
+		e.stats.Record(result)
+	}
+	if e.OnResult != nil {
+		e.OnResult(result)
+	}
+}
+
+// sleepUntil waits till t, it returns false if the context was cancelled before that
+func sleepUntil(ctx context.Context, t time.Time) bool {
+	d := time.Until(t)
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,239 @@
+package engine
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestNextArrival(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   Config
+		arrivals []time.Duration
+	}{
+		{
+			name:     "constant rate",
+			config:   Config{Model: OpenModel, Rate: 10},
+			arrivals: []time.Duration{0, 100 * time.Millisecond, 200 * time.Millisecond, 300 * time.Millisecond},
+		},
+		{
+			name:     "high rate",
+			config:   Config{Model: OpenModel, Rate: 1000},
+			arrivals: []time.Duration{0, time.Millisecond, 2 * time.Millisecond},
+		},
+		{
+			name: "profile",
+			config: Config{Model: OpenModel, Profile: &Profile{Stages: []Stage{
+				{Duration: time.Second, Shape: Step, Target: 0},
+				{Duration: time.Second, Shape: Step, Target: 4},
+			}}},
+			arrivals: []time.Duration{0, 1250 * time.Millisecond, 1500 * time.Millisecond, 1750 * time.Millisecond},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := Engine{Config: tt.config}
+			elapsed, credit := time.Duration(0), 1.0
+			for i, want := range tt.arrivals {
+				var ok bool
+				if elapsed, credit, ok = e.nextArrival(elapsed, credit); !ok {
+					t.Fatalf("arrival %d: profile is over", i)
+				}
+				if diff := elapsed - want; diff < -time.Millisecond || diff > time.Millisecond {
+					t.Errorf("arrival %d = %v, want %v", i, elapsed, want)
+				}
+			}
+		})
+	}
+}
+
+func TestNextArrivalProfileOver(t *testing.T) {
+	e := Engine{Config: Config{Model: OpenModel, Profile: &Profile{Stages: []Stage{{Duration: 500 * time.Millisecond, Shape: Step, Target: 1}}}}}
+	elapsed, credit, ok := e.nextArrival(0, 1)
+	if !ok || elapsed != 0 {
+		t.Fatalf("first arrival = %v, %v, want 0, true", elapsed, ok)
+	}
+	if _, _, ok := e.nextArrival(elapsed, credit); ok {
+		t.Errorf("an arrival after the profile is over was scheduled")
+	}
+}
+
+func TestRunLimit(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+	}{
+		{name: "open model", config: Config{Model: OpenModel, Rate: 2000, Limit: 25}},
+		{name: "open model with max in flight", config: Config{Model: OpenModel, Rate: 2000, Limit: 25, MaxInFlight: 2}},
+		{name: "closed model", config: Config{Model: ClosedModel, Concurrency: 4, Limit: 25}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sent, results int64
+			e := Engine{
+				Config: tt.config,
+				Send: func(ctx context.Context) Result {
+					atomic.AddInt64(&sent, 1)
+					return Result{StatusCode: 200}
+				},
+				OnResult: func(result Result) {
+					atomic.AddInt64(&results, 1)
+					if result.Latency < result.Sent.Sub(result.Intended) {
+						t.Errorf("latency %v is shorter than the wait for the schedule", result.Latency)
+					}
+				},
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			e.Run(ctx)
+
+			if ctx.Err() != nil {
+				t.Fatalf("run didn't stop at the limit")
+			}
+			if sent != int64(tt.config.Limit) || results != int64(tt.config.Limit) {
+				t.Errorf("sent %d requests with %d results, want %d", sent, results, tt.config.Limit)
+			}
+		})
+	}
+}
+
+func TestRunCancelled(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+	}{
+		{name: "open model", config: Config{Model: OpenModel, Rate: 1000}},
+		{name: "closed model", config: Config{Model: ClosedModel, Concurrency: 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			var results int64
+			e := Engine{
+				Config: tt.config,
+				Send: func(ctx context.Context) Result {
+					// Every request is still in flight when the run is stopped
+					cancel()
+					<-ctx.Done()
+					return Result{Err: ctx.Err()}
+				},
+				OnResult: func(Result) {
+					atomic.AddInt64(&results, 1)
+				},
+			}
+			e.Run(ctx)
+			if results != 0 {
+				t.Errorf("%d requests stopped by the end of the run were counted", results)
+			}
+		})
+	}
+}
+
+func TestRunWarmup(t *testing.T) {
+	var e *Engine
+	e = &Engine{
+		Config: Config{Model: ClosedModel, Concurrency: 1, Limit: 10},
+		Send: func(ctx context.Context) Result {
+			return Result{StatusCode: 200}
+		},
+		OnResult: func(result Result) {
+			// The warmup ends once 4 requests finished
+			if result.Warmup && e.WarmupSnapshot().Latency.Total == 4 && !e.EndWarmup() {
+				t.Errorf("EndWarmup() = false, want the warmup to end once")
+			}
+		},
+	}
+	e.BeginWarmup()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	e.Run(ctx)
+
+	if e.Warming() || e.EndWarmup() {
+		t.Errorf("the engine is still in its warmup")
+	}
+	if got := e.WarmupSnapshot().Latency.Total; got != 4 {
+		t.Errorf("warmup requests = %d, want 4", got)
+	}
+	if got := e.Snapshot().Latency.Total; got != 6 {
+		t.Errorf("requests of the run = %d, want 6", got)
+	}
+}
+
+// testJourney sends steps requests, the one before last fails when failing is set
+type testJourney struct {
+	steps   int
+	failing bool
+	sent    int
+}
+
+func (j *testJourney) Next(ctx context.Context) (Result, time.Duration, bool) {
+	if j.sent == j.steps {
+		return Result{}, 0, false
+	}
+	result := Result{StatusCode: 200, Scenario: "browse", Step: j.sent}
+	if j.failing && j.sent == j.steps-2 {
+		result.Err = context.DeadlineExceeded
+	}
+	j.sent++
+	return result, time.Millisecond, true
+}
+
+func TestRunJourneys(t *testing.T) {
+	var journeys, results int64
+	e := Engine{
+		Config: Config{Model: ClosedModel, Concurrency: 2, Limit: 4},
+		NewJourney: func() Journey {
+			// Every other journey fails
+			return &testJourney{steps: 3, failing: atomic.AddInt64(&journeys, 1)%2 == 0}
+		},
+		OnResult: func(Result) {
+			atomic.AddInt64(&results, 1)
+		},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	e.Run(ctx)
+
+	if journeys != 4 || results != 12 {
+		t.Fatalf("started %d journeys with %d results, want 4 journeys of 3 requests", journeys, results)
+	}
+	snapshot := e.Snapshot()
+	if snapshot.Journeys() != 4 || snapshot.Latency.Total != 12 {
+		t.Errorf("recorded %d journeys of %d requests, want 4 of 12", snapshot.Journeys(), snapshot.Latency.Total)
+	}
+	browse := snapshot.Scenarios["browse"]
+	if browse == nil || browse.Failed != 2 || browse.Duration.Latency().Mean < 2*time.Millisecond {
+		t.Errorf("browse = %+v, want 2 failed journeys taking at least their think time", browse)
+	}
+}
+
+func TestRunInFlight(t *testing.T) {
+	release := make(chan struct{})
+	var started int64
+	e := &Engine{
+		Config: Config{Model: ClosedModel, Concurrency: 3, Limit: 3},
+		Send: func(ctx context.Context) Result {
+			atomic.AddInt64(&started, 1)
+			<-release
+			return Result{StatusCode: 200}
+		},
+	}
+	done := make(chan struct{})
+	go func() {
+		e.Run(context.Background())
+		close(done)
+	}()
+	for atomic.LoadInt64(&started) < 3 {
+		time.Sleep(time.Millisecond)
+	}
+	if got := e.InFlight(); got != 3 {
+		t.Errorf("InFlight() = %d while every user waits on a response, want 3", got)
+	}
+	close(release)
+	<-done
+	if got := e.InFlight(); got != 0 {
+		t.Errorf("InFlight() = %d after the run, want 0", got)
+	}
+}
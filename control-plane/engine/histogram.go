@@ -0,0 +1,149 @@
+package engine
+
+import (
+	"math/bits"
+	"time"
+)
+
+// Buckets are linear up to subBucketCount microseconds, after that every power of two is split into
+// subBucketCount/2 buckets, which keeps the error of a recorded value under 1/64 (HDR histogram layout)
+const (
+	subBucketBits  = 7
+	subBucketCount = 1 << subBucketBits
+	subBucketHalf  = subBucketCount / 2
+)
+
+// Histogram records latencies in microseconds with a bounded relative error, the zero value is ready to use.
+// It is not safe for concurrent use
+type Histogram struct {
+	Counts []uint64 `json:"counts,omitempty"`
+	Total  uint64   `json:"total"`
+	// Sum and Max are exact, in microseconds
+	Sum uint64 `json:"sum"`
+	Max uint64 `json:"max"`
+}
+
+// Record adds a latency to the histogram, negative latencies are recorded as zero
+func (h *Histogram) Record(d time.Duration) {
+	v := uint64(0)
+	if d > 0 {
+		v = uint64(d / time.Microsecond)
+	}
+
+	i := bucketIndex(v)
+	if i >= len(h.Counts) {
+		counts := make([]uint64, i+1)
+		copy(counts, h.Counts)
+		h.Counts = counts
+	}
+	h.Counts[i]++
+	h.Total++
+	h.Sum += v
+	if v > h.Max {
+		h.Max = v
+	}
+}
+
+// Merge adds the values recorded by other to the histogram
+func (h *Histogram) Merge(other Histogram) {
+	if len(other.Counts) > len(h.Counts) {
+		counts := make([]uint64, len(other.Counts))
+		copy(counts, h.Counts)
+		h.Counts = counts
+	}
+	for i, count := range other.Counts {
+		h.Counts[i] += count
+	}
+	h.Total += other.Total
+	h.Sum += other.Sum
+	if other.Max > h.Max {
+		h.Max = other.Max
+	}
+}
+
+// Quantile returns the latency q (0-1) of the recorded values are at or below
+func (h *Histogram) Quantile(q float64) time.Duration {
+	if h.Total == 0 {
+		return 0
+	}
+	if q >= 1 {
+		return h.MaxLatency()
+	}
+
+	rank := uint64(q*float64(h.Total) + 0.5)
+	if rank < 1 {
+		rank = 1
+	}
+	seen := uint64(0)
+	for i, count := range h.Counts {
+		seen += count
+		if seen >= rank {
+			v := bucketHighest(i)
+			if v > h.Max {
+				v = h.Max
+			}
+			return time.Duration(v) * time.Microsecond
+		}
+	}
+	return h.MaxLatency()
+}
+
+// Latency returns the common percentiles of the histogram
+func (h *Histogram) Latency() Latency {
+	return Latency{
+		P50:  h.Quantile(0.5),
+		P90:  h.Quantile(0.9),
+		P95:  h.Quantile(0.95),
+		P99:  h.Quantile(0.99),
+		Max:  h.MaxLatency(),
+		Mean: h.Mean(),
+	}
+}
+
+// CumulativeCounts returns the number of values at or below every bound, bounds are in increasing order.
+// A bucket is counted under the first bound its highest value fits in
+func (h *Histogram) CumulativeCounts(bounds []time.Duration) []uint64 {
+	counts := make([]uint64, len(bounds))
+	b := 0
+	seen := uint64(0)
+	for i, count := range h.Counts {
+		for b < len(bounds) && time.Duration(bucketHighest(i))*time.Microsecond > bounds[b] {
+			counts[b] = seen
+			b++
+		}
+		seen += count
+	}
+	for ; b < len(bounds); b++ {
+		counts[b] = seen
+	}
+	return counts
+}
+
+func (h *Histogram) MaxLatency() time.Duration {
+	return time.Duration(h.Max) * time.Microsecond
+}
+
+func (h *Histogram) Mean() time.Duration {
+	if h.Total == 0 {
+		return 0
+	}
+	return time.Duration(h.Sum/h.Total) * time.Microsecond
+}
+
+func bucketIndex(v uint64) int {
+	if v < subBucketCount {
+		return int(v)
+	}
+	shift := bits.Len64(v) - subBucketBits
+	return shift*subBucketHalf + int(v>>uint(shift))
+}
+
+// bucketHighest returns the highest value that falls into the bucket
+func bucketHighest(i int) uint64 {
+	if i < subBucketCount {
+		return uint64(i)
+	}
+	shift := uint(i/subBucketHalf - 1)
+	mantissa := uint64(i%subBucketHalf + subBucketHalf)
+	return (mantissa+1)<<shift - 1
+}
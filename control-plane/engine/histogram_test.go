@@ -0,0 +1,157 @@
+package engine
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBucketIndex(t *testing.T) {
+	tests := []struct {
+		value   uint64
+		index   int
+		highest uint64
+	}{
+		{value: 0, index: 0, highest: 0},
+		{value: 1, index: 1, highest: 1},
+		{value: 127, index: 127, highest: 127},
+		{value: 128, index: 128, highest: 129},
+		{value: 129, index: 128, highest: 129},
+		{value: 130, index: 129, highest: 131},
+		{value: 255, index: 191, highest: 255},
+		{value: 256, index: 192, highest: 259},
+		{value: 1000, index: 317, highest: 1007},
+		{value: 50000, index: 673, highest: 50175},
+	}
+	for _, tt := range tests {
+		index := bucketIndex(tt.value)
+		if index != tt.index {
+			t.Errorf("bucketIndex(%d) = %d, want %d", tt.value, index, tt.index)
+		}
+		if highest := bucketHighest(index); highest != tt.highest {
+			t.Errorf("bucketHighest(%d) = %d, want %d", index, highest, tt.highest)
+		}
+	}
+}
+
+func TestBucketError(t *testing.T) {
+	for v := uint64(1); v < 1<<30; v = v*3/2 + 1 {
+		highest := bucketHighest(bucketIndex(v))
+		if highest < v || float64(highest-v) > float64(v)/64 {
+			t.Errorf("value %d is in a bucket up to %d, the error is over 1/64", v, highest)
+		}
+		if v > 0 && bucketIndex(v-1) > bucketIndex(v) {
+			t.Errorf("bucketIndex(%d) > bucketIndex(%d)", v-1, v)
+		}
+	}
+}
+
+// millis records 1ms, 2ms, ..., n ms
+func millis(n int) Histogram {
+	var h Histogram
+	for i := 1; i <= n; i++ {
+		h.Record(time.Duration(i) * time.Millisecond)
+	}
+	return h
+}
+
+func TestHistogramQuantile(t *testing.T) {
+	tests := []struct {
+		name      string
+		histogram Histogram
+		q         float64
+		want      time.Duration
+	}{
+		{name: "empty", histogram: Histogram{}, q: 0.5, want: 0},
+		{name: "p0", histogram: millis(100), q: 0, want: time.Millisecond},
+		{name: "p50", histogram: millis(100), q: 0.5, want: 50 * time.Millisecond},
+		{name: "p90", histogram: millis(100), q: 0.9, want: 90 * time.Millisecond},
+		{name: "p99", histogram: millis(100), q: 0.99, want: 99 * time.Millisecond},
+		{name: "p100 is the max", histogram: millis(100), q: 1, want: 100 * time.Millisecond},
+		{name: "single value", histogram: millis(1), q: 0.99, want: time.Millisecond},
+		{name: "exact below 128us", histogram: func() Histogram {
+			var h Histogram
+			for i := 0; i < 100; i++ {
+				h.Record(time.Duration(i) * time.Microsecond)
+			}
+			return h
+		}(), q: 0.5, want: 49 * time.Microsecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.histogram.Quantile(tt.q)
+			if got < tt.want || float64(got-tt.want) > float64(tt.want)/64 {
+				t.Errorf("Quantile(%v) = %v, want %v within 1/64", tt.q, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHistogramQuantileCappedAtMax(t *testing.T) {
+	var h Histogram
+	h.Record(1000 * time.Microsecond)
+	if got := h.Quantile(0.5); got != time.Millisecond {
+		t.Errorf("Quantile(0.5) = %v, want the max %v rather than the top of its bucket", got, time.Millisecond)
+	}
+}
+
+func TestHistogramMeanAndMax(t *testing.T) {
+	h := millis(100)
+	h.Record(-time.Second)
+	if got := h.MaxLatency(); got != 100*time.Millisecond {
+		t.Errorf("MaxLatency() = %v, want 100ms", got)
+	}
+	// The negative latency is recorded as zero, 5050ms over 101 requests
+	if got, want := h.Mean(), 5050*time.Millisecond/101/time.Microsecond*time.Microsecond; got != want {
+		t.Errorf("Mean() = %v, want %v", got, want)
+	}
+	if h.Total != 101 || h.Counts[0] != 1 {
+		t.Errorf("Total = %d and %d zeros, want 101 and 1", h.Total, h.Counts[0])
+	}
+	if (&Histogram{}).Mean() != 0 {
+		t.Errorf("an empty histogram has a mean")
+	}
+}
+
+func TestHistogramMerge(t *testing.T) {
+	var short, long, all Histogram
+	for i := 1; i <= 200; i++ {
+		d := time.Duration(i*i) * time.Microsecond
+		all.Record(d)
+		if i%2 == 0 {
+			short.Record(d)
+		} else {
+			long.Record(d)
+		}
+	}
+	long.Counts = append(long.Counts, 0, 0)
+
+	var merged Histogram
+	merged.Merge(short)
+	merged.Merge(long)
+	if merged.Total != all.Total || merged.Sum != all.Sum || merged.Max != all.Max {
+		t.Errorf("merged total, sum and max = %d, %d, %d, want %d, %d, %d", merged.Total, merged.Sum, merged.Max, all.Total, all.Sum, all.Max)
+	}
+	for _, q := range []float64{0.5, 0.9, 0.99} {
+		if merged.Quantile(q) != all.Quantile(q) {
+			t.Errorf("merged Quantile(%v) = %v, want %v", q, merged.Quantile(q), all.Quantile(q))
+		}
+	}
+}
+
+func TestHistogramCumulativeCounts(t *testing.T) {
+	var h Histogram
+	for _, d := range []time.Duration{3 * time.Millisecond, 20 * time.Millisecond, 21 * time.Millisecond, 2 * time.Second} {
+		h.Record(d)
+	}
+	bounds := []time.Duration{time.Millisecond, 5 * time.Millisecond, 10 * time.Millisecond, 25 * time.Millisecond, time.Second, 2500 * time.Millisecond, 5 * time.Second}
+	got := h.CumulativeCounts(bounds)
+	want := []uint64{0, 1, 1, 3, 3, 4, 4}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("CumulativeCounts() = %v, want %v", got, want)
+	}
+	var empty Histogram
+	if got := empty.CumulativeCounts(bounds); !reflect.DeepEqual(got, make([]uint64, len(bounds))) {
+		t.Errorf("CumulativeCounts() of an empty histogram = %v, want zeros", got)
+	}
+}
@@ -0,0 +1,74 @@
+package engine
+
+import (
+	"math"
+	"time"
+)
+
+type Shape string
+
+const (
+	// Step jumps to the target at the start of the stage
+	Step Shape = "Step"
+	// Ramp moves linearly from the previous level to the target over the stage
+	Ramp Shape = "Ramp"
+	// Spike rises linearly to the target at the middle of the stage and falls back to the previous level by its end
+	Spike Shape = "Spike"
+	// Sine oscillates around the target by the amplitude
+	Sine Shape = "Sine"
+)
+
+// Stage is a part of a load profile, levels are a rate in the open model and a number of users in the closed model
+type Stage struct {
+	Name      string
+	Duration  time.Duration
+	Shape     Shape
+	Target    float64
+	Amplitude float64
+	// Period of a sine stage, defaults to the duration of the stage
+	Period time.Duration
+}
+
+// Profile is a sequence of stages the load level follows
+type Profile struct {
+	// Start is the level before the first stage, a ramp or spike in the first stage starts from it
+	Start  float64
+	Stages []Stage
+}
+
+// At returns the load level after elapsed time with the index of the current stage,
+// ok is false once every stage is over
+func (p *Profile) At(elapsed time.Duration) (level float64, stage int, ok bool) {
+	previous := p.Start
+	for i, s := range p.Stages {
+		if elapsed >= s.Duration {
+			elapsed -= s.Duration
+			if s.Shape != Spike {
+				previous = s.Target
+			}
+			continue
+		}
+
+		f := float64(elapsed) / float64(s.Duration)
+		switch s.Shape {
+		case Ramp:
+			level = previous + (s.Target-previous)*f
+		case Spike:
+			if f < 0.5 {
+				level = previous + (s.Target-previous)*f*2
+			} else {
+				level = s.Target + (previous-s.Target)*(f*2-1)
+			}
+		case Sine:
+			period := s.Period
+			if period <= 0 {
+				period = s.Duration
+			}
+			level = s.Target + s.Amplitude*math.Sin(2*math.Pi*float64(elapsed)/float64(period))
+		default:
+			level = s.Target
+		}
+		return math.Max(level, 0), i, true
+	}
+	return 0, len(p.Stages), false
+}
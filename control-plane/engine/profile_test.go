@@ -0,0 +1,71 @@
+package engine
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestProfileAt(t *testing.T) {
+	profile := &Profile{
+		Start: 10,
+		Stages: []Stage{
+			{Name: "step", Duration: 10 * time.Second, Shape: Step, Target: 20},
+			{Name: "ramp", Duration: 10 * time.Second, Shape: Ramp, Target: 40},
+			{Name: "spike", Duration: 10 * time.Second, Shape: Spike, Target: 100},
+			{Name: "sine", Duration: 8 * time.Second, Shape: Sine, Target: 40, Amplitude: 10, Period: 4 * time.Second},
+		},
+	}
+	tests := []struct {
+		name    string
+		profile *Profile
+		elapsed time.Duration
+		level   float64
+		stage   int
+		ok      bool
+	}{
+		{name: "step start", profile: profile, elapsed: 0, level: 20, stage: 0, ok: true},
+		{name: "step end", profile: profile, elapsed: 9999 * time.Millisecond, level: 20, stage: 0, ok: true},
+		{name: "ramp start", profile: profile, elapsed: 10 * time.Second, level: 20, stage: 1, ok: true},
+		{name: "ramp quarter", profile: profile, elapsed: 12500 * time.Millisecond, level: 25, stage: 1, ok: true},
+		{name: "ramp half", profile: profile, elapsed: 15 * time.Second, level: 30, stage: 1, ok: true},
+		{name: "spike start", profile: profile, elapsed: 20 * time.Second, level: 40, stage: 2, ok: true},
+		{name: "spike rising", profile: profile, elapsed: 22500 * time.Millisecond, level: 70, stage: 2, ok: true},
+		{name: "spike peak", profile: profile, elapsed: 25 * time.Second, level: 100, stage: 2, ok: true},
+		{name: "spike falling", profile: profile, elapsed: 27500 * time.Millisecond, level: 70, stage: 2, ok: true},
+		{name: "sine start", profile: profile, elapsed: 30 * time.Second, level: 40, stage: 3, ok: true},
+		{name: "sine crest", profile: profile, elapsed: 31 * time.Second, level: 50, stage: 3, ok: true},
+		{name: "sine trough", profile: profile, elapsed: 33 * time.Second, level: 30, stage: 3, ok: true},
+		{name: "sine second period", profile: profile, elapsed: 35 * time.Second, level: 50, stage: 3, ok: true},
+		{name: "over", profile: profile, elapsed: 38 * time.Second, level: 0, stage: 4, ok: false},
+		{
+			name:    "ramp from start",
+			profile: &Profile{Start: 10, Stages: []Stage{{Duration: 10 * time.Second, Shape: Ramp, Target: 0}}},
+			elapsed: 5 * time.Second, level: 5, stage: 0, ok: true,
+		},
+		{
+			name:    "spike returns to the level before it",
+			profile: &Profile{Stages: []Stage{{Duration: time.Second, Shape: Spike, Target: 50}, {Duration: 10 * time.Second, Shape: Ramp, Target: 10}}},
+			elapsed: 6 * time.Second, level: 5, stage: 1, ok: true,
+		},
+		{
+			name:    "sine period defaults to the stage",
+			profile: &Profile{Stages: []Stage{{Duration: 4 * time.Second, Shape: Sine, Target: 5, Amplitude: 2}}},
+			elapsed: time.Second, level: 7, stage: 0, ok: true,
+		},
+		{
+			name:    "negative levels are cut to zero",
+			profile: &Profile{Stages: []Stage{{Duration: 4 * time.Second, Shape: Sine, Target: 5, Amplitude: 10}}},
+			elapsed: 3 * time.Second, level: 0, stage: 0, ok: true,
+		},
+		{name: "no stages", profile: &Profile{Start: 5}, elapsed: 0, level: 0, stage: 0, ok: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			level, stage, ok := tt.profile.At(tt.elapsed)
+			if math.Abs(level-tt.level) > 1e-9 || stage != tt.stage || ok != tt.ok {
+				t.Errorf("At(%v) = %v, %d, %v, want %v, %d, %v", tt.elapsed, level, stage, ok, tt.level, tt.stage, tt.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package engine
+
+import "math/rand"
+
+// splitMix64 is a small rand.Source64, cheap enough to seed one for every request
+type splitMix64 struct {
+	state uint64
+}
+
+func (s *splitMix64) Seed(seed int64) {
+	s.state = uint64(seed)
+}
+
+func (s *splitMix64) Uint64() uint64 {
+	s.state += 0x9e3779b97f4a7c15
+	z := s.state
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
+
+func (s *splitMix64) Int63() int64 {
+	return int64(s.Uint64() >> 1)
+}
+
+// mix derives a seed from seed and n, nearby inputs give unrelated seeds
+func mix(seed int64, n uint64) int64 {
+	s := splitMix64{state: uint64(seed)}
+	s.state ^= (&splitMix64{state: n}).Uint64()
+	return int64(s.Uint64())
+}
+
+// requestRand returns the random source of the request with the given sequence number, the draws
+// of a request only depend on the seed of the run and the sequence number so they don't change
+// with the order concurrent requests are sent in
+func requestRand(seed int64, sequence uint64) *rand.Rand {
+	return rand.New(&splitMix64{state: uint64(mix(seed, sequence))})
+}
+
+// ShardSeed derives the seed of a shard from the seed of the run
+func ShardSeed(seed int64, shard int) int64 {
+	if shard == 0 {
+		return seed
+	}
+	return mix(seed, uint64(shard))
+}
@@ -0,0 +1,81 @@
+package engine
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	microsimv1alpha1 "github.com/MrSupiri/MicroSim/api/v1alpha1"
+)
+
+// Recorded is a request as it was sent by a run, replaying it sends the same request tree at the same offset
+type Recorded struct {
+	// Offset is when the request was scheduled to start from the start of the run
+	Offset   time.Duration          `json:"offset"`
+	Template string                 `json:"template"`
+	Sequence uint64                 `json:"sequence"`
+	Scenario string                 `json:"scenario,omitempty"`
+	Step     int                    `json:"step,omitempty"`
+	Header   map[string]string      `json:"header,omitempty"`
+	Route    microsimv1alpha1.Route `json:"route"`
+}
+
+// NewRecorded returns the recording of a result
+func NewRecorded(result Result) Recorded {
+	return Recorded{
+		Offset:   result.Offset,
+		Template: result.Template,
+		Sequence: result.Sequence,
+		Scenario: result.Scenario,
+		Step:     result.Step,
+		Header:   result.Header,
+		Route:    result.Route,
+	}
+}
+
+// runReplay sends the recorded requests at their offsets scaled by the replay speed, a resumed run skips
+// the requests scheduled before its offset
+func (e *Engine) runReplay(ctx context.Context, wg *sync.WaitGroup) {
+	speed := e.ReplaySpeed
+	if speed <= 0 {
+		speed = 1
+	}
+	var slots chan struct{}
+	if e.MaxInFlight > 0 {
+		slots = make(chan struct{}, e.MaxInFlight)
+	}
+
+	start := time.Unix(0, atomic.LoadInt64(&e.start))
+	sent := 0
+	for _, recorded := range e.Replay {
+		if e.Limit > 0 && sent >= e.Limit {
+			return
+		}
+		elapsed := time.Duration(float64(recorded.Offset) / speed)
+		if elapsed < e.Offset {
+			continue
+		}
+		intended := start.Add(elapsed)
+		if !sleepUntil(ctx, intended) {
+			return
+		}
+		if slots != nil {
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		sent++
+		wg.Add(1)
+		go func(recorded Recorded, intended time.Time) {
+			defer wg.Done()
+			e.sendWith(ctx, intended, func(ctx context.Context) Result { return e.SendRecorded(ctx, recorded) })
+			if slots != nil {
+				<-slots
+			}
+		}(recorded, intended)
+	}
+}
@@ -0,0 +1,98 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	microsimv1alpha1 "github.com/MrSupiri/MicroSim/api/v1alpha1"
+)
+
+func TestRunReplay(t *testing.T) {
+	recording := []Recorded{
+		{Offset: 0, Sequence: 0},
+		{Offset: 100 * time.Millisecond, Sequence: 1},
+		{Offset: 200 * time.Millisecond, Sequence: 2},
+		{Offset: 300 * time.Millisecond, Sequence: 3},
+	}
+	tests := []struct {
+		name   string
+		speed  float64
+		offset time.Duration
+		limit  int
+		// sent are the sequences replayed and their offsets from the start of the run
+		sent    []uint64
+		offsets []time.Duration
+	}{
+		{name: "original speed", speed: 1, sent: []uint64{0, 1, 2, 3}, offsets: []time.Duration{0, 100 * time.Millisecond, 200 * time.Millisecond, 300 * time.Millisecond}},
+		{name: "twice as fast", speed: 2, sent: []uint64{0, 1, 2, 3}, offsets: []time.Duration{0, 50 * time.Millisecond, 100 * time.Millisecond, 150 * time.Millisecond}},
+		{name: "limit", speed: 1, limit: 2, sent: []uint64{0, 1}, offsets: []time.Duration{0, 100 * time.Millisecond}},
+		{name: "resumed", speed: 1, offset: 150 * time.Millisecond, sent: []uint64{2, 3}, offsets: []time.Duration{200 * time.Millisecond, 300 * time.Millisecond}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			sent := map[uint64]time.Duration{}
+			e := &Engine{
+				Config: Config{Model: OpenModel, ReplaySpeed: tt.speed, Limit: tt.limit, MaxInFlight: 2},
+				Replay: recording,
+				SendRecorded: func(ctx context.Context, recorded Recorded) Result {
+					return Result{StatusCode: 200, Sequence: recorded.Sequence}
+				},
+			}
+			e.OnResult = func(result Result) {
+				mu.Lock()
+				defer mu.Unlock()
+				sent[result.Sequence] = result.Intended.Sub(time.Unix(0, e.start))
+			}
+			e.Offset = tt.offset
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			e.Run(ctx)
+
+			if len(sent) != len(tt.sent) {
+				t.Fatalf("replayed %v, want sequences %v", sent, tt.sent)
+			}
+			for i, sequence := range tt.sent {
+				offset, ok := sent[sequence]
+				if !ok || offset != tt.offsets[i] {
+					t.Errorf("request %d was scheduled at %v, want %v", sequence, offset, tt.offsets[i])
+				}
+			}
+		})
+	}
+}
+
+func TestTargetReplay(t *testing.T) {
+	route := microsimv1alpha1.Route{Designation: "http://front", Routes: []microsimv1alpha1.Route{
+		{Designation: "http://cart", Probability: 30},
+	}}
+	target := NewReplayTarget(testSimulation(), 1)
+	rt := &roundTripper{}
+	target.client = &http.Client{Transport: rt}
+
+	recorded := Recorded{Template: "browse", Sequence: 7, Scenario: "purchase", Step: 1, Header: map[string]string{"X-Session-ID": "abc"}, Route: sampledRoute(route, hop{
+		designation: "http://front", routes: []hop{{designation: "http://cart"}},
+	})}
+	result := target.Replay(context.Background(), recorded)
+	if result.Err != nil {
+		t.Fatalf("Replay() error = %v", result.Err)
+	}
+	if result.Template != "browse" || result.Sequence != 7 || result.Scenario != "purchase" || result.Step != 1 {
+		t.Errorf("result = %+v, want request 7 of browse in step 1 of purchase", result)
+	}
+	if len(rt.requests) != 1 || rt.requests[0].Header.Get("X-Session-ID") != "abc" {
+		t.Fatalf("requests = %v, want one with the recorded session", rt.requests)
+	}
+	var sent microsimv1alpha1.Route
+	if err := json.Unmarshal(result.Request, &sent); err != nil {
+		t.Fatal(err)
+	}
+	// The recorded calls are always made again
+	if len(sent.Routes) != 1 || sent.Routes[0].Designation != "http://cart" || sent.Routes[0].Probability != 100 {
+		t.Errorf("sent route = %+v, want the recorded call to cart", sent)
+	}
+}
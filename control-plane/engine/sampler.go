@@ -0,0 +1,90 @@
+package engine
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Sampler keeps a bounded set of results, the most recent failed ones and successful ones picked
+// uniformly over the run (reservoir sampling). It is safe for concurrent use
+type Sampler struct {
+	mu        sync.Mutex
+	rand      *rand.Rand
+	errors    []Result
+	nextError int
+	successes []Result
+	succeeded uint64
+
+	maxErrors    int
+	maxSuccesses int
+}
+
+// NewSampler creates a sampler keeping up to maxErrors failed and maxSuccesses successful results
+func NewSampler(maxErrors, maxSuccesses int) *Sampler {
+	return &Sampler{
+		rand:         rand.New(rand.NewSource(time.Now().UnixNano())),
+		maxErrors:    maxErrors,
+		maxSuccesses: maxSuccesses,
+	}
+}
+
+func (s *Sampler) Record(result Result) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if result.Err != nil {
+		if s.maxErrors <= 0 {
+			return
+		}
+		if len(s.errors) < s.maxErrors {
+			s.errors = append(s.errors, result)
+			return
+		}
+		s.errors[s.nextError] = result
+		s.nextError = (s.nextError + 1) % s.maxErrors
+		return
+	}
+
+	s.succeeded++
+	if len(s.successes) < s.maxSuccesses {
+		s.successes = append(s.successes, result)
+	} else if i := s.rand.Int63n(int64(s.succeeded)); i < int64(s.maxSuccesses) {
+		s.successes[i] = result
+	}
+}
+
+// Restore puts back the samples of a resumed run, succeeded is the number of successful results
+// they were picked from so the reservoir keeps sampling uniformly over the whole run
+func (s *Sampler) Restore(errors []Result, successes []Result, succeeded uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.errors, s.nextError = nil, 0
+	if s.maxErrors > 0 {
+		if len(errors) > s.maxErrors {
+			errors = errors[len(errors)-s.maxErrors:]
+		}
+		// The errors are ordered from the oldest so the ring starts over at the front
+		s.errors = append(s.errors, errors...)
+	}
+	if len(successes) > s.maxSuccesses {
+		successes = successes[:s.maxSuccesses]
+	}
+	s.successes = append([]Result{}, successes...)
+	s.succeeded = succeeded
+	if s.succeeded < uint64(len(s.successes)) {
+		s.succeeded = uint64(len(s.successes))
+	}
+}
+
+// Samples returns the failed results from the oldest to the newest and the sampled successful results
+func (s *Sampler) Samples() (errors []Result, successes []Result) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	errors = make([]Result, 0, len(s.errors))
+	errors = append(errors, s.errors[s.nextError:]...)
+	errors = append(errors, s.errors[:s.nextError]...)
+	return errors, append([]Result{}, s.successes...)
+}
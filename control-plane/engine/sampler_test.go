@@ -0,0 +1,54 @@
+package engine
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestSamplerErrors(t *testing.T) {
+	s := NewSampler(3, 0)
+	for _, template := range []string{"a", "b", "c", "d", "e"} {
+		s.Record(Result{Template: template, Err: errors.New("failed")})
+	}
+	s.Record(Result{Template: "ok"})
+
+	failed, successes := s.Samples()
+	var got []string
+	for _, result := range failed {
+		got = append(got, result.Template)
+	}
+	if want := []string{"c", "d", "e"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("errors = %v, want the newest %v from the oldest", got, want)
+	}
+	if len(successes) != 0 {
+		t.Errorf("kept %d successes, want none", len(successes))
+	}
+}
+
+func TestSamplerSuccesses(t *testing.T) {
+	// Every result should be kept about as often as the others over many runs
+	const runs, results, kept = 2000, 10, 2
+	counts := make([]int, results)
+	for run := 0; run < runs; run++ {
+		s := NewSampler(0, kept)
+		for i := 0; i < results; i++ {
+			s.Record(Result{StatusCode: i})
+		}
+		s.Record(Result{Err: errors.New("failed")})
+
+		failed, successes := s.Samples()
+		if len(failed) != 0 || len(successes) != kept {
+			t.Fatalf("kept %d errors and %d successes, want 0 and %d", len(failed), len(successes), kept)
+		}
+		for _, result := range successes {
+			counts[result.StatusCode]++
+		}
+	}
+	want := runs * kept / results
+	for i, count := range counts {
+		if count < want*3/4 || count > want*5/4 {
+			t.Errorf("result %d was kept %d times, want about %d", i, count, want)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	microsimv1alpha1 "github.com/MrSupiri/MicroSim/api/v1alpha1"
+)
+
+// defaultSessionHeader holds the session of a journey when the scenario doesn't name a header
+const defaultSessionHeader = "X-Session-ID"
+
+// ThinkTime draws how long a virtual user waits between two steps
+type ThinkTime struct {
+	Distribution microsimv1alpha1.ThinkTimeDistribution
+	Mean         time.Duration
+	StdDev       time.Duration
+	// Min and Max bound the draws when they are above zero
+	Min time.Duration
+	Max time.Duration
+}
+
+func (t ThinkTime) draw(rng *rand.Rand) time.Duration {
+	var d time.Duration
+	switch t.Distribution {
+	case microsimv1alpha1.UniformThinkTime:
+		d = t.Min
+		if t.Max > t.Min {
+			d += time.Duration(rng.Int63n(int64(t.Max - t.Min + 1)))
+		}
+	case microsimv1alpha1.ExponentialThinkTime:
+		d = time.Duration(rng.ExpFloat64() * float64(t.Mean))
+	case microsimv1alpha1.NormalThinkTime:
+		d = t.Mean + time.Duration(rng.NormFloat64()*float64(t.StdDev))
+	default:
+		d = t.Mean
+	}
+	if t.Min > 0 && d < t.Min {
+		d = t.Min
+	}
+	if t.Max > 0 && d > t.Max {
+		d = t.Max
+	}
+	if d < 0 {
+		return 0
+	}
+	return d
+}
+
+// thinkTime converts the think time of the spec, a nil think time doesn't wait
+func thinkTime(spec *microsimv1alpha1.ThinkTime) (ThinkTime, error) {
+	if spec == nil {
+		return ThinkTime{}, nil
+	}
+	duration := func(d *metav1.Duration) time.Duration {
+		if d == nil {
+			return 0
+		}
+		return d.Duration
+	}
+	t := ThinkTime{
+		Distribution: spec.Distribution,
+		Mean:         duration(spec.Mean),
+		StdDev:       duration(spec.StdDev),
+		Min:          duration(spec.Min),
+		Max:          duration(spec.Max),
+	}
+	switch {
+	case t.Mean < 0 || t.StdDev < 0 || t.Min < 0 || t.Max < 0:
+		return ThinkTime{}, fmt.Errorf("think time can not be negative")
+	case t.Max > 0 && t.Max < t.Min:
+		return ThinkTime{}, fmt.Errorf("think time max %v is below min %v", t.Max, t.Min)
+	case t.Distribution == microsimv1alpha1.UniformThinkTime && t.Max == 0:
+		return ThinkTime{}, fmt.Errorf("uniform think time needs a max")
+	}
+	return t, nil
+}
+
+type scenario struct {
+	name          string
+	steps         []step
+	weight        int
+	current       int
+	sessionHeader string
+}
+
+type step struct {
+	// template is the index of the route template sent
+	template int
+	// think is the wait after the step, there's none after the last one
+	think ThinkTime
+}
+
+// SetScenarios sends the scenarios as journeys, their steps refer to the route templates of the target by name.
+// It has to be called before the first journey
+func (t *Target) SetScenarios(scenarios []microsimv1alpha1.Scenario) error {
+	byName := map[string]int{}
+	for i, tmpl := range t.templates {
+		byName[tmpl.name] = i
+	}
+
+	var list []scenario
+	total := 0
+	for _, spec := range scenarios {
+		if spec.Weight < 0 {
+			return fmt.Errorf("scenario %s has a negative weight", spec.Name)
+		}
+		if len(spec.Steps) == 0 {
+			return fmt.Errorf("scenario %s has no steps", spec.Name)
+		}
+		s := scenario{name: spec.Name, weight: spec.Weight, sessionHeader: spec.SessionHeader}
+		if s.sessionHeader == "" {
+			s.sessionHeader = defaultSessionHeader
+		}
+		for i, stepSpec := range spec.Steps {
+			template, ok := byName[stepSpec.Route]
+			if !ok {
+				return fmt.Errorf("step %d of scenario %s refers to route %s which doesn't exist", i, spec.Name, stepSpec.Route)
+			}
+			thinkSpec := spec.ThinkTime
+			if stepSpec.ThinkTime != nil {
+				thinkSpec = stepSpec.ThinkTime
+			}
+			think, err := thinkTime(thinkSpec)
+			if err != nil {
+				return fmt.Errorf("step %d of scenario %s: %w", i, spec.Name, err)
+			}
+			s.steps = append(s.steps, step{template: template, think: think})
+		}
+		total += s.weight
+		list = append(list, s)
+	}
+	if len(scenarios) > 0 && total == 0 {
+		return fmt.Errorf("all the scenarios have a weight of zero")
+	}
+	t.scenarios = list
+	return nil
+}
+
+// CheckScenarios fails if any of the scenarios is invalid for the route templates
+func CheckScenarios(templates []microsimv1alpha1.RouteTemplate, scenarios []microsimv1alpha1.Scenario) error {
+	t := &Target{}
+	for _, tmpl := range templates {
+		t.templates = append(t.templates, template{name: tmpl.Name, weight: tmpl.Weight})
+	}
+	return t.SetScenarios(scenarios)
+}
+
+// Journey starts the next journey, the scenarios are picked by weight like the route templates.
+// It returns nil without scenarios
+func (t *Target) Journey() Journey {
+	if len(t.scenarios) == 0 {
+		return nil
+	}
+	s, sequence := t.pickScenario()
+	rng := requestRand(t.seed, sequence)
+	// The session is drawn from the journey so a run with the same seed uses the same sessions
+	session, err := uuid.NewRandomFromReader(rng)
+	if err != nil {
+		session = uuid.New()
+	}
+	return &journey{
+		target:   t,
+		scenario: s,
+		sequence: sequence,
+		rng:      rng,
+		header:   http.Header{s.sessionHeader: []string{session.String()}},
+	}
+}
+
+// pickScenario returns the next scenario by smooth weighted round robin with the number of the journey
+func (t *Target) pickScenario() (*scenario, uint64) {
+	t.picking.Lock()
+	defer t.picking.Unlock()
+
+	var picked *scenario
+	total := 0
+	for i := range t.scenarios {
+		s := &t.scenarios[i]
+		s.current += s.weight
+		total += s.weight
+		if picked == nil || s.current > picked.current {
+			picked = s
+		}
+	}
+	picked.current -= total
+	t.sequence++
+	return picked, t.sequence - 1
+}
+
+// journey is a virtual user going through the steps of a scenario, the routes and think times are
+// drawn one after another from the random source of the journey
+type journey struct {
+	target   *Target
+	scenario *scenario
+	sequence uint64
+	rng      *rand.Rand
+	header   http.Header
+	step     int
+}
+
+func (j *journey) Next(ctx context.Context) (Result, time.Duration, bool) {
+	if j.step >= len(j.scenario.steps) {
+		return Result{}, 0, false
+	}
+	s := j.scenario.steps[j.step]
+	result := j.target.send(ctx, &j.target.templates[s.template], j.sequence, j.rng, j.header)
+	result.Scenario = j.scenario.name
+	result.Step = j.step
+
+	j.step++
+	if j.step == len(j.scenario.steps) {
+		return result, 0, true
+	}
+	return result, s.think.draw(j.rng), true
+}
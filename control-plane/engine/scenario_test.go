@@ -0,0 +1,198 @@
+package engine
+
+import (
+	"context"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	microsimv1alpha1 "github.com/MrSupiri/MicroSim/api/v1alpha1"
+)
+
+func TestThinkTimeDraw(t *testing.T) {
+	tests := []struct {
+		name      string
+		think     ThinkTime
+		min, max  time.Duration
+		wantMean  time.Duration
+		tolerance time.Duration
+	}{
+		{name: "none", think: ThinkTime{}, min: 0, max: 0},
+		{name: "constant", think: ThinkTime{Mean: time.Second}, min: time.Second, max: time.Second, wantMean: time.Second},
+		{
+			name:  "uniform",
+			think: ThinkTime{Distribution: microsimv1alpha1.UniformThinkTime, Min: time.Second, Max: 3 * time.Second},
+			min:   time.Second, max: 3 * time.Second, wantMean: 2 * time.Second, tolerance: 100 * time.Millisecond,
+		},
+		{
+			name:  "exponential",
+			think: ThinkTime{Distribution: microsimv1alpha1.ExponentialThinkTime, Mean: time.Second},
+			min:   0, max: time.Hour, wantMean: time.Second, tolerance: 100 * time.Millisecond,
+		},
+		{
+			name:  "exponential bounded",
+			think: ThinkTime{Distribution: microsimv1alpha1.ExponentialThinkTime, Mean: time.Second, Min: 500 * time.Millisecond, Max: 2 * time.Second},
+			min:   500 * time.Millisecond, max: 2 * time.Second,
+		},
+		{
+			// A normal draw below zero doesn't wait rather than waiting a negative time
+			name:  "normal",
+			think: ThinkTime{Distribution: microsimv1alpha1.NormalThinkTime, Mean: time.Second, StdDev: time.Second},
+			min:   0, max: time.Hour,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rng := rand.New(rand.NewSource(1))
+			const draws = 2000
+			var total time.Duration
+			for i := 0; i < draws; i++ {
+				d := tt.think.draw(rng)
+				if d < tt.min || d > tt.max {
+					t.Fatalf("draw() = %v, want within [%v, %v]", d, tt.min, tt.max)
+				}
+				total += d
+			}
+			if tt.wantMean == 0 {
+				return
+			}
+			if mean := total / draws; mean < tt.wantMean-tt.tolerance || mean > tt.wantMean+tt.tolerance {
+				t.Errorf("mean of the draws = %v, want %v", mean, tt.wantMean)
+			}
+		})
+	}
+}
+
+func TestThinkTime(t *testing.T) {
+	duration := func(d time.Duration) *metav1.Duration { return &metav1.Duration{Duration: d} }
+	tests := []struct {
+		name string
+		spec *microsimv1alpha1.ThinkTime
+		err  string
+	}{
+		{name: "none"},
+		{name: "constant", spec: &microsimv1alpha1.ThinkTime{Mean: duration(time.Second)}},
+		{name: "negative", spec: &microsimv1alpha1.ThinkTime{Mean: duration(-time.Second)}, err: "can not be negative"},
+		{name: "max below min", spec: &microsimv1alpha1.ThinkTime{Min: duration(2 * time.Second), Max: duration(time.Second)}, err: "is below min"},
+		{name: "uniform without max", spec: &microsimv1alpha1.ThinkTime{Distribution: microsimv1alpha1.UniformThinkTime}, err: "needs a max"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := thinkTime(tt.spec)
+			if (err == nil) != (tt.err == "") || (err != nil && !strings.Contains(err.Error(), tt.err)) {
+				t.Errorf("thinkTime() error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestCheckScenarios(t *testing.T) {
+	templates := []microsimv1alpha1.RouteTemplate{{Name: "browse", Weight: 1}, {Name: "buy", Weight: 1}}
+	tests := []struct {
+		name      string
+		scenarios []microsimv1alpha1.Scenario
+		err       string
+	}{
+		{name: "no scenarios"},
+		{
+			name:      "valid",
+			scenarios: []microsimv1alpha1.Scenario{{Name: "purchase", Weight: 1, Steps: []microsimv1alpha1.ScenarioStep{{Route: "browse"}, {Route: "buy"}}}},
+		},
+		{
+			name:      "unknown route",
+			scenarios: []microsimv1alpha1.Scenario{{Name: "purchase", Weight: 1, Steps: []microsimv1alpha1.ScenarioStep{{Route: "checkout"}}}},
+			err:       "refers to route checkout",
+		},
+		{
+			name:      "no steps",
+			scenarios: []microsimv1alpha1.Scenario{{Name: "purchase", Weight: 1}},
+			err:       "has no steps",
+		},
+		{
+			name:      "all weights zero",
+			scenarios: []microsimv1alpha1.Scenario{{Name: "purchase", Steps: []microsimv1alpha1.ScenarioStep{{Route: "browse"}}}},
+			err:       "weight of zero",
+		},
+		{
+			name: "invalid think time of a step",
+			scenarios: []microsimv1alpha1.Scenario{{Name: "purchase", Weight: 1, Steps: []microsimv1alpha1.ScenarioStep{
+				{Route: "browse", ThinkTime: &microsimv1alpha1.ThinkTime{Distribution: microsimv1alpha1.UniformThinkTime}},
+			}}},
+			err: "step 0 of scenario purchase",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckScenarios(templates, tt.scenarios)
+			if (err == nil) != (tt.err == "") || (err != nil && !strings.Contains(err.Error(), tt.err)) {
+				t.Errorf("CheckScenarios() error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+// roundTripper answers every request with 200 and keeps the requests
+type roundTripper struct {
+	mu       sync.Mutex
+	requests []*http.Request
+}
+
+func (rt *roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	rt.requests = append(rt.requests, req)
+	return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader("{}")), Request: req}, nil
+}
+
+func TestJourney(t *testing.T) {
+	target := newTestTarget(t, map[string]int{"a": 1, "b": 1})
+	rt := &roundTripper{}
+	target.client = &http.Client{Transport: rt}
+	err := target.SetScenarios([]microsimv1alpha1.Scenario{{
+		Name:      "ab",
+		Weight:    1,
+		Steps:     []microsimv1alpha1.ScenarioStep{{Route: "a"}, {Route: "b"}},
+		ThinkTime: &microsimv1alpha1.ThinkTime{Mean: &metav1.Duration{Duration: time.Second}},
+	}})
+	if err != nil {
+		t.Fatalf("SetScenarios() error = %v", err)
+	}
+
+	j := target.Journey()
+	var thinks []time.Duration
+	for step := 0; ; step++ {
+		result, think, ok := j.Next(context.Background())
+		if !ok {
+			break
+		}
+		if result.Err != nil || result.Scenario != "ab" || result.Step != step || result.Sequence != 0 {
+			t.Errorf("step %d = %+v, want step %d of journey 0 of ab", step, result, step)
+		}
+		thinks = append(thinks, think)
+	}
+	if len(thinks) != 2 || thinks[0] != time.Second || thinks[1] != 0 {
+		t.Errorf("think times = %v, want 1s after the first step and none after the last", thinks)
+	}
+	if len(rt.requests) != 2 {
+		t.Fatalf("sent %d requests, want 2", len(rt.requests))
+	}
+	session := rt.requests[0].Header.Get(defaultSessionHeader)
+	if session == "" || rt.requests[1].Header.Get(defaultSessionHeader) != session {
+		t.Errorf("sessions = %q, %q, want the same session on every step", session, rt.requests[1].Header.Get(defaultSessionHeader))
+	}
+	if next := target.Journey().(*journey); next.sequence != 1 || next.header.Get(defaultSessionHeader) == session {
+		t.Errorf("next journey %d has session %s, want journey 1 with a new session", next.sequence, next.header.Get(defaultSessionHeader))
+	}
+}
+
+func TestJourneyWithoutScenarios(t *testing.T) {
+	if j := newTestTarget(t, map[string]int{"a": 1}).Journey(); j != nil {
+		t.Errorf("Journey() = %v, want nil", j)
+	}
+}
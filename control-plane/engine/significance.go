@@ -0,0 +1,85 @@
+package engine
+
+import "math"
+
+// Test is the outcome of a two sided significance test between a baseline and a candidate
+type Test struct {
+	// Z is positive when the candidate is larger than the baseline
+	Z float64
+	// P is the probability of a difference at least this large when there is none
+	P float64
+}
+
+// Significant returns whether the difference holds at the significance level alpha, like 0.05
+func (t Test) Significant(alpha float64) bool {
+	return t.P < alpha
+}
+
+// MannWhitney compares the latencies of two histograms with a Mann-Whitney U test, values in the same
+// bucket are ties. The normal approximation is used, which holds for the number of requests of a run
+func MannWhitney(baseline Histogram, candidate Histogram) Test {
+	n1, n2 := float64(baseline.Total), float64(candidate.Total)
+	if n1 == 0 || n2 == 0 {
+		return Test{P: 1}
+	}
+	n := n1 + n2
+
+	// Rank sum of the candidate, ties share the mean of their ranks
+	ranked, rankSum, ties := 0.0, 0.0, 0.0
+	buckets := len(baseline.Counts)
+	if len(candidate.Counts) > buckets {
+		buckets = len(candidate.Counts)
+	}
+	for i := 0; i < buckets; i++ {
+		a, b := countAt(baseline, i), countAt(candidate, i)
+		t := a + b
+		if t == 0 {
+			continue
+		}
+		rankSum += b * (ranked + (t+1)/2)
+		ranked += t
+		ties += t*t*t - t
+	}
+
+	u := rankSum - n2*(n2+1)/2
+	mean := n1 * n2 / 2
+	variance := n1 * n2 / 12 * ((n + 1) - ties/(n*(n-1)))
+	if variance <= 0 {
+		return Test{P: 1}
+	}
+	// Continuity correction towards the mean
+	diff := u - mean
+	switch {
+	case diff > 0.5:
+		diff -= 0.5
+	case diff < -0.5:
+		diff += 0.5
+	default:
+		diff = 0
+	}
+	z := diff / math.Sqrt(variance)
+	return Test{Z: z, P: math.Erfc(math.Abs(z) / math.Sqrt2)}
+}
+
+// TwoProportions compares the share of failed requests of two runs with a pooled two proportion z-test
+func TwoProportions(baselineFailed uint64, baselineTotal uint64, candidateFailed uint64, candidateTotal uint64) Test {
+	if baselineTotal == 0 || candidateTotal == 0 {
+		return Test{P: 1}
+	}
+	n1, n2 := float64(baselineTotal), float64(candidateTotal)
+	p1, p2 := float64(baselineFailed)/n1, float64(candidateFailed)/n2
+	pooled := float64(baselineFailed+candidateFailed) / (n1 + n2)
+	se := math.Sqrt(pooled * (1 - pooled) * (1/n1 + 1/n2))
+	if se == 0 {
+		return Test{P: 1}
+	}
+	z := (p2 - p1) / se
+	return Test{Z: z, P: math.Erfc(math.Abs(z) / math.Sqrt2)}
+}
+
+func countAt(h Histogram, i int) float64 {
+	if i < len(h.Counts) {
+		return float64(h.Counts[i])
+	}
+	return 0
+}
@@ -0,0 +1,189 @@
+package engine
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// micros records every value in microseconds, values under 128µs have a bucket of their own
+func micros(values ...int) Histogram {
+	var h Histogram
+	for _, v := range values {
+		h.Record(time.Duration(v) * time.Microsecond)
+	}
+	return h
+}
+
+func TestMannWhitney(t *testing.T) {
+	tests := []struct {
+		name      string
+		baseline  Histogram
+		candidate Histogram
+		z         float64
+		p         float64
+	}{
+		{
+			// U = 25 of a possible 25, the normal approximation with continuity correction of n1 = n2 = 5
+			name:      "no overlap",
+			baseline:  micros(1, 2, 3, 4, 5),
+			candidate: micros(6, 7, 8, 9, 10),
+			z:         2.5067182457620487,
+			p:         0.012185780355344818,
+		},
+		{
+			name:      "candidate faster",
+			baseline:  micros(6, 7, 8, 9, 10),
+			candidate: micros(1, 2, 3, 4, 5),
+			z:         -2.5067182457620487,
+			p:         0.012185780355344818,
+		},
+		{
+			// Ranks 1, 3, 3, 3, 6, 6, 6, 8, the candidate's sum to 23 so U = 13, the tie correction is
+			// 48 / (8 * 7) and the variance 16 / 12 * (9 - 48 / 56)
+			name:      "ties",
+			baseline:  micros(1, 2, 2, 3),
+			candidate: micros(2, 3, 3, 4),
+			z:         1.365698202000489,
+			p:         0.17203370892182296,
+		},
+		{
+			name:      "equal samples",
+			baseline:  micros(1, 2, 3, 4, 5, 6, 7, 8, 9, 10),
+			candidate: micros(1, 2, 3, 4, 5, 6, 7, 8, 9, 10),
+			z:         0,
+			p:         1,
+		},
+		{
+			// 1000µs to 1007µs share a bucket
+			name:      "values in one bucket are ties",
+			baseline:  micros(1000, 1001, 1002),
+			candidate: micros(1005, 1006, 1007),
+			z:         0,
+			p:         1,
+		},
+		{
+			name:      "all values tied",
+			baseline:  micros(5, 5, 5),
+			candidate: micros(5, 5),
+			z:         0,
+			p:         1,
+		},
+		{
+			name:      "empty baseline",
+			baseline:  Histogram{},
+			candidate: micros(1, 2, 3),
+			z:         0,
+			p:         1,
+		},
+		{
+			name:      "empty candidate",
+			baseline:  micros(1, 2, 3),
+			candidate: Histogram{},
+			z:         0,
+			p:         1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := MannWhitney(tt.baseline, tt.candidate)
+			if math.Abs(got.Z-tt.z) > 1e-9 || math.Abs(got.P-tt.p) > 1e-9 {
+				t.Errorf("MannWhitney() = z %v p %v, want z %v p %v", got.Z, got.P, tt.z, tt.p)
+			}
+		})
+	}
+}
+
+func TestTwoProportions(t *testing.T) {
+	tests := []struct {
+		name                            string
+		baselineFailed, baselineTotal   uint64
+		candidateFailed, candidateTotal uint64
+		z                               float64
+		p                               float64
+	}{
+		{
+			// Pooled proportion 0.25, standard error sqrt(0.25 * 0.75 * (1/200 + 1/200))
+			name:           "textbook",
+			baselineFailed: 40, baselineTotal: 200,
+			candidateFailed: 60, candidateTotal: 200,
+			z: 2.3094010767585034,
+			p: 0.020921335337794028,
+		},
+		{
+			name:           "candidate better",
+			baselineFailed: 60, baselineTotal: 200,
+			candidateFailed: 40, candidateTotal: 200,
+			z: -2.3094010767585034,
+			p: 0.020921335337794028,
+		},
+		{
+			name:           "unequal sizes",
+			baselineFailed: 20, baselineTotal: 1000,
+			candidateFailed: 25, candidateTotal: 500,
+			z: 3.210806495339678,
+			p: 0.0013236303223317064,
+		},
+		{
+			name:           "equal rates",
+			baselineFailed: 10, baselineTotal: 100,
+			candidateFailed: 20, candidateTotal: 200,
+			z: 0,
+			p: 1,
+		},
+		{
+			name:           "no failures",
+			baselineFailed: 0, baselineTotal: 100,
+			candidateFailed: 0, candidateTotal: 100,
+			z: 0,
+			p: 1,
+		},
+		{
+			name:           "every request failed",
+			baselineFailed: 100, baselineTotal: 100,
+			candidateFailed: 50, candidateTotal: 50,
+			z: 0,
+			p: 1,
+		},
+		{
+			name:           "empty baseline",
+			baselineFailed: 0, baselineTotal: 0,
+			candidateFailed: 5, candidateTotal: 10,
+			z: 0,
+			p: 1,
+		},
+		{
+			name:           "empty candidate",
+			baselineFailed: 5, baselineTotal: 10,
+			candidateFailed: 0, candidateTotal: 0,
+			z: 0,
+			p: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := TwoProportions(tt.baselineFailed, tt.baselineTotal, tt.candidateFailed, tt.candidateTotal)
+			if math.Abs(got.Z-tt.z) > 1e-9 || math.Abs(got.P-tt.p) > 1e-9 {
+				t.Errorf("TwoProportions() = z %v p %v, want z %v p %v", got.Z, got.P, tt.z, tt.p)
+			}
+		})
+	}
+}
+
+func TestSignificant(t *testing.T) {
+	tests := []struct {
+		p     float64
+		alpha float64
+		want  bool
+	}{
+		{p: 0.01, alpha: 0.05, want: true},
+		{p: 0.05, alpha: 0.05, want: false},
+		{p: 0.2, alpha: 0.05, want: false},
+		{p: 1, alpha: 0.05, want: false},
+	}
+	for _, tt := range tests {
+		if got := (Test{P: tt.p}).Significant(tt.alpha); got != tt.want {
+			t.Errorf("Test{P: %v}.Significant(%v) = %v, want %v", tt.p, tt.alpha, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,535 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// Error types results are counted by
+const (
+	ErrorTimeout    = "Timeout"
+	ErrorConnection = "Connection"
+	ErrorStatus     = "Status"
+	ErrorOther      = "Other"
+)
+
+const (
+	// throughputWindow is how far back Summary.Throughput looks
+	throughputWindow = 10 * time.Second
+	// MaxWindow is the furthest back Snapshot.Window can look
+	MaxWindow = 5 * time.Minute
+	// maxErrorMessages is the number of distinct error messages counted per service
+	maxErrorMessages = 10
+)
+
+// Latency are percentiles of a histogram
+type Latency struct {
+	P50  time.Duration
+	P90  time.Duration
+	P95  time.Duration
+	P99  time.Duration
+	Max  time.Duration
+	Mean time.Duration
+}
+
+// At returns the latency at a percentile of p50, p90, p95 or p99 with its name, p99 is used when it's not set
+func (l Latency) At(percentile string) (string, time.Duration) {
+	switch percentile {
+	case "p50":
+		return percentile, l.P50
+	case "p90":
+		return percentile, l.P90
+	case "p95":
+		return percentile, l.P95
+	}
+	return "p99", l.P99
+}
+
+// ServiceSummary is the statistics of the calls to a service, by designation
+type ServiceSummary struct {
+	Calls  uint64
+	Failed uint64
+	// Skipped counts the calls left out by the probability of the hop
+	Skipped uint64
+	// ErrorMessages counts the distinct error messages, once there are maxErrorMessages of them new ones are left out
+	ErrorMessages map[string]uint64
+	// Latency is nil unless the service reports its durations
+	Latency *Latency
+}
+
+// TemplateSummary is the statistics of the requests sent with a route template
+type TemplateSummary struct {
+	Requests uint64
+	Failed   uint64
+	Latency  Latency
+}
+
+// ScenarioSummary is the statistics of the journeys through a scenario, a journey failed when any of its requests did
+type ScenarioSummary struct {
+	Journeys uint64
+	Failed   uint64
+	// Duration is how long the journeys took from the first request to the end of the last one, think time included
+	Duration Latency
+}
+
+// Summary is a point in time view of the statistics of a run
+type Summary struct {
+	Requests  uint64
+	Succeeded uint64
+	Failed    uint64
+	// StatusClasses counts the responses by the class of their status code, like 2xx
+	StatusClasses map[string]uint64
+	// Errors counts the failed requests by ErrorTimeout, ErrorConnection, ErrorStatus or ErrorOther
+	Errors map[string]uint64
+
+	Latency Latency
+	// Services breaks the calls down by the designation in the route template
+	Services map[string]ServiceSummary
+	// Templates breaks the requests down by the name of their route template
+	Templates map[string]TemplateSummary
+	// Scenarios are the journeys that finished by the name of their scenario
+	Scenarios map[string]ScenarioSummary
+	// Throughput is the number of requests finished per second over the last throughputWindow
+	Throughput float64
+}
+
+// String returns a short one line form of the summary
+func (s Summary) String() string {
+	errorRate := 0.0
+	if s.Requests > 0 {
+		errorRate = float64(s.Failed) / float64(s.Requests) * 100
+	}
+	return fmt.Sprintf("p50=%v p99=%v max=%v errors=%.1f%% %.1freq/s",
+		s.Latency.P50.Round(time.Millisecond), s.Latency.P99.Round(time.Millisecond), s.Latency.Max.Round(time.Millisecond), errorRate, s.Throughput)
+}
+
+// Snapshot is the state of Stats, it can be stored and the snapshots of the shards of a run merged
+type Snapshot struct {
+	Latency       Histogram                    `json:"latency"`
+	Succeeded     uint64                       `json:"succeeded"`
+	Failed        uint64                       `json:"failed"`
+	StatusClasses map[string]uint64            `json:"statusClasses,omitempty"`
+	Errors        map[string]uint64            `json:"errors,omitempty"`
+	Services      map[string]*ServiceSnapshot  `json:"services,omitempty"`
+	Templates     map[string]*TemplateSnapshot `json:"templates,omitempty"`
+	Scenarios     map[string]*ScenarioSnapshot `json:"scenarios,omitempty"`
+	// Seconds holds the requests finished and failed every second of the last MaxWindow, keyed by unix time
+	Seconds map[int64]*Second `json:"seconds,omitempty"`
+}
+
+type ServiceSnapshot struct {
+	Calls    uint64            `json:"calls"`
+	Failed   uint64            `json:"failed"`
+	Skipped  uint64            `json:"skipped,omitempty"`
+	Messages map[string]uint64 `json:"messages,omitempty"`
+	Latency  Histogram         `json:"latency"`
+}
+
+type TemplateSnapshot struct {
+	// Root is the service the requests of the template are sent to, without the version
+	Root    string            `json:"root,omitempty"`
+	Failed  uint64            `json:"failed"`
+	Errors  map[string]uint64 `json:"errors,omitempty"`
+	Latency Histogram         `json:"latency"`
+}
+
+type ScenarioSnapshot struct {
+	Failed   uint64    `json:"failed"`
+	Duration Histogram `json:"duration"`
+}
+
+type Second struct {
+	Finished uint64 `json:"finished"`
+	Failed   uint64 `json:"failed"`
+	// Latency is the sum of the latencies in microseconds
+	Latency uint64 `json:"latency,omitempty"`
+}
+
+// MeanLatency returns the mean latency of the requests finished in the second
+func (s Second) MeanLatency() time.Duration {
+	if s.Finished == 0 {
+		return 0
+	}
+	return time.Duration(s.Latency/s.Finished) * time.Microsecond
+}
+
+func (s *Snapshot) record(result Result, now time.Time) {
+	s.Latency.Record(result.Latency)
+	if result.StatusCode > 0 {
+		if s.StatusClasses == nil {
+			s.StatusClasses = map[string]uint64{}
+		}
+		s.StatusClasses[fmt.Sprintf("%dxx", result.StatusCode/100)]++
+	}
+	if result.Err != nil {
+		if s.Errors == nil {
+			s.Errors = map[string]uint64{}
+		}
+		s.Errors[errorType(result)]++
+		s.Failed++
+	} else {
+		s.Succeeded++
+	}
+
+	if result.Template != "" {
+		template := s.template(result.Template)
+		template.Latency.Record(result.Latency)
+		if template.Root == "" {
+			template.Root, _ = splitDesignation(result.Route.Designation)
+		}
+		if result.Err != nil {
+			template.Failed++
+			if template.Errors == nil {
+				template.Errors = map[string]uint64{}
+			}
+			template.Errors[errorType(result)]++
+		}
+	}
+	for _, call := range result.Calls {
+		s.recordCall(call)
+	}
+
+	s.prune(now)
+	second := s.second(now.Unix())
+	second.Finished++
+	if result.Latency > 0 {
+		second.Latency += uint64(result.Latency / time.Microsecond)
+	}
+	if result.Err != nil {
+		second.Failed++
+	}
+}
+
+func (s *Snapshot) recordJourney(scenario string, duration time.Duration, failed bool) {
+	snapshot := s.scenario(scenario)
+	snapshot.Duration.Record(duration)
+	if failed {
+		snapshot.Failed++
+	}
+}
+
+// Journeys returns the number of journeys that finished through any scenario
+func (s *Snapshot) Journeys() uint64 {
+	journeys := uint64(0)
+	for _, scenario := range s.Scenarios {
+		journeys += scenario.Duration.Total
+	}
+	return journeys
+}
+
+func (s *Snapshot) recordCall(call Call) {
+	service := s.service(call.Designation)
+	if call.Skipped {
+		service.Skipped++
+		return
+	}
+	service.Calls++
+	if len(call.Errors) > 0 {
+		service.Failed++
+	}
+	for _, message := range call.Errors {
+		if _, ok := service.Messages[message]; ok || len(service.Messages) < maxErrorMessages {
+			service.Messages[message]++
+		}
+	}
+	if call.Duration != nil {
+		service.Latency.Record(*call.Duration)
+	}
+}
+
+func (s *Snapshot) service(designation string) *ServiceSnapshot {
+	if s.Services == nil {
+		s.Services = map[string]*ServiceSnapshot{}
+	}
+	service, ok := s.Services[designation]
+	if !ok {
+		service = &ServiceSnapshot{Messages: map[string]uint64{}}
+		s.Services[designation] = service
+	}
+	return service
+}
+
+func (s *Snapshot) template(name string) *TemplateSnapshot {
+	if s.Templates == nil {
+		s.Templates = map[string]*TemplateSnapshot{}
+	}
+	template, ok := s.Templates[name]
+	if !ok {
+		template = &TemplateSnapshot{}
+		s.Templates[name] = template
+	}
+	return template
+}
+
+func (s *Snapshot) scenario(name string) *ScenarioSnapshot {
+	if s.Scenarios == nil {
+		s.Scenarios = map[string]*ScenarioSnapshot{}
+	}
+	scenario, ok := s.Scenarios[name]
+	if !ok {
+		scenario = &ScenarioSnapshot{}
+		s.Scenarios[name] = scenario
+	}
+	return scenario
+}
+
+func (s *Snapshot) second(unix int64) *Second {
+	if s.Seconds == nil {
+		s.Seconds = map[int64]*Second{}
+	}
+	second, ok := s.Seconds[unix]
+	if !ok {
+		second = &Second{}
+		s.Seconds[unix] = second
+	}
+	return second
+}
+
+// prune drops the seconds older than MaxWindow
+func (s *Snapshot) prune(now time.Time) {
+	oldest := now.Add(-MaxWindow).Unix()
+	for unix := range s.Seconds {
+		if unix <= oldest {
+			delete(s.Seconds, unix)
+		}
+	}
+}
+
+// Merge adds other to the snapshot
+func (s *Snapshot) Merge(other Snapshot) {
+	s.Latency.Merge(other.Latency)
+	s.Succeeded += other.Succeeded
+	s.Failed += other.Failed
+	for class, count := range other.StatusClasses {
+		if s.StatusClasses == nil {
+			s.StatusClasses = map[string]uint64{}
+		}
+		s.StatusClasses[class] += count
+	}
+	for t, count := range other.Errors {
+		if s.Errors == nil {
+			s.Errors = map[string]uint64{}
+		}
+		s.Errors[t] += count
+	}
+	for designation, other := range other.Services {
+		service := s.service(designation)
+		service.Calls += other.Calls
+		service.Failed += other.Failed
+		service.Skipped += other.Skipped
+		for message, count := range other.Messages {
+			if _, ok := service.Messages[message]; ok || len(service.Messages) < maxErrorMessages {
+				service.Messages[message] += count
+			}
+		}
+		service.Latency.Merge(other.Latency)
+	}
+	for name, other := range other.Templates {
+		template := s.template(name)
+		if template.Root == "" {
+			template.Root = other.Root
+		}
+		template.Failed += other.Failed
+		for t, count := range other.Errors {
+			if template.Errors == nil {
+				template.Errors = map[string]uint64{}
+			}
+			template.Errors[t] += count
+		}
+		template.Latency.Merge(other.Latency)
+	}
+	for name, other := range other.Scenarios {
+		scenario := s.scenario(name)
+		scenario.Failed += other.Failed
+		scenario.Duration.Merge(other.Duration)
+	}
+	for unix, other := range other.Seconds {
+		second := s.second(unix)
+		second.Finished += other.Finished
+		second.Failed += other.Failed
+		second.Latency += other.Latency
+	}
+}
+
+// Copy returns a deep copy of the snapshot
+func (s *Snapshot) Copy() Snapshot {
+	var snapshot Snapshot
+	snapshot.Merge(*s)
+	return snapshot
+}
+
+// Window returns the number of requests that finished and failed within window before now, which is cut to MaxWindow
+func (s *Snapshot) Window(now time.Time, window time.Duration) (finished uint64, failed uint64) {
+	if window > MaxWindow {
+		window = MaxWindow
+	}
+	total := s.Between(now.Add(-window), now)
+	return total.Finished, total.Failed
+}
+
+// Between adds up the seconds after from up to and including to, only the last MaxWindow is kept
+func (s *Snapshot) Between(from time.Time, to time.Time) Second {
+	var total Second
+	for unix, second := range s.Seconds {
+		if unix > from.Unix() && unix <= to.Unix() {
+			total.Finished += second.Finished
+			total.Failed += second.Failed
+			total.Latency += second.Latency
+		}
+	}
+	return total
+}
+
+// Summary returns the statistics of the snapshot at now
+func (s *Snapshot) Summary(now time.Time) Summary {
+	finished, _ := s.Window(now, throughputWindow)
+	summary := Summary{
+		Requests:      s.Latency.Total,
+		Succeeded:     s.Succeeded,
+		Failed:        s.Failed,
+		StatusClasses: map[string]uint64{},
+		Errors:        map[string]uint64{},
+		Latency:       s.Latency.Latency(),
+		Services:      map[string]ServiceSummary{},
+		Templates:     map[string]TemplateSummary{},
+		Scenarios:     map[string]ScenarioSummary{},
+		Throughput:    float64(finished) / throughputWindow.Seconds(),
+	}
+	for class, count := range s.StatusClasses {
+		summary.StatusClasses[class] = count
+	}
+	for t, count := range s.Errors {
+		summary.Errors[t] = count
+	}
+	for designation, service := range s.Services {
+		serviceSummary := ServiceSummary{
+			Calls:         service.Calls,
+			Failed:        service.Failed,
+			Skipped:       service.Skipped,
+			ErrorMessages: map[string]uint64{},
+		}
+		for message, count := range service.Messages {
+			serviceSummary.ErrorMessages[message] = count
+		}
+		if service.Latency.Total > 0 {
+			latency := service.Latency.Latency()
+			serviceSummary.Latency = &latency
+		}
+		summary.Services[designation] = serviceSummary
+	}
+	for name, template := range s.Templates {
+		summary.Templates[name] = TemplateSummary{
+			Requests: template.Latency.Total,
+			Failed:   template.Failed,
+			Latency:  template.Latency.Latency(),
+		}
+	}
+	for name, scenario := range s.Scenarios {
+		summary.Scenarios[name] = ScenarioSummary{
+			Journeys: scenario.Duration.Total,
+			Failed:   scenario.Failed,
+			Duration: scenario.Duration.Latency(),
+		}
+	}
+	return summary
+}
+
+// Stats collects the results of a run, it is safe for concurrent use
+type Stats struct {
+	mu       sync.Mutex
+	snapshot Snapshot
+	// recent holds the latencies of every second of the last MaxWindow keyed by unix time, it's left
+	// out of the snapshot to keep it small
+	recent map[int64]*Histogram
+}
+
+func (s *Stats) Record(result Result) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	s.snapshot.record(result, now)
+
+	if s.recent == nil {
+		s.recent = map[int64]*Histogram{}
+	}
+	oldest := now.Add(-MaxWindow).Unix()
+	for unix := range s.recent {
+		if unix <= oldest {
+			delete(s.recent, unix)
+		}
+	}
+	second, ok := s.recent[now.Unix()]
+	if !ok {
+		second = &Histogram{}
+		s.recent[now.Unix()] = second
+	}
+	second.Record(result.Latency)
+}
+
+// RecentLatency returns the latencies of the requests that finished within the last window, up to MaxWindow
+func (s *Stats) RecentLatency(window time.Duration) Histogram {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if window > MaxWindow {
+		window = MaxWindow
+	}
+	now := time.Now()
+	var latency Histogram
+	for unix, second := range s.recent {
+		if unix > now.Add(-window).Unix() && unix <= now.Unix() {
+			latency.Merge(*second)
+		}
+	}
+	return latency
+}
+
+// Snapshot returns a copy of the statistics collected so far
+func (s *Stats) Snapshot() Snapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.snapshot.prune(time.Now())
+	return s.snapshot.Copy()
+}
+
+// Restore replaces the statistics with a copy of snapshot
+func (s *Stats) Restore(snapshot Snapshot) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.snapshot = snapshot.Copy()
+}
+
+// RecordJourney counts a journey that finished
+func (s *Stats) RecordJourney(scenario string, duration time.Duration, failed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.snapshot.recordJourney(scenario, duration, failed)
+}
+
+func (s *Stats) Window(window time.Duration) (finished uint64, failed uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.snapshot.Window(time.Now(), window)
+}
+
+func (s *Stats) Summary() Summary {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.snapshot.Summary(time.Now())
+}
+
+func errorType(result Result) string {
+	var netErr net.Error
+	switch {
+	case errors.Is(result.Err, context.DeadlineExceeded), errors.As(result.Err, &netErr) && netErr.Timeout():
+		return ErrorTimeout
+	case result.StatusCode >= 400:
+		return ErrorStatus
+	case result.StatusCode == 0 && errors.As(result.Err, &netErr):
+		return ErrorConnection
+	}
+	return ErrorOther
+}
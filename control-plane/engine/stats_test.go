@@ -0,0 +1,278 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	microsimv1alpha1 "github.com/MrSupiri/MicroSim/api/v1alpha1"
+)
+
+func durationPtr(d time.Duration) *time.Duration {
+	return &d
+}
+
+func TestStatsSummary(t *testing.T) {
+	var s Stats
+	for i := 1; i <= 16; i++ {
+		s.Record(Result{StatusCode: 200, Latency: time.Duration(i) * 10 * time.Millisecond})
+	}
+	s.Record(Result{StatusCode: 500, Err: errors.New("service responded with status 500"), Latency: 30 * time.Millisecond})
+	s.Record(Result{Err: context.DeadlineExceeded, Latency: time.Second})
+	s.Record(Result{Err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}, Latency: time.Millisecond})
+	s.Record(Result{Err: errors.New("failed to encode request")})
+
+	summary := s.Summary()
+	if summary.Requests != 20 || summary.Succeeded != 16 || summary.Failed != 4 {
+		t.Errorf("requests, succeeded and failed = %d, %d, %d, want 20, 16, 4", summary.Requests, summary.Succeeded, summary.Failed)
+	}
+	if want := map[string]uint64{"2xx": 16, "5xx": 1}; !reflect.DeepEqual(summary.StatusClasses, want) {
+		t.Errorf("StatusClasses = %v, want %v", summary.StatusClasses, want)
+	}
+	want := map[string]uint64{ErrorStatus: 1, ErrorTimeout: 1, ErrorConnection: 1, ErrorOther: 1}
+	if !reflect.DeepEqual(summary.Errors, want) {
+		t.Errorf("Errors = %v, want %v", summary.Errors, want)
+	}
+	if summary.Latency.Max != time.Second {
+		t.Errorf("Max = %v, want 1s", summary.Latency.Max)
+	}
+	// The 10th of the 20 latencies is 70ms, failed requests count too
+	if summary.Latency.P50 < 70*time.Millisecond || float64(summary.Latency.P50-70*time.Millisecond) > float64(70*time.Millisecond)/64 {
+		t.Errorf("P50 = %v, want 70ms within 1/64", summary.Latency.P50)
+	}
+	if want := 20 / throughputWindow.Seconds(); summary.Throughput != want {
+		t.Errorf("Throughput = %v, want %v", summary.Throughput, want)
+	}
+
+	// The summary is a copy, later results don't change it
+	s.Record(Result{StatusCode: 200})
+	if summary.StatusClasses["2xx"] != 16 {
+		t.Errorf("summary changed after a later result")
+	}
+}
+
+func TestStatsServices(t *testing.T) {
+	var s Stats
+	for i := 1; i <= 4; i++ {
+		s.Record(Result{StatusCode: 200, Calls: []Call{
+			{Designation: "front@v1", Duration: durationPtr(time.Duration(i) * 10 * time.Millisecond)},
+			{Designation: "cart"},
+		}})
+	}
+	s.Record(Result{StatusCode: 500, Err: errors.New("service responded with status 500"), Calls: []Call{
+		{Designation: "front@v1", Errors: []string{"downstream failed"}, Duration: durationPtr(50 * time.Millisecond)},
+		{Designation: "cart", Errors: []string{"card declined", "timeout"}},
+	}})
+	for i := 0; i < maxErrorMessages+2; i++ {
+		s.Record(Result{Calls: []Call{{Designation: "noisy", Errors: []string{fmt.Sprintf("error %d", i)}}}})
+	}
+
+	services := s.Summary().Services
+	front := services["front@v1"]
+	if front.Calls != 5 || front.Failed != 1 {
+		t.Errorf("front calls and failed = %d, %d, want 5, 1", front.Calls, front.Failed)
+	}
+	if front.Latency == nil || front.Latency.Max != 50*time.Millisecond {
+		t.Errorf("front latency = %+v, want a max of 50ms", front.Latency)
+	}
+	cart := services["cart"]
+	if want := map[string]uint64{"card declined": 1, "timeout": 1}; cart.Calls != 5 || cart.Failed != 1 || !reflect.DeepEqual(cart.ErrorMessages, want) {
+		t.Errorf("cart = %+v, want 5 calls, 1 failed and messages %v", cart, want)
+	}
+	if cart.Latency != nil {
+		t.Errorf("cart latency = %+v, want none as it doesn't report durations", cart.Latency)
+	}
+	if got := len(services["noisy"].ErrorMessages); got != maxErrorMessages {
+		t.Errorf("noisy has %d error messages, want %d", got, maxErrorMessages)
+	}
+}
+
+func TestStatsTemplates(t *testing.T) {
+	now := time.Unix(1000, 0)
+	var s Snapshot
+	for _, r := range results(now) {
+		s.record(r.result, r.at)
+	}
+
+	templates := s.Summary(now).Templates
+	if browse := templates["browse"]; browse.Requests != 16 || browse.Failed != 0 || browse.Latency.Max != 160*time.Millisecond {
+		t.Errorf("browse = %+v, want 16 requests, none failed, a max of 160ms", browse)
+	}
+	if checkout := templates["checkout"]; checkout.Requests != 3 || checkout.Failed != 3 || checkout.Latency.Max != time.Second {
+		t.Errorf("checkout = %+v, want 3 requests, all failed, a max of 1s", checkout)
+	}
+	if len(templates) != 2 {
+		t.Errorf("templates = %v, want only browse and checkout as requests without a template aren't broken down", templates)
+	}
+}
+
+func TestSnapshotTemplateErrors(t *testing.T) {
+	now := time.Unix(1000, 0)
+	var a, b Snapshot
+	a.record(Result{Template: "checkout", Route: microsimv1alpha1.Route{Designation: "cart@v2"}, StatusCode: 200}, now)
+	a.record(Result{Template: "checkout", Route: microsimv1alpha1.Route{Designation: "cart@v2"}, Err: context.DeadlineExceeded}, now)
+	b.record(Result{Template: "checkout", Route: microsimv1alpha1.Route{Designation: "cart@v2"}, StatusCode: 500, Err: errors.New("service responded with status 500")}, now)
+	b.record(Result{Template: "checkout", Route: microsimv1alpha1.Route{Designation: "cart@v2"}, Err: context.DeadlineExceeded}, now)
+	a.Merge(b)
+
+	checkout := a.Templates["checkout"]
+	if checkout.Root != "cart" {
+		t.Errorf("root = %s, want the service without its version", checkout.Root)
+	}
+	if want := map[string]uint64{ErrorTimeout: 2, ErrorStatus: 1}; checkout.Failed != 3 || !reflect.DeepEqual(checkout.Errors, want) {
+		t.Errorf("checkout failed %d with errors %v, want 3 with %v", checkout.Failed, checkout.Errors, want)
+	}
+}
+
+// results are the requests of a run, one every 100ms from now
+func results(now time.Time) []struct {
+	result Result
+	at     time.Time
+} {
+	ok := func(latency time.Duration) Result {
+		return Result{Template: "browse", StatusCode: 200, Latency: latency,
+			Calls: []Call{{Designation: "front@v1", Duration: durationPtr(latency)}}}
+	}
+	failed := []Result{
+		{Template: "checkout", StatusCode: 500, Err: errors.New("service responded with status 500"), Latency: 30 * time.Millisecond,
+			Calls: []Call{{Designation: "payment", Errors: []string{"card declined"}}}},
+		{Template: "checkout", Err: context.DeadlineExceeded, Latency: time.Second},
+		{Template: "checkout", Err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}, Latency: time.Millisecond},
+		{Err: errors.New("failed to encode request")},
+	}
+
+	var all []struct {
+		result Result
+		at     time.Time
+	}
+	add := func(result Result) {
+		all = append(all, struct {
+			result Result
+			at     time.Time
+		}{result, now.Add(time.Duration(len(all)) * 100 * time.Millisecond)})
+	}
+	for i := 1; i <= 16; i++ {
+		add(ok(time.Duration(i) * 10 * time.Millisecond))
+	}
+	for _, result := range failed {
+		add(result)
+	}
+	return all
+}
+
+func TestSnapshotMerge(t *testing.T) {
+	now := time.Unix(1000, 0)
+	var all, even, odd Snapshot
+	for i, r := range results(now) {
+		all.record(r.result, r.at)
+		if i%2 == 0 {
+			even.record(r.result, r.at)
+		} else {
+			odd.record(r.result, r.at)
+		}
+	}
+
+	var merged Snapshot
+	merged.Merge(even)
+	merged.Merge(odd)
+	at := now.Add(2 * time.Second)
+	if got, want := merged.Summary(at), all.Summary(at); !reflect.DeepEqual(got, want) {
+		t.Errorf("merged summary = %+v\nwant %+v", got, want)
+	}
+	if !reflect.DeepEqual(merged.Seconds, all.Seconds) {
+		t.Errorf("merged seconds = %v, want %v", merged.Seconds, all.Seconds)
+	}
+
+	copied := merged.Copy()
+	copied.Merge(even)
+	if merged.Latency.Total != all.Latency.Total || merged.Services["front@v1"].Calls != 16 {
+		t.Errorf("changing a copy changed the snapshot")
+	}
+}
+
+func TestSnapshotWindow(t *testing.T) {
+	now := time.Unix(1000, 0)
+	var s Snapshot
+	for _, r := range results(now) {
+		s.record(r.result, r.at)
+	}
+	tests := []struct {
+		at       time.Time
+		window   time.Duration
+		finished uint64
+		failed   uint64
+	}{
+		// The requests finish at 1000s to 1001.9s, the first 10 in second 1000. A window takes in the
+		// seconds after at-window up to and including at
+		{at: now, window: time.Second, finished: 10, failed: 0},
+		{at: now.Add(time.Second), window: time.Second, finished: 10, failed: 4},
+		{at: now.Add(2 * time.Second), window: time.Second, finished: 0, failed: 0},
+		{at: now.Add(2 * time.Second), window: 5 * time.Second, finished: 20, failed: 4},
+		{at: now.Add(10 * time.Second), window: 5 * time.Second, finished: 0, failed: 0},
+		{at: now.Add(time.Minute), window: time.Hour, finished: 20, failed: 4},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%v at %v", tt.window, tt.at.Sub(now)), func(t *testing.T) {
+			finished, failed := s.Window(tt.at, tt.window)
+			if finished != tt.finished || failed != tt.failed {
+				t.Errorf("Window() = %d, %d, want %d, %d", finished, failed, tt.finished, tt.failed)
+			}
+		})
+	}
+
+	// Seconds older than MaxWindow are dropped
+	s.prune(now.Add(MaxWindow))
+	if len(s.Seconds) != 1 {
+		t.Errorf("%d seconds kept after pruning, want the last one", len(s.Seconds))
+	}
+}
+
+func TestLatencyAt(t *testing.T) {
+	latency := Latency{P50: 1, P90: 2, P95: 3, P99: 4}
+	tests := []struct {
+		percentile string
+		name       string
+		want       time.Duration
+	}{
+		{percentile: "p50", name: "p50", want: 1},
+		{percentile: "p90", name: "p90", want: 2},
+		{percentile: "p95", name: "p95", want: 3},
+		{percentile: "p99", name: "p99", want: 4},
+		{percentile: "", name: "p99", want: 4},
+	}
+	for _, tt := range tests {
+		if name, got := latency.At(tt.percentile); name != tt.name || got != tt.want {
+			t.Errorf("At(%q) = %s, %v, want %s, %v", tt.percentile, name, got, tt.name, tt.want)
+		}
+	}
+}
+
+func TestSummaryString(t *testing.T) {
+	s := Summary{Requests: 200, Failed: 3, Latency: Latency{P50: 12300 * time.Microsecond, P99: 98 * time.Millisecond, Max: 1234 * time.Millisecond}, Throughput: 19.96}
+	if got, want := s.String(), "p50=12ms p99=98ms max=1.234s errors=1.5% 20.0req/s"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
+
+func TestStatsRecentLatency(t *testing.T) {
+	var s Stats
+	for i := 1; i <= 10; i++ {
+		s.Record(Result{Latency: time.Duration(i) * time.Millisecond})
+	}
+	// A second that fell out of MaxWindow is left out
+	s.mu.Lock()
+	old := millis(1000)
+	s.recent[time.Now().Unix()-int64(MaxWindow/time.Second)-1] = &old
+	s.mu.Unlock()
+
+	if recent := s.RecentLatency(time.Hour); recent.Total != 10 || recent.MaxLatency() != 10*time.Millisecond {
+		t.Errorf("RecentLatency() has %d requests up to %v, want 10 up to 10ms", recent.Total, recent.MaxLatency())
+	}
+	if recent := s.RecentLatency(0); recent.Total != 0 {
+		t.Errorf("RecentLatency(0) has %d requests, want none", recent.Total)
+	}
+}
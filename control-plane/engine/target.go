@@ -0,0 +1,404 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	microsimv1alpha1 "github.com/MrSupiri/MicroSim/api/v1alpha1"
+)
+
+// Target sends the route templates of a load generator to the services of a simulation
+type Target struct {
+	mu         sync.RWMutex
+	simulation microsimv1alpha1.Simulation
+	templates  []template
+	scenarios  []scenario
+	client     *http.Client
+	// seed of the sampled routes and versions, together with the sequence number of a request
+	seed int64
+	// logged are the problems of the routes that were logged already
+	logged map[string]bool
+
+	// picking holds the current weights of the templates and the scenarios and the sequence while one is picked
+	picking  sync.Mutex
+	sequence uint64
+}
+
+type template struct {
+	name   string
+	route  microsimv1alpha1.Route
+	weight int
+	// current is the running weight of the smooth weighted round robin
+	current int
+}
+
+// NewTarget expands the route templates, it fails if any of them is invalid. Requests sent with the
+// same seed sample the same routes
+func NewTarget(ctx context.Context, simulation microsimv1alpha1.Simulation, templates []microsimv1alpha1.RouteTemplate, seed int64) (*Target, error) {
+	if err := CheckTemplates(templates); err != nil {
+		return nil, err
+	}
+
+	t := &Target{
+		seed: seed,
+		client: &http.Client{
+			Transport: &http.Transport{DisableKeepAlives: true},
+		},
+		logged: map[string]bool{},
+	}
+	for _, tmpl := range templates {
+		route, err := tmpl.Route()
+		if err != nil {
+			return nil, err
+		}
+		t.templates = append(t.templates, template{name: tmpl.Name, route: route, weight: tmpl.Weight})
+	}
+	t.SetSimulation(ctx, simulation)
+	return t, nil
+}
+
+// CheckTemplates fails if any of the route templates is invalid or none of them would be sent
+func CheckTemplates(templates []microsimv1alpha1.RouteTemplate) error {
+	if len(templates) == 0 {
+		return fmt.Errorf("no routes to send")
+	}
+	total := 0
+	for _, tmpl := range templates {
+		if _, err := tmpl.Route(); err != nil {
+			return err
+		}
+		if tmpl.Weight < 0 {
+			return fmt.Errorf("route template %s has a negative weight", tmpl.Name)
+		}
+		total += tmpl.Weight
+	}
+	if total == 0 {
+		return fmt.Errorf("all the route templates have a weight of zero")
+	}
+	return nil
+}
+
+// NewReplayTarget returns a target without route templates, it only sends recorded requests with Replay
+func NewReplayTarget(simulation microsimv1alpha1.Simulation, seed int64) *Target {
+	return &Target{
+		simulation: simulation,
+		seed:       seed,
+		client: &http.Client{
+			Transport: &http.Transport{DisableKeepAlives: true},
+		},
+		logged: map[string]bool{},
+	}
+}
+
+// SetSimulation replaces the simulation used to look up the service endpoints, the problems of the
+// routes in it are logged once rather than on every request
+func (t *Target) SetSimulation(ctx context.Context, simulation microsimv1alpha1.Simulation) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.simulation = simulation
+	for _, tmpl := range t.templates {
+		t.logProblems(ctx, simulation, tmpl.route)
+	}
+}
+
+// logProblems logs the problems of route that weren't logged yet, t.mu has to be held
+func (t *Target) logProblems(ctx context.Context, simulation microsimv1alpha1.Simulation, route microsimv1alpha1.Route) {
+	logger := log.FromContext(ctx)
+	for _, p := range routeProblems(simulation, route) {
+		if key := fmt.Sprint(p.message, p.keysAndValues); !t.logged[key] {
+			t.logged[key] = true
+			logger.V(-1).Info(p.message, p.keysAndValues...)
+		}
+	}
+}
+
+// Send sends the next route template, the templates are picked by weight
+func (t *Target) Send(ctx context.Context) Result {
+	tmpl, sequence := t.pick()
+	return t.send(ctx, tmpl, sequence, requestRand(t.seed, sequence), nil)
+}
+
+// send sends a route template with the routes drawn from rng, header is added to the request
+func (t *Target) send(ctx context.Context, tmpl *template, sequence uint64, rng *rand.Rand, header http.Header) Result {
+	logger := log.FromContext(ctx)
+	t.mu.RLock()
+	simulation := t.simulation
+	t.mu.RUnlock()
+	route, sent := overwriteDesignations(simulation, tmpl.route, rng)
+	logger.V(1).Info("sending request", "designation", route.Designation, "sequence", sequence)
+
+	result := t.post(ctx, route, header)
+	result.Template = tmpl.name
+	result.Sequence = sequence
+	result.Calls = parseCalls(sent, result)
+	result.Decisions = decisions(sent)
+	result.Route = sampledRoute(tmpl.route, sent)
+	for name := range header {
+		if result.Header == nil {
+			result.Header = map[string]string{}
+		}
+		result.Header[name] = header.Get(name)
+	}
+	return result
+}
+
+// Replay sends a recorded request again, the services are looked up in the current simulation so the
+// same request tree reaches the services of another deployment
+func (t *Target) Replay(ctx context.Context, recorded Recorded) Result {
+	tmpl := &template{name: recorded.Template, route: recorded.Route}
+	// The recorded routes aren't known up front, their problems are logged the first time they're sent
+	t.mu.Lock()
+	t.logProblems(ctx, t.simulation, recorded.Route)
+	t.mu.Unlock()
+	header := http.Header{}
+	for name, value := range recorded.Header {
+		header.Set(name, value)
+	}
+	result := t.send(ctx, tmpl, recorded.Sequence, requestRand(t.seed, recorded.Sequence), header)
+	result.Scenario = recorded.Scenario
+	result.Step = recorded.Step
+	return result
+}
+
+// Resume continues the sequence of a run after sent requests, or journeys with scenarios, the ones
+// that follow pick the same templates and routes as they would have had the run never stopped
+func (t *Target) Resume(sent uint64) {
+	total := uint64(0)
+	pick := func() { t.pick() }
+	for _, tmpl := range t.templates {
+		total += uint64(tmpl.weight)
+	}
+	if len(t.scenarios) > 0 {
+		total, pick = 0, func() { t.pickScenario() }
+		for _, s := range t.scenarios {
+			total += uint64(s.weight)
+		}
+	}
+	// The round robin starts over every total picks
+	for i := uint64(0); i < sent%total; i++ {
+		pick()
+	}
+	t.picking.Lock()
+	defer t.picking.Unlock()
+	t.sequence = sent
+}
+
+// pick returns the next template by smooth weighted round robin with the sequence number of the request,
+// every template gets its share of any run of total weight requests spread across it rather than in
+// a block. Templates with equal weights take turns
+func (t *Target) pick() (*template, uint64) {
+	t.picking.Lock()
+	defer t.picking.Unlock()
+
+	var picked *template
+	total := 0
+	for i := range t.templates {
+		tmpl := &t.templates[i]
+		tmpl.current += tmpl.weight
+		total += tmpl.weight
+		if picked == nil || tmpl.current > picked.current {
+			picked = tmpl
+		}
+	}
+	picked.current -= total
+	t.sequence++
+	return picked, t.sequence - 1
+}
+
+func (t *Target) post(ctx context.Context, route microsimv1alpha1.Route, header http.Header) Result {
+	var result Result
+	reqBody, err := json.Marshal(route)
+	if err != nil {
+		result.Err = fmt.Errorf("failed to encode request: %w", err)
+		return result
+	}
+	result.Request = reqBody
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, route.Designation, bytes.NewBuffer(reqBody))
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	req.Header = http.Header{
+		"Content-Type": []string{"application/json"},
+		"X-Request-ID": []string{uuid.New().String()},
+	}
+	for name, values := range header {
+		for _, value := range values {
+			req.Header.Add(name, value)
+		}
+	}
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	defer resp.Body.Close()
+
+	result.StatusCode = resp.StatusCode
+	if result.Response, err = ioutil.ReadAll(resp.Body); err != nil {
+		result.Err = fmt.Errorf("failed to read response: %w", err)
+		return result
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		result.Err = fmt.Errorf("service responded with status %d", resp.StatusCode)
+	}
+	return result
+}
+
+// overwriteDesignations replaces the service names in the route with their endpoints and drops the
+// routes that weren't picked by their own probability, the returned hop keeps the names. The
+// probability of the first route is left out, the request is always sent
+func overwriteDesignations(simulation microsimv1alpha1.Simulation, route microsimv1alpha1.Route, rng *rand.Rand) (microsimv1alpha1.Route, hop) {
+	var newRoutes []microsimv1alpha1.Route
+	sent := hop{designation: route.Designation}
+
+	if !strings.HasPrefix(route.Designation, "http") {
+		name, version := splitDesignation(route.Designation)
+		if svc, ok := simulation.Status.Services[simulation.ServiceName(name)]; ok {
+			route.Designation = svc.Endpoint
+			if len(svc.Versions) > 0 {
+				if version == "" {
+					version = pickVersion(svc.Versions, rng)
+				}
+				if v, ok := svc.Versions[version]; ok {
+					sent.designation = name + "@" + version
+					route.Designation = v.Endpoint
+					route.Faults = microsimv1alpha1.Faults{
+						Before: append(append([]microsimv1alpha1.Fault{}, v.Faults.Before...), route.Faults.Before...),
+						After:  append(append([]microsimv1alpha1.Fault{}, route.Faults.After...), v.Faults.After...),
+					}
+				}
+			}
+		}
+	}
+
+	for _, p := range route.Routes {
+		// A probability of 0 never calls the route and 100 always does
+		if rng.Intn(100) >= p.Probability {
+			sent.routes = append(sent.routes, hop{designation: p.Designation, skipped: true})
+			continue
+		}
+		newRoute, child := overwriteDesignations(simulation, p, rng)
+		newRoutes = append(newRoutes, newRoute)
+		sent.routes = append(sent.routes, child)
+	}
+	route.Routes = newRoutes
+	return route, sent
+}
+
+// problem is something wrong with a route that doesn't stop it from being sent
+type problem struct {
+	message       string
+	keysAndValues []interface{}
+}
+
+// routeProblems returns the services of the route and its children that aren't in the simulation,
+// the pinned versions they don't have and the faults their frameworks don't support
+func routeProblems(simulation microsimv1alpha1.Simulation, route microsimv1alpha1.Route) []problem {
+	var problems []problem
+	if !strings.HasPrefix(route.Designation, "http") {
+		name, version := splitDesignation(route.Designation)
+		if svc, ok := simulation.Status.Services[simulation.ServiceName(name)]; ok {
+			for _, fault := range unsupportedFaults(route.Faults, svc.SupportedFaults) {
+				problems = append(problems, problem{"fault is not supported by the service framework", []interface{}{"service name", route.Designation, "fault", fault}})
+			}
+			if _, ok := svc.Versions[version]; len(svc.Versions) > 0 && version != "" && !ok {
+				problems = append(problems, problem{"service version was not found, using the shared service", []interface{}{"service name", name, "version", version}})
+			}
+		} else {
+			problems = append(problems, problem{"service name was not found", []interface{}{"service name", route.Designation}})
+		}
+	}
+	for _, r := range route.Routes {
+		problems = append(problems, routeProblems(simulation, r)...)
+	}
+	return problems
+}
+
+// sampledRoute returns the route as it was sent, the skipped routes are dropped and the others are always
+// called. Services keep their names, with the version that was picked
+func sampledRoute(route microsimv1alpha1.Route, sent hop) microsimv1alpha1.Route {
+	routes := route.Routes
+	route.Designation = sent.designation
+	route.Routes = nil
+	for i, child := range sent.routes {
+		if child.skipped {
+			continue
+		}
+		r := sampledRoute(routes[i], child)
+		r.Probability = 100
+		route.Routes = append(route.Routes, r)
+	}
+	return route
+}
+
+// splitDesignation splits a <service>@<version> designation, version is empty when it's not set
+func splitDesignation(designation string) (string, string) {
+	if i := strings.LastIndex(designation, "@"); i >= 0 {
+		return designation[:i], designation[i+1:]
+	}
+	return designation, ""
+}
+
+// pickVersion picks a version by weight, an empty version is returned when all the weights are zero
+func pickVersion(versions map[string]microsimv1alpha1.ServiceVersionStatus, rng *rand.Rand) string {
+	names := make([]string, 0, len(versions))
+	total := 0
+	for name, version := range versions {
+		names = append(names, name)
+		total += version.Weight
+	}
+	if total <= 0 {
+		return ""
+	}
+	// Map iteration order is random, sort so a given draw always picks the same version
+	sort.Strings(names)
+
+	n := rng.Intn(total)
+	for _, name := range names {
+		if n < versions[name].Weight {
+			return name
+		}
+		n -= versions[name].Weight
+	}
+	return ""
+}
+
+// unsupportedFaults returns the fault types not listed in supported, nothing is reported
+// when the framework didn't declare its supported faults
+func unsupportedFaults(faults microsimv1alpha1.Faults, supported []string) []string {
+	var unsupported []string
+	if len(supported) == 0 {
+		return unsupported
+	}
+	for _, list := range [][]microsimv1alpha1.Fault{faults.Before, faults.After} {
+		for _, fault := range list {
+			if !containsString(supported, fault.Type) {
+				unsupported = append(unsupported, fault.Type)
+			}
+		}
+	}
+	return unsupported
+}
+
+func containsString(slice []string, s string) bool {
+	for _, item := range slice {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,400 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	microsimv1alpha1 "github.com/MrSupiri/MicroSim/api/v1alpha1"
+)
+
+// testSimulation returns a simulation without services, services are named after its UID
+func testSimulation() microsimv1alpha1.Simulation {
+	var simulation microsimv1alpha1.Simulation
+	simulation.UID = "0123456789"
+	return simulation
+}
+
+func newTestTarget(t *testing.T, weights map[string]int) *Target {
+	t.Helper()
+	var templates []microsimv1alpha1.RouteTemplate
+	for _, name := range []string{"a", "b", "c"} {
+		if weight, ok := weights[name]; ok {
+			templates = append(templates, microsimv1alpha1.RouteTemplate{
+				Name:   name,
+				Hops:   []microsimv1alpha1.Hop{{Designation: name}},
+				Weight: weight,
+			})
+		}
+	}
+	target, err := NewTarget(context.Background(), testSimulation(), templates, 1)
+	if err != nil {
+		t.Fatalf("NewTarget() error = %v", err)
+	}
+	return target
+}
+
+// picks returns the names of the next n templates picked by target
+func picks(target *Target, n int) string {
+	var names []string
+	for i := 0; i < n; i++ {
+		tmpl, _ := target.pick()
+		names = append(names, tmpl.name)
+	}
+	return strings.Join(names, "")
+}
+
+func TestTargetPick(t *testing.T) {
+	tests := []struct {
+		name    string
+		weights map[string]int
+		want    string
+	}{
+		{name: "single template", weights: map[string]int{"a": 3}, want: "aaaa"},
+		{name: "equal weights take turns", weights: map[string]int{"a": 1, "b": 1, "c": 1}, want: "abcabcabc"},
+		{name: "smooth", weights: map[string]int{"a": 5, "b": 1, "c": 1}, want: "aabacaaaabacaa"},
+		{name: "two to one", weights: map[string]int{"a": 2, "b": 1}, want: "abaaba"},
+		{name: "zero weight is never picked", weights: map[string]int{"a": 1, "b": 0, "c": 1}, want: "acacac"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := picks(newTestTarget(t, tt.weights), len(tt.want)); got != tt.want {
+				t.Errorf("picked %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTargetPickSequence(t *testing.T) {
+	target := newTestTarget(t, map[string]int{"a": 1, "b": 1})
+	for want := uint64(0); want < 5; want++ {
+		if _, sequence := target.pick(); sequence != want {
+			t.Errorf("sequence = %d, want %d", sequence, want)
+		}
+	}
+}
+
+func TestTargetResume(t *testing.T) {
+	weights := map[string]int{"a": 5, "b": 2, "c": 1}
+	want := picks(newTestTarget(t, weights), 40)
+	for _, sent := range []int{0, 1, 3, 8, 13, 30} {
+		target := newTestTarget(t, weights)
+		target.Resume(uint64(sent))
+		if got := picks(target, 10); got != want[sent:sent+10] {
+			t.Errorf("after %d requests picked %s, want %s", sent, got, want[sent:sent+10])
+		}
+		if _, sequence := target.pick(); sequence != uint64(sent+10) {
+			t.Errorf("after %d requests the sequence is %d, want %d", sent, sequence, sent+10)
+		}
+	}
+}
+
+func TestNewTarget(t *testing.T) {
+	tests := []struct {
+		name      string
+		templates []microsimv1alpha1.RouteTemplate
+		err       string
+	}{
+		{name: "no templates", err: "no routes to send"},
+		{
+			name:      "negative weight",
+			templates: []microsimv1alpha1.RouteTemplate{{Name: "a", Hops: []microsimv1alpha1.Hop{{Designation: "a"}}, Weight: -1}},
+			err:       "negative weight",
+		},
+		{
+			name:      "all weights zero",
+			templates: []microsimv1alpha1.RouteTemplate{{Name: "a", Hops: []microsimv1alpha1.Hop{{Designation: "a"}}}},
+			err:       "weight of zero",
+		},
+		{
+			name:      "invalid template",
+			templates: []microsimv1alpha1.RouteTemplate{{Name: "a", Weight: 1}},
+			err:       "has no hops",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewTarget(context.Background(), testSimulation(), tt.templates, 0)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("NewTarget() error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestOverwriteDesignationsProbability(t *testing.T) {
+	route := microsimv1alpha1.Route{Designation: "a", Probability: 0, Routes: []microsimv1alpha1.Route{
+		{Designation: "never", Probability: 0},
+		{Designation: "always", Probability: 100},
+		{Designation: "sometimes", Probability: 30, Routes: []microsimv1alpha1.Route{{Designation: "child", Probability: 100}}},
+	}}
+	const requests = 4000
+	called := map[string]int{}
+	for sequence := uint64(0); sequence < requests; sequence++ {
+		sent, h := overwriteDesignations(testSimulation(), route, requestRand(7, sequence))
+		for _, decision := range decisions(h) {
+			if decision.Called {
+				called[decision.Path]++
+			}
+		}
+		// The sampled route replays the same calls
+		sampled := sampledRoute(route, h)
+		if len(sampled.Routes) != len(sent.Routes) {
+			t.Fatalf("sampled route has %d routes, the sent one %d", len(sampled.Routes), len(sent.Routes))
+		}
+		for _, r := range sampled.Routes {
+			if r.Probability != 100 {
+				t.Fatalf("sampled route %s has a probability of %d, want 100", r.Designation, r.Probability)
+			}
+		}
+	}
+
+	tests := []struct {
+		path     string
+		min, max int
+	}{
+		// The probability of the first hop is left out, the request is always sent
+		{path: "a", min: requests, max: requests},
+		{path: "a/never", min: 0, max: 0},
+		{path: "a/always", min: requests, max: requests},
+		{path: "a/sometimes", min: requests * 27 / 100, max: requests * 33 / 100},
+		{path: "a/sometimes/child", min: requests * 27 / 100, max: requests * 33 / 100},
+	}
+	for _, tt := range tests {
+		if got := called[tt.path]; got < tt.min || got > tt.max {
+			t.Errorf("%s was called %d times, want %d to %d", tt.path, got, tt.min, tt.max)
+		}
+	}
+	if called["a/sometimes"] != called["a/sometimes/child"] {
+		t.Errorf("child was called %d times, its parent %d", called["a/sometimes/child"], called["a/sometimes"])
+	}
+}
+
+func TestRequestRandDeterministic(t *testing.T) {
+	route := microsimv1alpha1.Route{Designation: "a", Routes: []microsimv1alpha1.Route{
+		{Designation: "b", Probability: 50}, {Designation: "c", Probability: 50}, {Designation: "d", Probability: 50},
+	}}
+	sample := func(seed int64, sequence uint64) []Decision {
+		_, h := overwriteDesignations(testSimulation(), route, requestRand(seed, sequence))
+		return decisions(h)
+	}
+
+	differs := 0
+	for sequence := uint64(0); sequence < 100; sequence++ {
+		if !reflect.DeepEqual(sample(42, sequence), sample(42, sequence)) {
+			t.Fatalf("request %d sampled different routes with the same seed", sequence)
+		}
+		if !reflect.DeepEqual(sample(42, sequence), sample(43, sequence)) {
+			differs++
+		}
+	}
+	if differs == 0 {
+		t.Errorf("different seeds sampled the same routes")
+	}
+}
+
+func TestPickVersion(t *testing.T) {
+	tests := []struct {
+		name     string
+		versions map[string]microsimv1alpha1.ServiceVersionStatus
+		// want is the share of every version in percent, within 3%
+		want map[string]int
+	}{
+		{
+			name:     "single version",
+			versions: map[string]microsimv1alpha1.ServiceVersionStatus{"v1": {Weight: 1}},
+			want:     map[string]int{"v1": 100},
+		},
+		{
+			name:     "canary",
+			versions: map[string]microsimv1alpha1.ServiceVersionStatus{"v1": {Weight: 90}, "v2": {Weight: 10}},
+			want:     map[string]int{"v1": 90, "v2": 10},
+		},
+		{
+			name:     "zero weight",
+			versions: map[string]microsimv1alpha1.ServiceVersionStatus{"v1": {Weight: 1}, "v2": {Weight: 0}, "v3": {Weight: 1}},
+			want:     map[string]int{"v1": 50, "v3": 50},
+		},
+		{
+			name:     "all weights zero",
+			versions: map[string]microsimv1alpha1.ServiceVersionStatus{"v1": {}, "v2": {}},
+			want:     map[string]int{"": 100},
+		},
+	}
+	const draws = 4000
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			picked := map[string]int{}
+			for sequence := uint64(0); sequence < draws; sequence++ {
+				version := pickVersion(tt.versions, requestRand(1, sequence))
+				if again := pickVersion(tt.versions, requestRand(1, sequence)); again != version {
+					t.Fatalf("draw %d picked %s and then %s", sequence, version, again)
+				}
+				picked[version]++
+			}
+			for version, count := range picked {
+				if _, ok := tt.want[version]; !ok {
+					t.Errorf("%q was picked %d times, want never", version, count)
+				}
+			}
+			for version, want := range tt.want {
+				if got := picked[version] * 100 / draws; got < want-3 || got > want+3 {
+					t.Errorf("%q was picked %d%% of the time, want %d%%", version, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestOverwriteDesignationsVersions(t *testing.T) {
+	simulation := testSimulation()
+	simulation.Status.Services = map[string]microsimv1alpha1.ServiceStatus{
+		simulation.ServiceName("front"): {
+			Endpoint: "http://front",
+			Versions: map[string]microsimv1alpha1.ServiceVersionStatus{
+				"v1": {Endpoint: "http://front-v1", Weight: 1, Faults: microsimv1alpha1.Faults{
+					Before: []microsimv1alpha1.Fault{{Type: "version-before"}},
+					After:  []microsimv1alpha1.Fault{{Type: "version-after"}},
+				}},
+				"v2": {Endpoint: "http://front-v2", Weight: 0},
+			},
+		},
+		simulation.ServiceName("back"): {Endpoint: "http://back"},
+	}
+	faults := microsimv1alpha1.Faults{
+		Before: []microsimv1alpha1.Fault{{Type: "route-before"}},
+		After:  []microsimv1alpha1.Fault{{Type: "route-after"}},
+	}
+	tests := []struct {
+		name        string
+		designation string
+		endpoint    string
+		sent        string
+		faults      []string
+	}{
+		{name: "weighted version", designation: "front", endpoint: "http://front-v1", sent: "front@v1",
+			faults: []string{"version-before", "route-before", "route-after", "version-after"}},
+		{name: "pinned version", designation: "front@v2", endpoint: "http://front-v2", sent: "front@v2",
+			faults: []string{"route-before", "route-after"}},
+		{name: "unknown version", designation: "front@v3", endpoint: "http://front", sent: "front@v3",
+			faults: []string{"route-before", "route-after"}},
+		{name: "service without versions", designation: "back", endpoint: "http://back", sent: "back",
+			faults: []string{"route-before", "route-after"}},
+		{name: "unknown service", designation: "missing", endpoint: "missing", sent: "missing",
+			faults: []string{"route-before", "route-after"}},
+		{name: "url", designation: "http://example.com", endpoint: "http://example.com", sent: "http://example.com",
+			faults: []string{"route-before", "route-after"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			route := microsimv1alpha1.Route{Designation: tt.designation, Faults: faults}
+			got, h := overwriteDesignations(simulation, route, requestRand(1, 0))
+			if got.Designation != tt.endpoint || h.designation != tt.sent {
+				t.Errorf("sent to %s as %s, want %s as %s", got.Designation, h.designation, tt.endpoint, tt.sent)
+			}
+			var types []string
+			for _, fault := range append(got.Faults.Before, got.Faults.After...) {
+				types = append(types, fault.Type)
+			}
+			if !reflect.DeepEqual(types, tt.faults) {
+				t.Errorf("faults = %v, want %v", types, tt.faults)
+			}
+		})
+	}
+}
+
+func TestSplitDesignation(t *testing.T) {
+	tests := []struct {
+		designation string
+		name        string
+		version     string
+	}{
+		{designation: "service_1", name: "service_1"},
+		{designation: "service_1@v2", name: "service_1", version: "v2"},
+		{designation: "a@b@v3", name: "a@b", version: "v3"},
+	}
+	for _, tt := range tests {
+		if name, version := splitDesignation(tt.designation); name != tt.name || version != tt.version {
+			t.Errorf("splitDesignation(%q) = %q, %q, want %q, %q", tt.designation, name, version, tt.name, tt.version)
+		}
+	}
+}
+
+func TestRouteProblems(t *testing.T) {
+	simulation := testSimulation()
+	simulation.Status.Services = map[string]microsimv1alpha1.ServiceStatus{
+		simulation.ServiceName("front"): {
+			SupportedFaults: []string{"latency"},
+			Versions:        map[string]microsimv1alpha1.ServiceVersionStatus{"v1": {Weight: 1}},
+		},
+		simulation.ServiceName("back"): {},
+	}
+	route := microsimv1alpha1.Route{
+		Designation: "front@v3",
+		Faults:      microsimv1alpha1.Faults{Before: []microsimv1alpha1.Fault{{Type: "latency"}, {Type: "cpu"}}},
+		Routes: []microsimv1alpha1.Route{
+			{Designation: "back"},
+			{Designation: "back@v1"},
+			{Designation: "missing", Routes: []microsimv1alpha1.Route{{Designation: "http://example.com"}}},
+		},
+	}
+
+	var got []string
+	for _, p := range routeProblems(simulation, route) {
+		got = append(got, fmt.Sprint(p.message, p.keysAndValues))
+	}
+	want := []string{
+		"fault is not supported by the service framework[service name front@v3 fault cpu]",
+		"service version was not found, using the shared service[service name front version v3]",
+		"service name was not found[service name missing]",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("routeProblems() = %q, want %q", got, want)
+	}
+}
+
+func TestTargetLogsProblemsOnce(t *testing.T) {
+	var logs bytes.Buffer
+	ctx := log.IntoContext(context.Background(), zap.New(zap.WriteTo(&logs)))
+	templates := []microsimv1alpha1.RouteTemplate{
+		{Name: "a", Hops: []microsimv1alpha1.Hop{{Designation: "missing"}}, Weight: 1},
+		{Name: "b", Hops: []microsimv1alpha1.Hop{{Designation: "missing"}}, Weight: 1},
+	}
+	target, err := NewTarget(ctx, testSimulation(), templates, 0)
+	if err != nil {
+		t.Fatalf("NewTarget() error = %v", err)
+	}
+	target.SetSimulation(ctx, testSimulation())
+	if got := strings.Count(logs.String(), "service name was not found"); got != 1 {
+		t.Errorf("missing service was logged %d times, want once:\n%s", got, logs.String())
+	}
+}
+
+func TestUnsupportedFaults(t *testing.T) {
+	faults := microsimv1alpha1.Faults{
+		Before: []microsimv1alpha1.Fault{{Type: "latency"}, {Type: "cpu"}},
+		After:  []microsimv1alpha1.Fault{{Type: "memory-leak"}},
+	}
+	tests := []struct {
+		name      string
+		supported []string
+		want      []string
+	}{
+		{name: "all supported", supported: []string{"latency", "cpu", "memory-leak"}, want: nil},
+		{name: "some unsupported", supported: []string{"latency"}, want: []string{"cpu", "memory-leak"}},
+		{name: "nothing declared", supported: nil, want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := unsupportedFaults(faults, tt.supported); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("unsupportedFaults() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -3,11 +3,14 @@ module github.com/MrSupiri/MicroSim
 go 1.16
 
 require (
-	github.com/google/uuid v1.1.2 // indirect
+	github.com/google/uuid v1.1.2
 	github.com/onsi/ginkgo v1.14.1
 	github.com/onsi/gomega v1.10.2
-	k8s.io/api v0.20.2 // indirect
+	github.com/prometheus/client_golang v1.7.1
+	github.com/robfig/cron/v3 v3.0.0
+	k8s.io/api v0.20.2
 	k8s.io/apimachinery v0.20.2
 	k8s.io/client-go v0.20.2
 	sigs.k8s.io/controller-runtime v0.8.3
+	sigs.k8s.io/yaml v1.2.0
 )
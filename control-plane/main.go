@@ -52,8 +52,20 @@ func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
+	var serviceRegistry string
+	var resultsDir string
+	var resultsAddr string
+	var workerImage string
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
+	flag.StringVar(&serviceRegistry, "service-registry", "ghcr.io/mrsupiri/microsim/service",
+		"The image repository used for services that don't reference a ServiceFramework.")
+	flag.StringVar(&resultsDir, "results-dir", "",
+		"The directory load generators spill their samples and export their results to, the results store is disabled when it's empty.")
+	flag.StringVar(&resultsAddr, "results-bind-address", "",
+		"The address the results directory is served on under /results/, it's not served when it's empty.")
+	flag.StringVar(&workerImage, "worker-image", "ghcr.io/mrsupiri/microsim:latest",
+		"The image of the worker pods load generators run in.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
@@ -79,21 +91,46 @@ func main() {
 	}
 
 	if err = (&controllers.SimulationReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:          mgr.GetClient(),
+		Scheme:          mgr.GetScheme(),
+		ServiceRegistry: serviceRegistry,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Simulation")
 		os.Exit(1)
 	}
 	if err = (&controllers.LoadGeneratorReconciler{
+		Client:      mgr.GetClient(),
+		Scheme:      mgr.GetScheme(),
+		ResultsDir:  resultsDir,
+		WorkerImage: workerImage,
+		Recorder:    mgr.GetEventRecorderFor("loadgenerator-controller"),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "LoadGenerator")
+		os.Exit(1)
+	}
+	if err = (&controllers.ScheduledLoadGeneratorReconciler{
 		Client: mgr.GetClient(),
 		Scheme: mgr.GetScheme(),
 	}).SetupWithManager(mgr); err != nil {
-		setupLog.Error(err, "unable to create controller", "controller", "LoadGenerator")
+		setupLog.Error(err, "unable to create controller", "controller", "ScheduledLoadGenerator")
+		os.Exit(1)
+	}
+	if err = (&controllers.RunComparisonReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "RunComparison")
 		os.Exit(1)
 	}
 	//+kubebuilder:scaffold:builder
 
+	if resultsAddr != "" && resultsDir != "" {
+		if err := mgr.Add(&controllers.ResultsServer{Addr: resultsAddr, Dir: resultsDir}); err != nil {
+			setupLog.Error(err, "unable to set up results server")
+			os.Exit(1)
+		}
+	}
+
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
 		setupLog.Error(err, "unable to set up health check")
 		os.Exit(1)
@@ -0,0 +1,52 @@
+package worker
+
+import (
+	"fmt"
+	"time"
+
+	microsimv1alpha1 "github.com/MrSupiri/MicroSim/api/v1alpha1"
+	"github.com/MrSupiri/MicroSim/engine"
+)
+
+// defaultAbortWindow is the window of the abort conditions when the spec doesn't set one
+const defaultAbortWindow = 30 * time.Second
+
+// AbortWindow returns how far back the abort conditions look
+func AbortWindow(spec microsimv1alpha1.AbortSpec) time.Duration {
+	window := spec.Window.Duration
+	if window <= 0 {
+		window = defaultAbortWindow
+	}
+	if window > engine.MaxWindow {
+		window = engine.MaxWindow
+	}
+	return window
+}
+
+// AbortReason returns the reason and the message of the Finished condition, reason is empty when none
+// of the conditions are met. stats are the statistics of every shard of the run added up and recent
+// the latencies of their requests within the abort window
+func AbortReason(spec microsimv1alpha1.AbortSpec, stats engine.Snapshot, recent engine.Histogram, now time.Time) (string, string) {
+	minRequests := uint64(spec.MinRequests)
+	window := AbortWindow(spec)
+
+	if spec.MaxErrorRate != nil {
+		finished, failed := stats.Window(now, window)
+		if finished > 0 && finished >= minRequests {
+			errorRate := float64(failed) / float64(finished) * 100
+			if errorRate > float64(*spec.MaxErrorRate) {
+				return microsimv1alpha1.ReasonErrorRateExceeded, fmt.Sprintf("%.1f%% of the requests failed within the last %v, the limit is %d%%",
+					errorRate, window, *spec.MaxErrorRate)
+			}
+		}
+	}
+
+	if spec.MaxLatency != nil && recent.Total > 0 && recent.Total >= minRequests {
+		percentile, latency := recent.Latency().At(spec.Percentile)
+		if latency > spec.MaxLatency.Duration {
+			return microsimv1alpha1.ReasonLatencyExceeded, fmt.Sprintf("%s latency within the last %v is %v, the limit is %v",
+				percentile, window, latency.Round(time.Millisecond), spec.MaxLatency.Duration)
+		}
+	}
+	return "", ""
+}
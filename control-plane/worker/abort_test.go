@@ -0,0 +1,73 @@
+package worker
+
+import (
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	microsimv1alpha1 "github.com/MrSupiri/MicroSim/api/v1alpha1"
+	"github.com/MrSupiri/MicroSim/engine"
+)
+
+func TestAbortWindow(t *testing.T) {
+	tests := []struct {
+		window time.Duration
+		want   time.Duration
+	}{
+		{window: 0, want: defaultAbortWindow},
+		{window: time.Minute, want: time.Minute},
+		{window: time.Hour, want: engine.MaxWindow},
+	}
+	for _, tt := range tests {
+		if got := AbortWindow(microsimv1alpha1.AbortSpec{Window: metav1.Duration{Duration: tt.window}}); got != tt.want {
+			t.Errorf("AbortWindow(%v) = %v, want %v", tt.window, got, tt.want)
+		}
+	}
+}
+
+func TestAbortReason(t *testing.T) {
+	now := time.Unix(1000, 0)
+	// 20 requests finished in the last 30 seconds, 10 of them failed, and 100 older ones that all succeeded
+	stats := engine.Snapshot{Seconds: map[int64]*engine.Second{
+		now.Unix():      {Finished: 20, Failed: 10},
+		now.Unix() - 60: {Finished: 100},
+	}}
+	var recent engine.Histogram
+	for i := 1; i <= 20; i++ {
+		recent.Record(time.Duration(i) * 10 * time.Millisecond)
+	}
+	maxErrorRate := func(rate int) *int { return &rate }
+	maxLatency := func(d time.Duration) *metav1.Duration { return &metav1.Duration{Duration: d} }
+
+	tests := []struct {
+		name   string
+		spec   microsimv1alpha1.AbortSpec
+		recent engine.Histogram
+		want   string
+	}{
+		{name: "error rate within the window", spec: microsimv1alpha1.AbortSpec{MaxErrorRate: maxErrorRate(40)}, want: microsimv1alpha1.ReasonErrorRateExceeded},
+		{name: "error rate under the limit", spec: microsimv1alpha1.AbortSpec{MaxErrorRate: maxErrorRate(50)}},
+		{
+			name: "older requests in a longer window",
+			spec: microsimv1alpha1.AbortSpec{MaxErrorRate: maxErrorRate(40), Window: metav1.Duration{Duration: 2 * time.Minute}},
+		},
+		{name: "too few requests", spec: microsimv1alpha1.AbortSpec{MaxErrorRate: maxErrorRate(40), MinRequests: 21}},
+		{
+			name:   "latency over the limit",
+			spec:   microsimv1alpha1.AbortSpec{MaxLatency: maxLatency(100 * time.Millisecond), Percentile: "p90"},
+			recent: recent,
+			want:   microsimv1alpha1.ReasonLatencyExceeded,
+		},
+		{name: "latency under the limit", spec: microsimv1alpha1.AbortSpec{MaxLatency: maxLatency(time.Second)}, recent: recent},
+		{name: "no recent latency", spec: microsimv1alpha1.AbortSpec{MaxLatency: maxLatency(time.Millisecond)}},
+		{name: "no conditions", recent: recent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if reason, message := AbortReason(tt.spec, stats, tt.recent, now); reason != tt.want {
+				t.Errorf("AbortReason() = %q (%s), want %q", reason, message, tt.want)
+			}
+		})
+	}
+}
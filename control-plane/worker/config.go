@@ -0,0 +1,177 @@
+package worker
+
+import (
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	microsimv1alpha1 "github.com/MrSupiri/MicroSim/api/v1alpha1"
+	"github.com/MrSupiri/MicroSim/engine"
+)
+
+const (
+	// maxInFlight bounds the requests a single worker runs at once in the open model
+	maxInFlight = 1000
+	// maxJourneysInFlight bounds the journeys instead with scenarios, a journey spends most of its time thinking
+	maxJourneysInFlight = 10000
+)
+
+// EngineConfig picks the load model of the spec and splits it across the shards, load generators without
+// a rate or concurrency send a request per route every betweenDelay from every shard. A replay sends the
+// requests of its recording when they were sent instead
+func EngineConfig(spec microsimv1alpha1.LoadGeneratorSpec, routes int, shard int, shards int) (engine.Config, error) {
+	var config engine.Config
+	switch {
+	case spec.Replay != nil:
+		speed, err := ReplaySpeed(*spec.Replay)
+		if err != nil {
+			return engine.Config{}, err
+		}
+		config = engine.Config{Model: engine.OpenModel, MaxInFlight: maxInFlight, ReplaySpeed: speed}
+	case spec.Profile != nil:
+		var err error
+		if config, err = profileConfig(*spec.Profile); err != nil {
+			return engine.Config{}, err
+		}
+	case spec.Concurrency != nil:
+		config = engine.Config{Model: engine.ClosedModel, Concurrency: *spec.Concurrency}
+	case spec.Rate != nil:
+		config = engine.Config{Model: engine.OpenModel, Rate: quantityToFloat(*spec.Rate), MaxInFlight: maxInFlight}
+	case spec.BetweenDelay.Duration > 0:
+		rate := float64(shards*routes) / spec.BetweenDelay.Seconds()
+		config = engine.Config{Model: engine.OpenModel, Rate: rate, MaxInFlight: maxInFlight}
+	default:
+		return engine.Config{}, fmt.Errorf("one of rate, concurrency, profile or betweenDelay must be set")
+	}
+
+	// A shard without users would finish at once and leave its share of the request count unsent
+	if users := MaxUsers(spec); users > 0 && shards > users {
+		return engine.Config{}, fmt.Errorf("%d users can't be split across %d workers, every worker needs a user", users, shards)
+	}
+	if len(spec.Scenarios) > 0 && config.MaxInFlight > 0 {
+		config.MaxInFlight = maxJourneysInFlight
+	}
+
+	// The limit is left to the worker, a zero share has to be told apart from no limit
+	config.Shard, config.Shards = shard, shards
+	return config, nil
+}
+
+// Shards returns the number of workers the load of the spec is split across, the closed model gets
+// no more workers than it has users
+func Shards(spec microsimv1alpha1.LoadGeneratorSpec) int {
+	shards := spec.Replicas
+	if users := MaxUsers(spec); users > 0 && users < shards {
+		shards = users
+	}
+	if shards < 1 {
+		return 1
+	}
+	return shards
+}
+
+// MaxUsers returns the most users the closed model of the spec runs at once, it's 0 for the open model
+func MaxUsers(spec microsimv1alpha1.LoadGeneratorSpec) int {
+	switch {
+	case spec.Replay != nil:
+		return 0
+	case spec.Profile != nil:
+		users := 0
+		for _, stage := range spec.Profile.Stages {
+			if stage.Rate != nil {
+				return 0
+			}
+			if stage.Concurrency != nil && *stage.Concurrency > users {
+				users = *stage.Concurrency
+			}
+		}
+		return users
+	case spec.Concurrency != nil:
+		return *spec.Concurrency
+	}
+	return 0
+}
+
+// Share splits n across the shards such that the shares add up to n
+func Share(n int, shard int, shards int) int {
+	if shards <= 1 {
+		return n
+	}
+	return n*(shard+1)/shards - n*shard/shards
+}
+
+// profileConfig converts the stages of the profile, the load model is picked by whether the stages set a rate or a concurrency
+func profileConfig(spec microsimv1alpha1.LoadProfile) (engine.Config, error) {
+	if len(spec.Stages) == 0 {
+		return engine.Config{}, fmt.Errorf("profile has no stages")
+	}
+
+	config := engine.Config{Profile: &engine.Profile{}}
+	for i, s := range spec.Stages {
+		stage := engine.Stage{
+			Name:     s.Name,
+			Duration: s.Duration.Duration,
+			Shape:    engine.Shape(s.Shape),
+		}
+		if stage.Name == "" {
+			stage.Name = fmt.Sprintf("stage-%d", i)
+		}
+		if stage.Duration <= 0 {
+			return engine.Config{}, fmt.Errorf("stage %s has no duration", stage.Name)
+		}
+
+		var model engine.Model
+		switch {
+		case s.Rate != nil && s.Concurrency != nil:
+			return engine.Config{}, fmt.Errorf("stage %s sets both rate and concurrency", stage.Name)
+		case s.Rate != nil:
+			model, stage.Target = engine.OpenModel, quantityToFloat(*s.Rate)
+		case s.Concurrency != nil:
+			model, stage.Target = engine.ClosedModel, float64(*s.Concurrency)
+		default:
+			return engine.Config{}, fmt.Errorf("stage %s sets neither rate nor concurrency", stage.Name)
+		}
+		if config.Model != "" && config.Model != model {
+			return engine.Config{}, fmt.Errorf("profile mixes rate and concurrency stages")
+		}
+		config.Model = model
+
+		if s.Amplitude != nil {
+			stage.Amplitude = quantityToFloat(*s.Amplitude)
+		}
+		if s.Period != nil {
+			stage.Period = s.Period.Duration
+		}
+		config.Profile.Stages = append(config.Profile.Stages, stage)
+	}
+	if config.Model == engine.OpenModel {
+		config.MaxInFlight = maxInFlight
+	}
+	return config, nil
+}
+
+func quantityToFloat(q resource.Quantity) float64 {
+	return float64(q.MilliValue()) / 1000
+}
+
+// SeedOf returns the seed of the shard, derived from the seed of the spec or the one the controller
+// picked for the run
+func SeedOf(loadGenerator *microsimv1alpha1.LoadGenerator, shard int) int64 {
+	seed := time.Now().UnixNano()
+	switch {
+	case loadGenerator.Spec.Seed != nil:
+		seed = *loadGenerator.Spec.Seed
+	case loadGenerator.Status.Seed != nil:
+		seed = *loadGenerator.Status.Seed
+	}
+	return engine.ShardSeed(seed, shard)
+}
+
+// SamplingOf returns the sampling spec of the load generator with the defaults filled in
+func SamplingOf(spec microsimv1alpha1.LoadGeneratorSpec) microsimv1alpha1.SamplingSpec {
+	if spec.Sampling == nil {
+		return microsimv1alpha1.SamplingSpec{Errors: 5, Successes: 5}
+	}
+	return *spec.Sampling
+}
@@ -0,0 +1,198 @@
+package worker
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	microsimv1alpha1 "github.com/MrSupiri/MicroSim/api/v1alpha1"
+	"github.com/MrSupiri/MicroSim/engine"
+)
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func TestShare(t *testing.T) {
+	tests := []struct {
+		name   string
+		n      int
+		shards int
+		want   []int
+	}{
+		{name: "single shard", n: 7, shards: 1, want: []int{7}},
+		{name: "no shards", n: 7, shards: 0, want: []int{7}},
+		{name: "even split", n: 9, shards: 3, want: []int{3, 3, 3}},
+		{name: "remainder to the later shards", n: 10, shards: 3, want: []int{3, 3, 4}},
+		{name: "fewer than shards", n: 2, shards: 4, want: []int{0, 1, 0, 1}},
+		{name: "zero", n: 0, shards: 3, want: []int{0, 0, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			total := 0
+			for shard, want := range tt.want {
+				got := Share(tt.n, shard, tt.shards)
+				if got != want {
+					t.Errorf("Share(%d, %d, %d) = %d, want %d", tt.n, shard, tt.shards, got, want)
+				}
+				total += got
+			}
+			if total != tt.n {
+				t.Errorf("shares add up to %d, want %d", total, tt.n)
+			}
+		})
+	}
+}
+
+func TestShards(t *testing.T) {
+	rate := resource.MustParse("10")
+	tests := []struct {
+		name string
+		spec microsimv1alpha1.LoadGeneratorSpec
+		want int
+	}{
+		{name: "default", spec: microsimv1alpha1.LoadGeneratorSpec{}, want: 1},
+		{name: "open model", spec: microsimv1alpha1.LoadGeneratorSpec{Replicas: 4, Rate: &rate}, want: 4},
+		{name: "enough users", spec: microsimv1alpha1.LoadGeneratorSpec{Replicas: 4, Concurrency: intPtr(8)}, want: 4},
+		{name: "fewer users than replicas", spec: microsimv1alpha1.LoadGeneratorSpec{Replicas: 4, Concurrency: intPtr(2)}, want: 2},
+		{name: "replay", spec: microsimv1alpha1.LoadGeneratorSpec{Replicas: 3, Concurrency: intPtr(1), Replay: &microsimv1alpha1.ReplaySpec{}}, want: 3},
+		{
+			name: "closed profile",
+			spec: microsimv1alpha1.LoadGeneratorSpec{Replicas: 4, Profile: &microsimv1alpha1.LoadProfile{Stages: []microsimv1alpha1.LoadStage{
+				{Concurrency: intPtr(1)}, {Concurrency: intPtr(3)},
+			}}},
+			want: 3,
+		},
+		{
+			name: "open profile",
+			spec: microsimv1alpha1.LoadGeneratorSpec{Replicas: 4, Profile: &microsimv1alpha1.LoadProfile{Stages: []microsimv1alpha1.LoadStage{
+				{Rate: &rate},
+			}}},
+			want: 4,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Shards(tt.spec); got != tt.want {
+				t.Errorf("Shards() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEngineConfig(t *testing.T) {
+	rate := resource.MustParse("2500m")
+	tests := []struct {
+		name   string
+		spec   microsimv1alpha1.LoadGeneratorSpec
+		shards int
+		model  string
+		rate   float64
+		err    string
+	}{
+		{name: "rate", spec: microsimv1alpha1.LoadGeneratorSpec{Rate: &rate}, shards: 2, model: "Open", rate: 2.5},
+		{name: "concurrency over rate", spec: microsimv1alpha1.LoadGeneratorSpec{Rate: &rate, Concurrency: intPtr(2)}, shards: 2, model: "Closed"},
+		{name: "too many workers for the users", spec: microsimv1alpha1.LoadGeneratorSpec{Concurrency: intPtr(2)}, shards: 3, err: "2 users can't be split across 3 workers"},
+		{name: "nothing to send", spec: microsimv1alpha1.LoadGeneratorSpec{}, shards: 1, err: "must be set"},
+		{
+			name: "mixed profile",
+			spec: microsimv1alpha1.LoadGeneratorSpec{Profile: &microsimv1alpha1.LoadProfile{Stages: []microsimv1alpha1.LoadStage{
+				{Name: "a", Duration: metav1.Duration{Duration: time.Second}, Rate: &rate}, {Name: "b", Duration: metav1.Duration{Duration: time.Second}, Concurrency: intPtr(1)},
+			}}},
+			shards: 1,
+			err:    "mixes rate and concurrency",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := EngineConfig(tt.spec, 1, 0, tt.shards)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("EngineConfig() error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("EngineConfig() error = %v", err)
+			}
+			if string(config.Model) != tt.model || config.Rate != tt.rate || config.Shards != tt.shards {
+				t.Errorf("EngineConfig() = %s model at %v over %d shards, want %s at %v over %d", config.Model, config.Rate, config.Shards, tt.model, tt.rate, tt.shards)
+			}
+		})
+	}
+}
+
+func TestEngineConfigScenarios(t *testing.T) {
+	rate := resource.MustParse("10")
+	spec := microsimv1alpha1.LoadGeneratorSpec{Rate: &rate, Scenarios: []microsimv1alpha1.Scenario{{Name: "purchase"}}}
+	config, err := EngineConfig(spec, 1, 0, 1)
+	if err != nil {
+		t.Fatalf("EngineConfig() error = %v", err)
+	}
+	if config.MaxInFlight != maxJourneysInFlight {
+		t.Errorf("MaxInFlight = %d, want %d journeys", config.MaxInFlight, maxJourneysInFlight)
+	}
+}
+
+func TestProfileConfig(t *testing.T) {
+	rate, concurrency := resource.MustParse("2500m"), 4
+	tests := []struct {
+		name    string
+		stages  []microsimv1alpha1.LoadStage
+		want    engine.Config
+		wantErr bool
+	}{
+		{
+			name: "rate stages",
+			stages: []microsimv1alpha1.LoadStage{
+				{Duration: metav1.Duration{Duration: time.Minute}, Shape: microsimv1alpha1.LoadShape(engine.Ramp), Rate: &rate},
+				{Name: "hold", Duration: metav1.Duration{Duration: time.Minute}, Rate: &rate},
+			},
+			want: engine.Config{Model: engine.OpenModel, MaxInFlight: maxInFlight, Profile: &engine.Profile{Stages: []engine.Stage{
+				{Name: "stage-0", Duration: time.Minute, Shape: engine.Ramp, Target: 2.5},
+				{Name: "hold", Duration: time.Minute, Target: 2.5},
+			}}},
+		},
+		{
+			name:   "concurrency stages",
+			stages: []microsimv1alpha1.LoadStage{{Duration: metav1.Duration{Duration: time.Minute}, Concurrency: &concurrency}},
+			want: engine.Config{Model: engine.ClosedModel, Profile: &engine.Profile{Stages: []engine.Stage{
+				{Name: "stage-0", Duration: time.Minute, Target: 4},
+			}}},
+		},
+		{name: "no stages", wantErr: true},
+		{
+			name:    "no duration",
+			stages:  []microsimv1alpha1.LoadStage{{Rate: &rate}},
+			wantErr: true,
+		},
+		{
+			name:    "neither rate nor concurrency",
+			stages:  []microsimv1alpha1.LoadStage{{Duration: metav1.Duration{Duration: time.Minute}}},
+			wantErr: true,
+		},
+		{
+			name: "mixed models",
+			stages: []microsimv1alpha1.LoadStage{
+				{Duration: metav1.Duration{Duration: time.Minute}, Rate: &rate},
+				{Duration: metav1.Duration{Duration: time.Minute}, Concurrency: &concurrency},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := profileConfig(microsimv1alpha1.LoadProfile{Stages: tt.stages})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("profileConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("profileConfig() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,183 @@
+package worker
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	microsimv1alpha1 "github.com/MrSupiri/MicroSim/api/v1alpha1"
+	"github.com/MrSupiri/MicroSim/engine"
+)
+
+const (
+	// RecordingKey is the key of the recorded requests in the ConfigMap of a shard
+	RecordingKey = "recording.jsonl"
+	// downloadTimeout bounds the download of a recording from a URL
+	downloadTimeout = time.Minute
+)
+
+// downloadClient downloads the recordings, a server that stops answering fails the worker rather than hanging it
+var downloadClient = &http.Client{Timeout: downloadTimeout}
+
+// RecordingName is the name of the ConfigMap the shard records its requests to
+func RecordingName(loadGenerator string, shard int) string {
+	return fmt.Sprintf("%s-recording-%d", loadGenerator, shard)
+}
+
+// recording keeps the requests of a shard as JSON lines until they fill a ConfigMap, it is safe for
+// concurrent use. Once it's full every request after is dropped so the recording stays a prefix of the run
+type recording struct {
+	mu       sync.Mutex
+	data     bytes.Buffer
+	requests int
+	dropped  int
+}
+
+func (r *recording) Record(result engine.Result) error {
+	line, err := json.Marshal(engine.NewRecorded(result))
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.dropped > 0 || r.data.Len()+len(line)+1 > MaxSpillSize {
+		r.dropped++
+		return nil
+	}
+	r.data.Write(line)
+	r.data.WriteByte('\n')
+	r.requests++
+	return nil
+}
+
+// Restore continues from the recording of a resumed run
+func (r *recording) Restore(data string, dropped int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.data.Reset()
+	r.data.WriteString(data)
+	r.requests = strings.Count(data, "\n")
+	r.dropped = dropped
+}
+
+// Data returns the recorded requests as JSON lines
+func (r *recording) Data() []byte {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]byte(nil), r.data.Bytes()...)
+}
+
+// Counts returns the number of requests recorded and dropped
+func (r *recording) Counts() (int, int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.requests, r.dropped
+}
+
+// ReadRecording reads recorded requests written as JSON lines
+func ReadRecording(data io.Reader) ([]engine.Recorded, error) {
+	var recorded []engine.Recorded
+	scanner := bufio.NewScanner(data)
+	scanner.Buffer(nil, MaxSpillSize)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var request engine.Recorded
+		if err := json.Unmarshal(scanner.Bytes(), &request); err != nil {
+			return nil, err
+		}
+		recorded = append(recorded, request)
+	}
+	return recorded, scanner.Err()
+}
+
+// SortRecording puts the requests of a recording in the order they were sent
+func SortRecording(recorded []engine.Recorded) {
+	sort.SliceStable(recorded, func(i, j int) bool { return recorded[i].Offset < recorded[j].Offset })
+}
+
+// ReplaySpeed returns the speed of the replay spec, it fails if the spec has no recording to replay
+func ReplaySpeed(spec microsimv1alpha1.ReplaySpec) (float64, error) {
+	if (spec.From == nil) == (spec.URL == "") {
+		return 0, fmt.Errorf("replay needs one of from or url")
+	}
+	if spec.Speed == nil {
+		return 1, nil
+	}
+	speed := quantityToFloat(*spec.Speed)
+	if speed <= 0 {
+		return 0, fmt.Errorf("replay speed must be positive")
+	}
+	return speed, nil
+}
+
+// readRecording reads the recording the spec replays and returns the share of the shard, every shard
+// takes every shards-th request in the order they were sent
+func (w *Worker) readRecording(ctx context.Context, loadGenerator *microsimv1alpha1.LoadGenerator, spec microsimv1alpha1.ReplaySpec) ([]engine.Recorded, error) {
+	var recorded []engine.Recorded
+	if spec.URL != "" {
+		var err error
+		if recorded, err = downloadRecording(ctx, spec.URL); err != nil {
+			return nil, fmt.Errorf("failed to download recording from %s: %w", spec.URL, err)
+		}
+	} else {
+		var source microsimv1alpha1.LoadGenerator
+		if err := w.Get(ctx, spec.From.NamespacedName(loadGenerator.Namespace), &source); err != nil {
+			return nil, err
+		}
+		for shard := 0; shard < Shards(source.Spec); shard++ {
+			var configMap v1.ConfigMap
+			key := types.NamespacedName{Namespace: source.Namespace, Name: RecordingName(source.Name, shard)}
+			if err := w.Get(ctx, key, &configMap); err != nil {
+				if client.IgnoreNotFound(err) != nil {
+					return nil, err
+				}
+				continue
+			}
+			requests, err := ReadRecording(strings.NewReader(configMap.Data[RecordingKey]))
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode recording of %s: %w", configMap.Name, err)
+			}
+			recorded = append(recorded, requests...)
+		}
+	}
+	SortRecording(recorded)
+
+	if w.Shards <= 1 {
+		return recorded, nil
+	}
+	share := make([]engine.Recorded, 0, len(recorded)/w.Shards+1)
+	for i := w.Shard; i < len(recorded); i += w.Shards {
+		share = append(share, recorded[i])
+	}
+	return share, nil
+}
+
+func downloadRecording(ctx context.Context, url string) ([]engine.Recorded, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := downloadClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("server responded with status %d", resp.StatusCode)
+	}
+	return ReadRecording(resp.Body)
+}
@@ -0,0 +1,167 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	microsimv1alpha1 "github.com/MrSupiri/MicroSim/api/v1alpha1"
+	"github.com/MrSupiri/MicroSim/engine"
+)
+
+// recordingData returns the recorded requests with the sequences as JSON lines
+func recordingData(t *testing.T, sequences ...uint64) string {
+	var data strings.Builder
+	for _, sequence := range sequences {
+		line, err := json.Marshal(engine.Recorded{Offset: time.Duration(sequence) * time.Millisecond, Sequence: sequence})
+		if err != nil {
+			t.Fatal(err)
+		}
+		data.Write(line)
+		data.WriteByte('\n')
+	}
+	return data.String()
+}
+
+// sequences returns the sequences of the recorded requests
+func sequences(recorded []engine.Recorded) []uint64 {
+	var sequences []uint64
+	for _, request := range recorded {
+		sequences = append(sequences, request.Sequence)
+	}
+	return sequences
+}
+
+func TestRecording(t *testing.T) {
+	var r recording
+	result := engine.Result{Template: "browse", Sequence: 1, Route: microsimv1alpha1.Route{Designation: strings.Repeat("a", MaxSpillSize/3)}}
+	for i := 0; i < 4; i++ {
+		if err := r.Record(result); err != nil {
+			t.Fatalf("Record() error = %v", err)
+		}
+	}
+	// Only two requests fit, the ones after are dropped even when a smaller one would fit
+	if err := r.Record(engine.Result{Sequence: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if requests, dropped := r.Counts(); requests != 2 || dropped != 3 {
+		t.Errorf("Counts() = %d, %d, want 2 recorded and 3 dropped", requests, dropped)
+	}
+
+	var restored recording
+	restored.Restore(string(r.Data()), 3)
+	if requests, dropped := restored.Counts(); requests != 2 || dropped != 3 {
+		t.Errorf("restored Counts() = %d, %d, want 2 recorded and 3 dropped", requests, dropped)
+	}
+	recorded, err := ReadRecording(strings.NewReader(string(restored.Data())))
+	if err != nil {
+		t.Fatalf("ReadRecording() error = %v", err)
+	}
+	if len(recorded) != 2 || recorded[0].Template != "browse" || recorded[0].Route.Designation != result.Route.Designation {
+		t.Errorf("recording = %+v, want the 2 first requests", recorded)
+	}
+}
+
+func TestReplaySpeed(t *testing.T) {
+	speed := func(s string) *resource.Quantity {
+		q := resource.MustParse(s)
+		return &q
+	}
+	tests := []struct {
+		name string
+		spec microsimv1alpha1.ReplaySpec
+		want float64
+		err  string
+	}{
+		{name: "default", spec: microsimv1alpha1.ReplaySpec{URL: "http://recordings/browse.jsonl"}, want: 1},
+		{name: "scaled", spec: microsimv1alpha1.ReplaySpec{From: &microsimv1alpha1.LoadGeneratorRef{Name: "browse"}, Speed: speed("2500m")}, want: 2.5},
+		{name: "nothing to replay", spec: microsimv1alpha1.ReplaySpec{}, err: "one of from or url"},
+		{
+			name: "both",
+			spec: microsimv1alpha1.ReplaySpec{From: &microsimv1alpha1.LoadGeneratorRef{Name: "browse"}, URL: "http://recordings/browse.jsonl"},
+			err:  "one of from or url",
+		},
+		{name: "zero", spec: microsimv1alpha1.ReplaySpec{URL: "http://recordings/browse.jsonl", Speed: speed("0")}, err: "must be positive"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReplaySpeed(tt.spec)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("ReplaySpeed() error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("ReplaySpeed() = %v, %v, want %v", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadRecording(t *testing.T) {
+	// The source ran on 3 workers, replicas alone would have read a single shard
+	source := &microsimv1alpha1.LoadGenerator{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "recorded"},
+		Spec:       microsimv1alpha1.LoadGeneratorSpec{Replicas: 3},
+	}
+	configMap := func(shard int, sequences ...uint64) *v1.ConfigMap {
+		return &v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: RecordingName(source.Name, shard)},
+			Data:       map[string]string{RecordingKey: recordingData(t, sequences...)},
+		}
+	}
+	scheme := testScheme(t)
+	w := &Worker{
+		Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(source, configMap(0, 0, 3), configMap(1, 1, 4), configMap(2, 2, 5)).Build(),
+		Scheme: scheme,
+		Shard:  1,
+		Shards: 2,
+	}
+	loadGenerator := &microsimv1alpha1.LoadGenerator{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "replay"}}
+
+	recorded, err := w.readRecording(context.Background(), loadGenerator, microsimv1alpha1.ReplaySpec{From: &microsimv1alpha1.LoadGeneratorRef{Name: source.Name}})
+	if err != nil {
+		t.Fatalf("readRecording() error = %v", err)
+	}
+	// The second of two shards takes every other request in the order they were sent
+	if got, want := sequences(recorded), []uint64{1, 3, 5}; !reflect.DeepEqual(got, want) {
+		t.Errorf("replayed %v, want %v", got, want)
+	}
+}
+
+func TestReadRecordingURL(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/browse.jsonl" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(recordingData(t, 2, 0, 1)))
+	}))
+	defer server.Close()
+	w := &Worker{Shard: 0, Shards: 1}
+	loadGenerator := &microsimv1alpha1.LoadGenerator{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "replay"}}
+
+	recorded, err := w.readRecording(context.Background(), loadGenerator, microsimv1alpha1.ReplaySpec{URL: server.URL + "/browse.jsonl"})
+	if err != nil {
+		t.Fatalf("readRecording() error = %v", err)
+	}
+	if got, want := sequences(recorded), []uint64{0, 1, 2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("replayed %v, want %v", got, want)
+	}
+
+	_, err = w.readRecording(context.Background(), loadGenerator, microsimv1alpha1.ReplaySpec{URL: server.URL + "/missing.jsonl"})
+	if err == nil || !strings.Contains(err.Error(), "status 404") {
+		t.Errorf("readRecording() error = %v, want the status of the server", err)
+	}
+}
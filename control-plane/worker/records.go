@@ -0,0 +1,127 @@
+package worker
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MrSupiri/MicroSim/engine"
+)
+
+// RecordsKey is the key of the recorded requests in the ConfigMap of a shard
+const RecordsKey = "records.jsonl"
+
+// Record is a request of the run as it's exported, one per line of records.jsonl and records.csv
+type Record struct {
+	Time       time.Time `json:"time"`
+	Shard      int       `json:"shard"`
+	Sequence   uint64    `json:"sequence"`
+	Template   string    `json:"template"`
+	LatencyMs  float64   `json:"latencyMs"`
+	StatusCode int       `json:"statusCode"`
+	Error      string    `json:"error,omitempty"`
+	// Route are the paths of the hops that were called, the ones left out by their probability aren't listed
+	Route []string `json:"route,omitempty"`
+	// Warmup is set on the requests of the warmup
+	Warmup bool `json:"warmup,omitempty"`
+	// Scenario and Step are set on the requests of a journey, Sequence is then the number of the journey
+	Scenario string `json:"scenario,omitempty"`
+	Step     int    `json:"step,omitempty"`
+}
+
+// RecordsName is the name of the ConfigMap the shard writes its records to
+func RecordsName(loadGenerator string, shard int) string {
+	return fmt.Sprintf("%s-records-%d", loadGenerator, shard)
+}
+
+// recorder keeps the first max requests of a shard, it is safe for concurrent use
+type recorder struct {
+	shard int
+	max   int
+
+	mu      sync.Mutex
+	records []Record
+}
+
+func newRecorder(shard int, max int) *recorder {
+	return &recorder{shard: shard, max: max}
+}
+
+func (r *recorder) Record(result engine.Result) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.records) >= r.max {
+		return
+	}
+	record := Record{
+		Time:       result.Sent,
+		Shard:      r.shard,
+		Sequence:   result.Sequence,
+		Template:   result.Template,
+		LatencyMs:  float64(result.Latency) / float64(time.Millisecond),
+		StatusCode: result.StatusCode,
+		Warmup:     result.Warmup,
+		Scenario:   result.Scenario,
+		Step:       result.Step,
+	}
+	if result.Err != nil {
+		record.Error = result.Err.Error()
+	}
+	for _, decision := range result.Decisions {
+		if decision.Called {
+			record.Route = append(record.Route, decision.Path)
+		}
+	}
+	r.records = append(r.records, record)
+}
+
+// Restore continues from the records of a resumed run
+func (r *recorder) Restore(records []Record) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.records = append([]Record(nil), records...)
+	if len(r.records) > r.max {
+		r.records = r.records[:r.max]
+	}
+}
+
+// Encode returns the records as JSON lines, the records after maxSize bytes are left out
+func (r *recorder) Encode(maxSize int) ([]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var buf bytes.Buffer
+	for _, record := range r.records {
+		line, err := json.Marshal(record)
+		if err != nil {
+			return nil, err
+		}
+		if buf.Len()+len(line)+1 > maxSize {
+			break
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
+
+// DecodeRecords reads records written as JSON lines
+func DecodeRecords(data string) ([]Record, error) {
+	var records []Record
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	scanner.Buffer(nil, MaxSpillSize)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, scanner.Err()
+}
@@ -0,0 +1,75 @@
+package worker
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MrSupiri/MicroSim/engine"
+)
+
+func TestRecorder(t *testing.T) {
+	sent := time.Date(2021, 6, 1, 10, 0, 0, 0, time.UTC)
+	r := newRecorder(1, 2)
+	r.Record(engine.Result{
+		Sent: sent, Sequence: 4, Template: "browse", Latency: 1500 * time.Microsecond, StatusCode: 200,
+		Decisions: []engine.Decision{{Path: "front", Called: true}, {Path: "front/cart"}, {Path: "front/search", Called: true}},
+	})
+	r.Record(engine.Result{Sent: sent, Sequence: 5, Template: "checkout", Err: errors.New("connection refused")})
+	r.Record(engine.Result{Sent: sent, Sequence: 6, Template: "browse"})
+
+	want := []Record{
+		{Time: sent, Shard: 1, Sequence: 4, Template: "browse", LatencyMs: 1.5, StatusCode: 200, Route: []string{"front", "front/search"}},
+		{Time: sent, Shard: 1, Sequence: 5, Template: "checkout", Error: "connection refused"},
+	}
+	if !reflect.DeepEqual(r.records, want) {
+		t.Errorf("records = %+v\nwant the first 2 with only the called hops %+v", r.records, want)
+	}
+
+	data, err := r.Encode(MaxSpillSize)
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	decoded, err := DecodeRecords(string(data))
+	if err != nil {
+		t.Fatalf("DecodeRecords() error = %v", err)
+	}
+	if !reflect.DeepEqual(decoded, want) {
+		t.Errorf("decoded records = %+v, want %+v", decoded, want)
+	}
+
+	restored := newRecorder(1, 1)
+	restored.Restore(decoded)
+	if len(restored.records) != 1 || restored.records[0].Sequence != 4 {
+		t.Errorf("restored records = %+v, want only the first within the max", restored.records)
+	}
+}
+
+func TestRecorderEncode(t *testing.T) {
+	r := newRecorder(0, 100)
+	for i := 0; i < 100; i++ {
+		r.Record(engine.Result{Template: "browse"})
+	}
+	full, _ := r.Encode(MaxSpillSize)
+	line := len(full) / 100
+
+	data, err := r.Encode(10*line + line/2)
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	if got := strings.Count(string(data), "\n"); got != 10 {
+		t.Errorf("encoded %d records, want the 10 that fit", got)
+	}
+}
+
+func TestDecodeRecords(t *testing.T) {
+	records, err := DecodeRecords("{\"sequence\":1}\n\n{\"sequence\":2}\n")
+	if err != nil || len(records) != 2 || records[1].Sequence != 2 {
+		t.Errorf("DecodeRecords() = %+v, %v, want 2 records with the blank line skipped", records, err)
+	}
+	if _, err := DecodeRecords("{\n"); err == nil {
+		t.Errorf("DecodeRecords() of an invalid line error = nil")
+	}
+}
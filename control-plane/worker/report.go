@@ -0,0 +1,85 @@
+package worker
+
+import (
+	"encoding/json"
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	microsimv1alpha1 "github.com/MrSupiri/MicroSim/api/v1alpha1"
+	"github.com/MrSupiri/MicroSim/engine"
+)
+
+const (
+	// ReportKey is the key of the report in the ConfigMap of a shard
+	ReportKey = "report.json"
+	// SamplesKey is the key of the spilled samples in the ConfigMap of a shard
+	SamplesKey = "samples.json"
+)
+
+// Report is the state of a shard, the worker writes it to a ConfigMap and the controller adds
+// up the reports of the shards into the status of the load generator
+type Report struct {
+	// Generation of the load generator the shard is running
+	Generation int64 `json:"generation"`
+	Shard      int   `json:"shard"`
+	// Stage is the name of the profile stage the shard is in
+	Stage string `json:"stage,omitempty"`
+	// Elapsed is how long the shard has been sending requests, pauses and restarts not included
+	Elapsed metav1.Duration `json:"elapsed"`
+	// Resumes is how many times the shard was picked up from its last report
+	Resumes int             `json:"resumes,omitempty"`
+	Stats   engine.Snapshot `json:"stats"`
+	// RecentLatency are the latencies within the abort window, it's only set with a MaxLatency to abort at
+	RecentLatency *engine.Histogram `json:"recentLatency,omitempty"`
+	// InFlight is the number of requests waiting for their response
+	InFlight int `json:"inFlight,omitempty"`
+	// Warmup are the statistics of the warmup, Warming is set while it's going and WarmupEndedBy once it's over
+	Warmup         *engine.Snapshot `json:"warmup,omitempty"`
+	Warming        bool             `json:"warming,omitempty"`
+	WarmupEndedBy  string           `json:"warmupEndedBy,omitempty"`
+	WarmupDuration metav1.Duration  `json:"warmupDuration,omitempty"`
+	// Recorded and RecordingDropped count the requests kept in and left out of the recording of the shard
+	Recorded         int `json:"recorded,omitempty"`
+	RecordingDropped int `json:"recordingDropped,omitempty"`
+	// Samples are cut short to fit in the status
+	Samples microsimv1alpha1.Samples `json:"samples"`
+	// Reason and Message are set once the shard stopped on its own, Reason is one of the reasons
+	// of the Finished condition
+	Reason  string      `json:"reason,omitempty"`
+	Message string      `json:"message,omitempty"`
+	Updated metav1.Time `json:"updated"`
+}
+
+// ReportName is the name of the ConfigMap the shard reports to
+func ReportName(loadGenerator string, shard int) string {
+	return fmt.Sprintf("%s-report-%d", loadGenerator, shard)
+}
+
+// SamplesName is the name of the ConfigMap the shard spills its samples to
+func SamplesName(loadGenerator string, shard int) string {
+	return fmt.Sprintf("%s-samples-%d", loadGenerator, shard)
+}
+
+// Labels are set on the objects created for a load generator
+func Labels(name string, loadGenerator *microsimv1alpha1.LoadGenerator) map[string]string {
+	return map[string]string{
+		"app.kubernetes.io/instance":   name,
+		"app.kubernetes.io/part-of":    loadGenerator.Name,
+		"app.kubernetes.io/managed-by": "microsim-loadgenerator",
+		"app.kubernetes.io/created-by": "microsim",
+	}
+}
+
+// ReadReport decodes the report of a shard, ok is false when the ConfigMap holds no report
+func ReadReport(configMap *v1.ConfigMap) (report Report, ok bool, err error) {
+	data, ok := configMap.Data[ReportKey]
+	if !ok {
+		return Report{}, false, nil
+	}
+	if err := json.Unmarshal([]byte(data), &report); err != nil {
+		return Report{}, false, fmt.Errorf("failed to decode report of %s: %w", configMap.Name, err)
+	}
+	return report, true, nil
+}
@@ -0,0 +1,81 @@
+package worker
+
+import (
+	"errors"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	microsimv1alpha1 "github.com/MrSupiri/MicroSim/api/v1alpha1"
+	"github.com/MrSupiri/MicroSim/engine"
+)
+
+const (
+	// maxStatusBody is the length request and response bodies are cut to in the status
+	maxStatusBody = 1024
+	// MaxSpillSize keeps the spilled samples of a shard under the 1MiB limit of a ConfigMap
+	MaxSpillSize = 900 * 1024
+)
+
+// toSamples converts the sampled results, bodies longer than maxBody are cut short unless it's zero
+func toSamples(sampler *engine.Sampler, maxBody int) microsimv1alpha1.Samples {
+	errors, successes := sampler.Samples()
+	samples := microsimv1alpha1.Samples{}
+	for _, result := range errors {
+		samples.Errors = append(samples.Errors, toSample(result, maxBody))
+	}
+	for _, result := range successes {
+		samples.Successes = append(samples.Successes, toSample(result, maxBody))
+	}
+	return samples
+}
+
+func toSample(result engine.Result, maxBody int) microsimv1alpha1.Sample {
+	sample := microsimv1alpha1.Sample{
+		Template:   result.Template,
+		Time:       metav1.NewTime(result.Sent),
+		Latency:    metav1.Duration{Duration: result.Latency},
+		StatusCode: result.StatusCode,
+		Request:    truncate(result.Request, maxBody),
+		Response:   truncate(result.Response, maxBody),
+		Sequence:   int64(result.Sequence),
+	}
+	if result.Err != nil {
+		sample.Error = result.Err.Error()
+	}
+	for _, decision := range result.Decisions {
+		sample.Route = append(sample.Route, microsimv1alpha1.RouteDecision{Path: decision.Path, Called: decision.Called})
+	}
+	return sample
+}
+
+// fromSamples converts samples back to results so a resumed run keeps them
+func fromSamples(samples []microsimv1alpha1.Sample) []engine.Result {
+	var results []engine.Result
+	for _, sample := range samples {
+		result := engine.Result{
+			Template:   sample.Template,
+			Sent:       sample.Time.Time,
+			Intended:   sample.Time.Time,
+			Latency:    sample.Latency.Duration,
+			StatusCode: sample.StatusCode,
+			Request:    []byte(sample.Request),
+			Response:   []byte(sample.Response),
+			Sequence:   uint64(sample.Sequence),
+		}
+		for _, decision := range sample.Route {
+			result.Decisions = append(result.Decisions, engine.Decision{Path: decision.Path, Called: decision.Called})
+		}
+		if sample.Error != "" {
+			result.Err = errors.New(sample.Error)
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+func truncate(body []byte, max int) string {
+	if max > 0 && len(body) > max {
+		return string(body[:max]) + "..."
+	}
+	return string(body)
+}
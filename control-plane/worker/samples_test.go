@@ -0,0 +1,25 @@
+package worker
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/MrSupiri/MicroSim/engine"
+)
+
+func TestToSamples(t *testing.T) {
+	sampler := engine.NewSampler(5, 5)
+	sampler.Record(engine.Result{Template: "checkout", StatusCode: 500, Err: errors.New("service responded with status 500"), Response: []byte("internal error")})
+	sampler.Record(engine.Result{Template: "browse", StatusCode: 200, Request: []byte("0123456789"), Response: []byte("ok")})
+
+	samples := toSamples(sampler, 4)
+	if len(samples.Errors) != 1 || samples.Errors[0].Error != "service responded with status 500" || samples.Errors[0].Response != "inte..." {
+		t.Errorf("errors = %+v, want the error message and a cut response", samples.Errors)
+	}
+	if len(samples.Successes) != 1 || samples.Successes[0].Request != "0123..." || samples.Successes[0].Response != "ok" || samples.Successes[0].Error != "" {
+		t.Errorf("successes = %+v, want only the long request cut", samples.Successes)
+	}
+	if full := toSamples(sampler, 0); full.Successes[0].Request != "0123456789" {
+		t.Errorf("request = %q, want it whole without a limit", full.Successes[0].Request)
+	}
+}
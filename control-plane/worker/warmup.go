@@ -0,0 +1,96 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	microsimv1alpha1 "github.com/MrSupiri/MicroSim/api/v1alpha1"
+	"github.com/MrSupiri/MicroSim/engine"
+)
+
+const (
+	// warmupCheckInterval is how often the end of the warmup is checked
+	warmupCheckInterval = time.Second
+	// defaultSteadyWindow and defaultSteadyTimeout are used when the spec doesn't set them
+	defaultSteadyWindow  = 30 * time.Second
+	defaultSteadyTimeout = 5 * time.Minute
+)
+
+// watchWarmup ends the warmup of the run once it's over, requests is the share of the warmup requests of the shard
+func watchWarmup(ctx context.Context, spec microsimv1alpha1.WarmupSpec, requests int, run *run) {
+	logger := log.FromContext(ctx)
+	ticker := time.NewTicker(warmupCheckInterval)
+	defer ticker.Stop()
+
+	for run.engine.Warming() {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		reason, message := warmupReason(spec, requests, run.engine.Elapsed(), run.engine.WarmupSnapshot(), time.Now())
+		if reason != "" {
+			logger.Info("warmup is over", "reason", reason, "message", message)
+			run.endWarmup(reason)
+			return
+		}
+	}
+}
+
+// warmupReason returns why the warmup is over, it's empty while the warmup is going. elapsed is how long
+// the run has been going and warmup the statistics of its warmup so far
+func warmupReason(spec microsimv1alpha1.WarmupSpec, requests int, elapsed time.Duration, warmup engine.Snapshot, now time.Time) (string, string) {
+	if spec.Duration != nil && elapsed < spec.Duration.Duration {
+		return "", ""
+	}
+	if spec.Requests != nil && warmup.Latency.Total < uint64(requests) {
+		return "", ""
+	}
+	if spec.SteadyState == nil {
+		return microsimv1alpha1.WarmupPassed, fmt.Sprintf("%d requests were sent in %v", warmup.Latency.Total, elapsed.Round(time.Second))
+	}
+
+	steady := *spec.SteadyState
+	timeout := steady.Timeout.Duration
+	if timeout <= 0 {
+		timeout = defaultSteadyTimeout
+	}
+	if elapsed >= timeout {
+		return microsimv1alpha1.WarmupTimeout, fmt.Sprintf("the load wasn't steady within %v", timeout)
+	}
+	window := steady.Window.Duration
+	if window <= 0 {
+		window = defaultSteadyWindow
+	}
+	if window > engine.MaxWindow/2 {
+		window = engine.MaxWindow / 2
+	}
+	if elapsed < 2*window {
+		return "", ""
+	}
+
+	// The current second is still filling up, only whole seconds are compared
+	end := now.Add(-time.Second)
+	last := warmup.Between(end.Add(-window), end)
+	previous := warmup.Between(end.Add(-2*window), end.Add(-window))
+	if previous.Finished == 0 || previous.MeanLatency() <= 0 {
+		return "", ""
+	}
+	throughputChange := change(float64(previous.Finished), float64(last.Finished))
+	latencyChange := change(float64(previous.MeanLatency()), float64(last.MeanLatency()))
+	if throughputChange > float64(steady.MaxThroughputChange) || latencyChange > float64(steady.MaxLatencyChange) {
+		return "", ""
+	}
+	return microsimv1alpha1.WarmupSteadyState, fmt.Sprintf("throughput changed by %.1f%% and mean latency by %.1f%% over the last %v",
+		throughputChange, latencyChange, window)
+}
+
+// change returns the absolute change from previous to current in percent
+func change(previous float64, current float64) float64 {
+	return math.Abs(current-previous) / previous * 100
+}
@@ -0,0 +1,141 @@
+package worker
+
+import (
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	microsimv1alpha1 "github.com/MrSupiri/MicroSim/api/v1alpha1"
+	"github.com/MrSupiri/MicroSim/engine"
+)
+
+// warmupSnapshot returns the warmup statistics of a run that finished requests at latency every second of
+// the window before the last one and last ones every second of the last window, the current second left out
+func warmupSnapshot(now time.Time, window int, previous, last uint64, previousLatency, lastLatency time.Duration) engine.Snapshot {
+	snapshot := engine.Snapshot{Seconds: map[int64]*engine.Second{}}
+	end := now.Unix() - 1
+	for i := 0; i < 2*window; i++ {
+		finished, latency := last, lastLatency
+		if i >= window {
+			finished, latency = previous, previousLatency
+		}
+		snapshot.Seconds[end-int64(i)] = &engine.Second{Finished: finished, Latency: finished * uint64(latency/time.Microsecond)}
+		snapshot.Latency.Total += finished
+	}
+	return snapshot
+}
+
+func TestWarmupReason(t *testing.T) {
+	now := time.Unix(10000, 0)
+	steady := &microsimv1alpha1.SteadyStateSpec{
+		Window:              metav1.Duration{Duration: 10 * time.Second},
+		MaxThroughputChange: 10,
+		MaxLatencyChange:    10,
+		Timeout:             metav1.Duration{Duration: 5 * time.Minute},
+	}
+	tests := []struct {
+		name     string
+		spec     microsimv1alpha1.WarmupSpec
+		requests int
+		elapsed  time.Duration
+		warmup   engine.Snapshot
+		want     string
+	}{
+		{
+			name:    "duration not over",
+			spec:    microsimv1alpha1.WarmupSpec{Duration: &metav1.Duration{Duration: time.Minute}},
+			elapsed: 30 * time.Second,
+			want:    "",
+		},
+		{
+			name:    "duration over",
+			spec:    microsimv1alpha1.WarmupSpec{Duration: &metav1.Duration{Duration: time.Minute}},
+			elapsed: time.Minute,
+			want:    microsimv1alpha1.WarmupPassed,
+		},
+		{
+			name:     "requests not sent",
+			spec:     microsimv1alpha1.WarmupSpec{Requests: intPtr(100)},
+			requests: 50,
+			elapsed:  time.Minute,
+			warmup:   engine.Snapshot{Latency: engine.Histogram{Total: 49}},
+			want:     "",
+		},
+		{
+			name:     "share of the requests sent",
+			spec:     microsimv1alpha1.WarmupSpec{Requests: intPtr(100)},
+			requests: 50,
+			elapsed:  time.Second,
+			warmup:   engine.Snapshot{Latency: engine.Histogram{Total: 50}},
+			want:     microsimv1alpha1.WarmupPassed,
+		},
+		{
+			name:     "duration over but requests not sent",
+			spec:     microsimv1alpha1.WarmupSpec{Duration: &metav1.Duration{Duration: time.Second}, Requests: intPtr(10)},
+			requests: 10,
+			elapsed:  time.Minute,
+			warmup:   engine.Snapshot{Latency: engine.Histogram{Total: 9}},
+			want:     "",
+		},
+		{
+			name:    "steady",
+			spec:    microsimv1alpha1.WarmupSpec{SteadyState: steady},
+			elapsed: time.Minute,
+			warmup:  warmupSnapshot(now, 10, 10, 10, 100*time.Millisecond, 105*time.Millisecond),
+			want:    microsimv1alpha1.WarmupSteadyState,
+		},
+		{
+			name:    "too early to compare two windows",
+			spec:    microsimv1alpha1.WarmupSpec{SteadyState: steady},
+			elapsed: 19 * time.Second,
+			warmup:  warmupSnapshot(now, 10, 10, 10, 100*time.Millisecond, 100*time.Millisecond),
+			want:    "",
+		},
+		{
+			name:    "throughput changing",
+			spec:    microsimv1alpha1.WarmupSpec{SteadyState: steady},
+			elapsed: time.Minute,
+			warmup:  warmupSnapshot(now, 10, 10, 12, 100*time.Millisecond, 100*time.Millisecond),
+			want:    "",
+		},
+		{
+			name:    "latency changing",
+			spec:    microsimv1alpha1.WarmupSpec{SteadyState: steady},
+			elapsed: time.Minute,
+			warmup:  warmupSnapshot(now, 10, 10, 10, 100*time.Millisecond, 50*time.Millisecond),
+			want:    "",
+		},
+		{
+			name:    "no requests in the previous window",
+			spec:    microsimv1alpha1.WarmupSpec{SteadyState: steady},
+			elapsed: time.Minute,
+			warmup:  warmupSnapshot(now, 10, 0, 10, 0, 100*time.Millisecond),
+			want:    "",
+		},
+		{
+			name:    "not steady within the timeout",
+			spec:    microsimv1alpha1.WarmupSpec{SteadyState: steady},
+			elapsed: 5 * time.Minute,
+			warmup:  warmupSnapshot(now, 10, 10, 20, 100*time.Millisecond, 100*time.Millisecond),
+			want:    microsimv1alpha1.WarmupTimeout,
+		},
+		{
+			name:    "default timeout",
+			spec:    microsimv1alpha1.WarmupSpec{SteadyState: &microsimv1alpha1.SteadyStateSpec{Window: steady.Window}},
+			elapsed: 5 * time.Minute,
+			want:    microsimv1alpha1.WarmupTimeout,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reason, message := warmupReason(tt.spec, tt.requests, tt.elapsed, tt.warmup, now)
+			if reason != tt.want {
+				t.Errorf("warmupReason() = %q (%s), want %q", reason, message, tt.want)
+			}
+			if reason != "" && message == "" {
+				t.Errorf("warmupReason() gave no message with %s", reason)
+			}
+		})
+	}
+}
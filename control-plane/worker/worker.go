@@ -0,0 +1,500 @@
+// Package worker runs a shard of a load generator, it's run by the worker pods created by the
+// load generator controller and reports its results through a ConfigMap.
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	microsimv1alpha1 "github.com/MrSupiri/MicroSim/api/v1alpha1"
+	"github.com/MrSupiri/MicroSim/engine"
+)
+
+const (
+	// reportInterval is how often a worker writes its report
+	reportInterval = 2 * time.Second
+	// spillInterval is how often the spilled samples are written while a run is going
+	spillInterval = 30 * time.Second
+	// simulationRefreshInterval is how often a run picks up changes to the simulation endpoints
+	simulationRefreshInterval = 10 * time.Second
+	// finalWriteTimeout bounds the last writes after the run is over
+	finalWriteTimeout = 10 * time.Second
+)
+
+// Worker sends the share of the load of a load generator that belongs to one shard
+type Worker struct {
+	client.Client
+	Scheme *runtime.Scheme
+
+	LoadGenerator types.NamespacedName
+	Shard         int
+	Shards        int
+}
+
+// run is the state of the engine of a worker
+type run struct {
+	engine  *engine.Engine
+	sampler *engine.Sampler
+	spill   *engine.Sampler
+	// records is nil unless the results of the run are exported
+	records *recorder
+	// recording is nil unless the run is recorded
+	recording *recording
+	// cancel stops the engine
+	cancel context.CancelFunc
+	// resumes is how many times the shard was picked up from its last report
+	resumes int
+
+	mu sync.Mutex
+	// reason and message of the Finished condition, reason is empty while the run is going
+	reason  string
+	message string
+	// warmupEndedBy is why the warmup is over and warmupDuration how long it lasted
+	warmupEndedBy  string
+	warmupDuration time.Duration
+}
+
+// endWarmup starts recording the results of the run
+func (r *run) endWarmup(reason string) {
+	if !r.engine.EndWarmup() {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.warmupEndedBy, r.warmupDuration = reason, r.engine.Elapsed()
+}
+
+func (r *run) warmup() (string, time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.warmupEndedBy, r.warmupDuration
+}
+
+// finish records why the run stopped, only the first reason is kept
+func (r *run) finish(reason string, message string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.reason == "" {
+		r.reason, r.message = reason, message
+	}
+}
+
+func (r *run) result() (string, string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.reason, r.message
+}
+
+// Run sends requests until the shard is done or ctx is cancelled, the report is written one last
+// time before it returns so a stopped worker doesn't lose its results
+func (w *Worker) Run(ctx context.Context) error {
+	logger := log.FromContext(ctx)
+
+	var loadGenerator microsimv1alpha1.LoadGenerator
+	if err := w.Get(ctx, w.LoadGenerator, &loadGenerator); err != nil {
+		return err
+	}
+	var simulation microsimv1alpha1.Simulation
+	if err := w.Get(ctx, loadGenerator.Spec.SimulationRef.NamespacedName(), &simulation); err != nil {
+		return err
+	}
+
+	var target *engine.Target
+	var replay []engine.Recorded
+	var templates []microsimv1alpha1.RouteTemplate
+	if spec := loadGenerator.Spec.Replay; spec != nil {
+		target = engine.NewReplayTarget(simulation, SeedOf(&loadGenerator, w.Shard))
+		var err error
+		if replay, err = w.readRecording(ctx, &loadGenerator, *spec); err != nil {
+			return fmt.Errorf("error while reading recording: %w", err)
+		}
+	} else {
+		var err error
+		if templates, err = loadGenerator.Spec.RouteTemplates(); err != nil {
+			return fmt.Errorf("error while decoding request spec: %w", err)
+		}
+		if target, err = engine.NewTarget(ctx, simulation, templates, SeedOf(&loadGenerator, w.Shard)); err != nil {
+			return fmt.Errorf("error while expanding route templates: %w", err)
+		}
+		if err := target.SetScenarios(loadGenerator.Spec.Scenarios); err != nil {
+			return fmt.Errorf("invalid scenarios: %w", err)
+		}
+	}
+	config, err := EngineConfig(loadGenerator.Spec, len(templates), w.Shard, w.Shards)
+	if err != nil {
+		return fmt.Errorf("invalid load model: %w", err)
+	}
+
+	sampling := SamplingOf(loadGenerator.Spec)
+	r := &run{
+		engine:  &engine.Engine{Config: config, Send: target.Send},
+		sampler: engine.NewSampler(sampling.Errors, sampling.Successes),
+	}
+	switch {
+	case loadGenerator.Spec.Replay != nil:
+		r.engine.Replay, r.engine.SendRecorded = replay, target.Replay
+	case len(loadGenerator.Spec.Scenarios) > 0:
+		r.engine.NewJourney = target.Journey
+	}
+	if sampling.Spill != nil {
+		r.spill = engine.NewSampler(sampling.Spill.Errors, sampling.Spill.Successes)
+	}
+	if export := loadGenerator.Spec.Export; export != nil {
+		r.records = newRecorder(w.Shard, Share(export.MaxRecords, w.Shard, w.Shards))
+	}
+	if loadGenerator.Spec.Record != nil {
+		r.recording = &recording{}
+	}
+	r.engine.OnResult = func(result engine.Result) {
+		if result.Err != nil {
+			logger.V(1).Info("request failed", "template", result.Template, "error", result.Err.Error())
+		}
+		if r.records != nil {
+			r.records.Record(result)
+		}
+		if r.recording != nil {
+			if err := r.recording.Record(result); err != nil {
+				logger.Error(err, "failed to record request")
+			}
+		}
+		if result.Warmup {
+			return
+		}
+		r.sampler.Record(result)
+		if r.spill != nil {
+			r.spill.Record(result)
+		}
+	}
+
+	// Pick up the run from the last report of this generation, the shard stops halfway when the load
+	// generator is paused or its pod is restarted. Results after the last report are lost on a crash
+	checkpoint, ok, err := w.checkpoint(ctx, &loadGenerator)
+	if err != nil {
+		return err
+	}
+	if loadGenerator.Spec.Warmup != nil && (!ok || checkpoint.Warming) {
+		r.engine.BeginWarmup()
+	}
+	// The limit and the sequence count journeys with scenarios
+	done := checkpoint.Stats.Latency.Total
+	if len(loadGenerator.Spec.Scenarios) > 0 {
+		done = checkpoint.Stats.Journeys()
+	}
+	if checkpoint.Warmup != nil {
+		if len(loadGenerator.Spec.Scenarios) > 0 {
+			done += checkpoint.Warmup.Journeys()
+		} else {
+			done += checkpoint.Warmup.Latency.Total
+		}
+	}
+	if ok {
+		if checkpoint.Reason != "" {
+			logger.Info("shard already finished", "reason", checkpoint.Reason)
+			return nil
+		}
+		if err := w.restore(ctx, &loadGenerator, r, checkpoint); err != nil {
+			return err
+		}
+		if loadGenerator.Spec.Replay == nil {
+			target.Resume(done)
+		}
+		logger.Info("resuming load", "elapsed", checkpoint.Elapsed.Duration, "done", done)
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	r.cancel = cancel
+	// The timeout counts the time the shard spent sending requests, pauses and restarts are left out
+	deadline := time.Time{}
+	if loadGenerator.Spec.Timeout != nil {
+		deadline = time.Now().Add(loadGenerator.Spec.Timeout.Duration - checkpoint.Elapsed.Duration)
+		var cancelDeadline context.CancelFunc
+		runCtx, cancelDeadline = context.WithDeadline(runCtx, deadline)
+		defer cancelDeadline()
+	}
+
+	// Background tasks are stopped once the engine returns rather than when the run is cancelled,
+	// so they see every result of the requests that were in flight
+	tasksCtx, stopTasks := context.WithCancel(ctx)
+	var tasks sync.WaitGroup
+	start := func(task func(context.Context)) {
+		tasks.Add(1)
+		go func() {
+			defer tasks.Done()
+			task(tasksCtx)
+		}()
+	}
+	start(func(ctx context.Context) {
+		w.refreshSimulation(ctx, loadGenerator.Spec.SimulationRef.NamespacedName(), target)
+	})
+	start(func(ctx context.Context) {
+		w.every(ctx, reportInterval, func(ctx context.Context) error { return w.report(ctx, &loadGenerator, r) })
+	})
+	if r.spill != nil {
+		start(func(ctx context.Context) {
+			w.every(ctx, spillInterval, func(ctx context.Context) error { return w.spillSamples(ctx, &loadGenerator, r) })
+		})
+	}
+	if r.records != nil {
+		start(func(ctx context.Context) {
+			w.every(ctx, spillInterval, func(ctx context.Context) error { return w.writeRecords(ctx, &loadGenerator, r) })
+		})
+	}
+	if r.recording != nil {
+		start(func(ctx context.Context) {
+			w.every(ctx, spillInterval, func(ctx context.Context) error { return w.writeRecording(ctx, &loadGenerator, r) })
+		})
+	}
+	if warmup := loadGenerator.Spec.Warmup; warmup != nil && r.engine.Warming() {
+		requests := 0
+		if warmup.Requests != nil {
+			requests = Share(*warmup.Requests, w.Shard, w.Shards)
+		}
+		start(func(ctx context.Context) { watchWarmup(ctx, *warmup, requests, r) })
+	}
+
+	if loadGenerator.Spec.RequestCount != nil {
+		r.engine.Limit = Share(*loadGenerator.Spec.RequestCount, w.Shard, w.Shards) - int(done)
+	}
+	if loadGenerator.Spec.RequestCount == nil || r.engine.Limit > 0 {
+		logger.Info("starting load", "shard", w.Shard, "shards", w.Shards, "model", config.Model)
+		r.engine.Run(runCtx)
+	}
+	switch {
+	case errors.Is(runCtx.Err(), context.DeadlineExceeded):
+		r.finish(microsimv1alpha1.ReasonTimeout, fmt.Sprintf("Timeout was reached at %v", deadline.Format(time.RFC3339)))
+	case runCtx.Err() == nil:
+		r.finish(microsimv1alpha1.ReasonCompleted, "All the requests were sent")
+	}
+	stopTasks()
+	tasks.Wait()
+
+	// ctx might be cancelled already, the last writes get one of their own
+	writeCtx, cancelWrite := context.WithTimeout(log.IntoContext(context.Background(), logger), finalWriteTimeout)
+	defer cancelWrite()
+	if r.spill != nil {
+		if err := w.spillSamples(writeCtx, &loadGenerator, r); err != nil {
+			logger.Error(err, "failed to spill samples")
+		}
+	}
+	if r.records != nil {
+		if err := w.writeRecords(writeCtx, &loadGenerator, r); err != nil {
+			logger.Error(err, "failed to write records")
+		}
+	}
+	if r.recording != nil {
+		if err := w.writeRecording(writeCtx, &loadGenerator, r); err != nil {
+			logger.Error(err, "failed to write recording")
+		}
+	}
+	if err := w.report(writeCtx, &loadGenerator, r); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	reason, _ := r.result()
+	logger.Info("load finished", "reason", reason)
+	return nil
+}
+
+// every runs write every interval until ctx is done
+func (w *Worker) every(ctx context.Context, interval time.Duration, write func(context.Context) error) {
+	logger := log.FromContext(ctx)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := write(ctx); err != nil {
+			logger.Error(err, "failed to write results")
+		}
+	}
+}
+
+func (w *Worker) report(ctx context.Context, loadGenerator *microsimv1alpha1.LoadGenerator, r *run) error {
+	reason, message := r.result()
+	report := Report{
+		Generation: loadGenerator.Generation,
+		Shard:      w.Shard,
+		Stage:      r.engine.StageName(),
+		Elapsed:    metav1.Duration{Duration: r.engine.Elapsed()},
+		Resumes:    r.resumes,
+		Stats:      r.engine.Snapshot(),
+		InFlight:   r.engine.InFlight(),
+		Samples:    toSamples(r.sampler, maxStatusBody),
+		Reason:     reason,
+		Message:    message,
+		Updated:    metav1.Now(),
+	}
+	if abort := loadGenerator.Spec.Abort; abort != nil && abort.MaxLatency != nil {
+		recent := r.engine.RecentLatency(AbortWindow(*abort))
+		report.RecentLatency = &recent
+	}
+	if loadGenerator.Spec.Warmup != nil {
+		warmup := r.engine.WarmupSnapshot()
+		report.Warmup = &warmup
+		report.Warming = r.engine.Warming()
+		endedBy, duration := r.warmup()
+		report.WarmupEndedBy = endedBy
+		report.WarmupDuration = metav1.Duration{Duration: duration}
+		if report.Warming {
+			report.WarmupDuration.Duration = report.Elapsed.Duration
+		}
+	}
+	if r.recording != nil {
+		report.Recorded, report.RecordingDropped = r.recording.Counts()
+	}
+	data, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	return w.writeConfigMap(ctx, loadGenerator, ReportName(loadGenerator.Name, w.Shard), ReportKey, data)
+}
+
+// checkpoint reads the last report of the shard, ok is false when there's none for the current generation
+func (w *Worker) checkpoint(ctx context.Context, loadGenerator *microsimv1alpha1.LoadGenerator) (Report, bool, error) {
+	var configMap v1.ConfigMap
+	key := types.NamespacedName{Namespace: loadGenerator.Namespace, Name: ReportName(loadGenerator.Name, w.Shard)}
+	if err := w.Get(ctx, key, &configMap); err != nil {
+		return Report{}, false, client.IgnoreNotFound(err)
+	}
+	report, ok, err := ReadReport(&configMap)
+	if err != nil || !ok || report.Generation != loadGenerator.Generation {
+		return Report{}, false, err
+	}
+	return report, true, nil
+}
+
+// restore continues the statistics, the samples and the profile of the run from the checkpoint
+func (w *Worker) restore(ctx context.Context, loadGenerator *microsimv1alpha1.LoadGenerator, r *run, checkpoint Report) error {
+	r.resumes = checkpoint.Resumes + 1
+	r.engine.Offset = checkpoint.Elapsed.Duration
+	r.engine.Restore(checkpoint.Stats)
+	if checkpoint.Warmup != nil {
+		r.engine.RestoreWarmup(*checkpoint.Warmup)
+		r.warmupEndedBy, r.warmupDuration = checkpoint.WarmupEndedBy, checkpoint.WarmupDuration.Duration
+	}
+	r.sampler.Restore(fromSamples(checkpoint.Samples.Errors), fromSamples(checkpoint.Samples.Successes), checkpoint.Stats.Succeeded)
+	if r.records != nil {
+		var configMap v1.ConfigMap
+		key := types.NamespacedName{Namespace: loadGenerator.Namespace, Name: RecordsName(loadGenerator.Name, w.Shard)}
+		if err := w.Get(ctx, key, &configMap); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+		records, err := DecodeRecords(configMap.Data[RecordsKey])
+		if err != nil {
+			return fmt.Errorf("failed to decode records of %s: %w", configMap.Name, err)
+		}
+		r.records.Restore(records)
+	}
+	if r.recording != nil {
+		var configMap v1.ConfigMap
+		key := types.NamespacedName{Namespace: loadGenerator.Namespace, Name: RecordingName(loadGenerator.Name, w.Shard)}
+		if err := w.Get(ctx, key, &configMap); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+		r.recording.Restore(configMap.Data[RecordingKey], checkpoint.RecordingDropped)
+	}
+	if r.spill == nil {
+		return nil
+	}
+
+	var configMap v1.ConfigMap
+	key := types.NamespacedName{Namespace: loadGenerator.Namespace, Name: SamplesName(loadGenerator.Name, w.Shard)}
+	if err := w.Get(ctx, key, &configMap); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	var samples microsimv1alpha1.Samples
+	if err := json.Unmarshal([]byte(configMap.Data[SamplesKey]), &samples); err != nil {
+		return fmt.Errorf("failed to decode samples of %s: %w", configMap.Name, err)
+	}
+	r.spill.Restore(fromSamples(samples.Errors), fromSamples(samples.Successes), checkpoint.Stats.Succeeded)
+	return nil
+}
+
+// spillSamples writes the spilled samples with their full bodies, samples are dropped until they fit in a ConfigMap
+func (w *Worker) spillSamples(ctx context.Context, loadGenerator *microsimv1alpha1.LoadGenerator, r *run) error {
+	samples := toSamples(r.spill, 0)
+	data, err := json.Marshal(samples)
+	if err != nil {
+		return err
+	}
+	// The longer list goes first so both kinds are kept
+	for len(data) > MaxSpillSize && len(samples.Errors)+len(samples.Successes) > 0 {
+		if len(samples.Errors) > len(samples.Successes) {
+			samples.Errors = samples.Errors[1:]
+		} else {
+			samples.Successes = samples.Successes[:len(samples.Successes)-1]
+		}
+		if data, err = json.Marshal(samples); err != nil {
+			return err
+		}
+	}
+	return w.writeConfigMap(ctx, loadGenerator, SamplesName(loadGenerator.Name, w.Shard), SamplesKey, data)
+}
+
+// writeRecords writes the recorded requests, the ones that don't fit in a ConfigMap are left out
+func (w *Worker) writeRecords(ctx context.Context, loadGenerator *microsimv1alpha1.LoadGenerator, r *run) error {
+	data, err := r.records.Encode(MaxSpillSize)
+	if err != nil {
+		return err
+	}
+	return w.writeConfigMap(ctx, loadGenerator, RecordsName(loadGenerator.Name, w.Shard), RecordsKey, data)
+}
+
+func (w *Worker) writeConfigMap(ctx context.Context, loadGenerator *microsimv1alpha1.LoadGenerator, name string, key string, data []byte) error {
+	configMap := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: loadGenerator.Namespace,
+		},
+	}
+	_, err := controllerutil.CreateOrUpdate(ctx, w.Client, configMap, func() error {
+		configMap.Labels = Labels(name, loadGenerator)
+		configMap.Data = map[string]string{key: string(data)}
+		return ctrl.SetControllerReference(loadGenerator, configMap, w.Scheme)
+	})
+	return err
+}
+
+// refreshSimulation keeps the endpoints of the target up to date while the run is going, the simulation
+// status is filled in after the services are provisioned
+func (w *Worker) refreshSimulation(ctx context.Context, key types.NamespacedName, target *engine.Target) {
+	logger := log.FromContext(ctx)
+	ticker := time.NewTicker(simulationRefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		var simulation microsimv1alpha1.Simulation
+		if err := w.Get(ctx, key, &simulation); err != nil {
+			logger.Error(err, "failed to refresh simulation")
+			continue
+		}
+		target.SetSimulation(ctx, simulation)
+	}
+}
+
+// writeRecording writes the recorded requests of the shard, a replay reads them from there
+func (w *Worker) writeRecording(ctx context.Context, loadGenerator *microsimv1alpha1.LoadGenerator, r *run) error {
+	return w.writeConfigMap(ctx, loadGenerator, RecordingName(loadGenerator.Name, w.Shard), RecordingKey, r.recording.Data())
+}
@@ -0,0 +1,163 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	microsimv1alpha1 "github.com/MrSupiri/MicroSim/api/v1alpha1"
+	"github.com/MrSupiri/MicroSim/engine"
+)
+
+func testScheme(t *testing.T) *runtime.Scheme {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := microsimv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	return scheme
+}
+
+func testWorker(t *testing.T) *Worker {
+	scheme := testScheme(t)
+	return &Worker{Client: fake.NewClientBuilder().WithScheme(scheme).Build(), Scheme: scheme, Shard: 1, Shards: 2}
+}
+
+func TestReport(t *testing.T) {
+	loadGenerator := &microsimv1alpha1.LoadGenerator{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "browse", UID: "0123456789", Generation: 2}}
+	r := &run{engine: &engine.Engine{}, sampler: engine.NewSampler(5, 5)}
+	r.finish(microsimv1alpha1.ReasonCompleted, "All the requests were sent")
+
+	tests := []struct {
+		name   string
+		abort  *microsimv1alpha1.AbortSpec
+		recent bool
+	}{
+		{name: "without abort conditions"},
+		{name: "error rate only", abort: &microsimv1alpha1.AbortSpec{MaxErrorRate: new(int)}},
+		{name: "max latency", abort: &microsimv1alpha1.AbortSpec{MaxLatency: &metav1.Duration{Duration: time.Second}}, recent: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := testWorker(t)
+			loadGenerator.Spec.Abort = tt.abort
+			if err := w.report(context.Background(), loadGenerator, r); err != nil {
+				t.Fatalf("report() error = %v", err)
+			}
+
+			var configMap v1.ConfigMap
+			if err := w.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: "browse-report-1"}, &configMap); err != nil {
+				t.Fatal(err)
+			}
+			report, ok, err := ReadReport(&configMap)
+			if err != nil || !ok {
+				t.Fatalf("ReadReport() = %v, %v", ok, err)
+			}
+			if report.Generation != 2 || report.Shard != 1 || report.Reason != microsimv1alpha1.ReasonCompleted {
+				t.Errorf("report = %+v, want generation 2 of shard 1 completed", report)
+			}
+			if (report.RecentLatency != nil) != tt.recent {
+				t.Errorf("recent latency = %v, want it set: %v", report.RecentLatency, tt.recent)
+			}
+			if len(configMap.OwnerReferences) != 1 || configMap.OwnerReferences[0].UID != loadGenerator.UID {
+				t.Errorf("owner references = %+v, want the load generator", configMap.OwnerReferences)
+			}
+		})
+	}
+}
+
+func TestReadReport(t *testing.T) {
+	if _, ok, err := ReadReport(&v1.ConfigMap{}); ok || err != nil {
+		t.Errorf("ReadReport() of an empty ConfigMap = %v, %v, want no report", ok, err)
+	}
+	if _, ok, err := ReadReport(&v1.ConfigMap{Data: map[string]string{ReportKey: "{"}}); ok || err == nil {
+		t.Errorf("ReadReport() of an invalid report = %v, %v, want an error", ok, err)
+	}
+}
+
+func TestCheckpoint(t *testing.T) {
+	loadGenerator := &microsimv1alpha1.LoadGenerator{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "browse", Generation: 2}}
+	w := testWorker(t)
+	if _, ok, err := w.checkpoint(context.Background(), loadGenerator); ok || err != nil {
+		t.Fatalf("checkpoint() without a report = %v, %v, want none", ok, err)
+	}
+
+	// The shard ran for 3 minutes and was restarted once already
+	r := &run{engine: &engine.Engine{}, sampler: engine.NewSampler(5, 5), resumes: 1}
+	r.engine.Offset = 3 * time.Minute
+	r.engine.Restore(engine.Snapshot{Latency: engine.Histogram{Total: 40}, Succeeded: 38, Failed: 2})
+	if err := w.report(context.Background(), loadGenerator, r); err != nil {
+		t.Fatalf("report() error = %v", err)
+	}
+
+	checkpoint, ok, err := w.checkpoint(context.Background(), loadGenerator)
+	if err != nil || !ok {
+		t.Fatalf("checkpoint() = %v, %v", ok, err)
+	}
+	resumed := &run{engine: &engine.Engine{}, sampler: engine.NewSampler(5, 5)}
+	if err := w.restore(context.Background(), loadGenerator, resumed, checkpoint); err != nil {
+		t.Fatalf("restore() error = %v", err)
+	}
+	if resumed.resumes != 2 {
+		t.Errorf("resumes = %d, want 2", resumed.resumes)
+	}
+	if got := resumed.engine.Elapsed(); got != 3*time.Minute {
+		t.Errorf("elapsed = %v, want the 3m the shard already ran", got)
+	}
+	if got := resumed.engine.Snapshot().Latency.Total; got != 40 {
+		t.Errorf("requests = %d, want the 40 sent before the restart", got)
+	}
+
+	loadGenerator.Generation = 3
+	if _, ok, err := w.checkpoint(context.Background(), loadGenerator); ok || err != nil {
+		t.Errorf("checkpoint() of an older generation = %v, %v, want none", ok, err)
+	}
+}
+
+func TestSpillSamples(t *testing.T) {
+	loadGenerator := &microsimv1alpha1.LoadGenerator{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "browse"}}
+	// 40 errors and 20 successes of 32KiB each are well over the size of a ConfigMap
+	r := &run{spill: engine.NewSampler(40, 20)}
+	body := []byte(strings.Repeat("x", 32*1024))
+	for i := 0; i < 40; i++ {
+		r.spill.Record(engine.Result{Err: errors.New("failed"), Response: body})
+	}
+	for i := 0; i < 20; i++ {
+		r.spill.Record(engine.Result{StatusCode: 200, Response: body})
+	}
+
+	w := testWorker(t)
+	if err := w.spillSamples(context.Background(), loadGenerator, r); err != nil {
+		t.Fatalf("spillSamples() error = %v", err)
+	}
+	var configMap v1.ConfigMap
+	if err := w.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: "browse-samples-1"}, &configMap); err != nil {
+		t.Fatal(err)
+	}
+	data := configMap.Data[SamplesKey]
+	if len(data) > MaxSpillSize {
+		t.Errorf("spilled %d bytes, want at most %d", len(data), MaxSpillSize)
+	}
+	var spilled microsimv1alpha1.Samples
+	if err := json.Unmarshal([]byte(data), &spilled); err != nil {
+		t.Fatal(err)
+	}
+	if len(spilled.Errors) == 0 || len(spilled.Successes) == 0 {
+		t.Errorf("spilled %d errors and %d successes, want both kinds kept", len(spilled.Errors), len(spilled.Successes))
+	}
+	if spilled.Errors[0].Response != string(body) {
+		t.Errorf("spilled bodies were cut short")
+	}
+}